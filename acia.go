@@ -0,0 +1,253 @@
+package mos6502
+
+import "io"
+
+/*
+ACIA registers, as offsets from where the chip is mapped. Only two address lines are decoded, so the registers repeat
+every 4 bytes.
+*/
+const (
+	aciaData Address = iota
+	aciaStatus
+	aciaCommand
+	aciaControl
+)
+
+/*
+ACIA status register bits.
+*/
+const (
+	aciaParityError byte = 1 << iota
+	aciaFramingError
+	aciaOverrun
+	aciaRDRF
+	aciaTDRE
+	aciaDCD
+	aciaDSR
+	aciaIRQ
+)
+
+/*
+aciaBaud is the rate picked by the low bits of the control register. Zero selects the 16x external clock, which is
+taken to be the common 1.8432MHz crystal.
+*/
+var aciaBaud = [16]uint64{115200, 50, 75, 110, 135, 150, 300, 600, 1200, 1800, 2400, 3600, 4800, 7200, 9600, 19200}
+
+/*
+ACIA emulates the MOS 6551 Asynchronous Communications Interface Adapter. The serial side is bridged to any
+io.ReadWriter: characters written by the program go out to it, and characters read from it arrive at the selected
+baud rate, timed against the clock the chip is ticked with.
+*/
+type ACIA struct {
+	// Frequency of the clock the chip is ticked with, used to time characters at the selected baud rate.
+	ClockHz uint64
+
+	host io.Writer
+	rx   *hostInput
+	err  error
+
+	status  byte
+	command byte
+	control byte
+
+	// Receive and transmit data registers.
+	rdr byte
+	tdr byte
+
+	// Characters being shifted in and out, and the cycles left until they are done.
+	rxShift byte
+	rxWait  uint64
+	txShift byte
+	txWait  uint64
+}
+
+/*
+NewACIA returns an ACIA in its reset state, bridged to host and timed for a clock of clockHz. Reading from host
+happens in the background, and stops when host returns an error or the ACIA is closed.
+*/
+func NewACIA(host io.ReadWriter, clockHz uint64) *ACIA {
+	a := &ACIA{ClockHz: clockHz}
+	if host != nil {
+		a.host = host
+		a.rx = readHost(host)
+	}
+	a.Reset()
+	return a
+}
+
+/*
+Close stops reading from the host in the background. The host itself is left open, and characters written by the
+program still go out to it.
+*/
+func (a *ACIA) Close() {
+	a.rx.Close()
+}
+
+/*
+Reset puts the registers in the state the RES line leaves them.
+*/
+func (a *ACIA) Reset() {
+	a.status = aciaTDRE
+	a.command = 0
+	a.control = 0
+	a.rxWait, a.txWait = 0, 0
+}
+
+/*
+Read returns the value of a register. Reading data takes the received character, reading status clears the
+interrupt.
+*/
+func (a *ACIA) Read(r Address) byte {
+	switch r & 0x03 {
+	case aciaData:
+		a.status &^= aciaRDRF | aciaOverrun | aciaFramingError | aciaParityError
+		return a.rdr
+	case aciaStatus:
+		s := a.status
+		a.status &^= aciaIRQ
+		return s
+	case aciaCommand:
+		return a.command
+	default:
+		return a.control
+	}
+}
+
+/*
+Write stores a value in a register. Writing data queues a character to send, writing status is a programmed reset.
+*/
+func (a *ACIA) Write(r Address, d byte) {
+	switch r & 0x03 {
+	case aciaData:
+		a.tdr = d
+		a.status &^= aciaTDRE
+	case aciaStatus:
+		a.command &= 0xE0
+		a.status &^= aciaOverrun
+	case aciaCommand:
+		a.command = d
+	default:
+		a.control = d
+	}
+}
+
+/*
+Tick advances the chip by one cycle of the system clock.
+*/
+func (a *ACIA) Tick() {
+	a.tickTransmit()
+	a.tickReceive()
+}
+
+/*
+Interrupting reports if the IRQ output is asserted.
+*/
+func (a *ACIA) Interrupting() bool {
+	return a.status&aciaIRQ != 0
+}
+
+/*
+Err returns the first error from the host connection, other than it reaching the end of its input.
+*/
+func (a *ACIA) Err() error {
+	if a.err != nil {
+		return a.err
+	}
+	return a.rx.Err()
+}
+
+/*
+tickTransmit moves a waiting character into the shift register and, a character time later, out to the host.
+*/
+func (a *ACIA) tickTransmit() {
+	if a.txWait > 0 {
+		a.txWait--
+		if a.txWait > 0 {
+			return
+		}
+		a.send(a.txShift)
+	}
+
+	// The transmitter is off while TIC is zero.
+	if a.status&aciaTDRE != 0 || a.command&0x0C == 0 {
+		return
+	}
+
+	a.txShift = a.tdr & a.wordMask()
+	a.txWait = a.frameCycles()
+	a.status |= aciaTDRE
+	if a.command&0x0C == 0x04 {
+		a.status |= aciaIRQ
+	}
+}
+
+/*
+tickReceive starts taking in a character from the host once the last one has been read, and a character time later
+makes it available in the data register. Characters wait on the host side rather than overrunning.
+*/
+func (a *ACIA) tickReceive() {
+	if a.rxWait > 0 {
+		a.rxWait--
+		if a.rxWait > 0 {
+			return
+		}
+		a.rdr = a.rxShift
+		a.status |= aciaRDRF
+		if a.command&0x02 == 0 {
+			a.status |= aciaIRQ
+		}
+		if a.command&0x10 != 0 {
+			a.send(a.rdr)
+		}
+		return
+	}
+
+	// The receiver is off while DTR is not asserted.
+	if a.command&0x01 == 0 || a.status&aciaRDRF != 0 {
+		return
+	}
+
+	if b, ok := a.rx.poll(); ok {
+		a.rxShift = b & a.wordMask()
+		a.rxWait = a.frameCycles()
+	}
+}
+
+/*
+frameCycles returns how many clock cycles one character takes on the wire, including start, parity and stop bits.
+*/
+func (a *ACIA) frameCycles() uint64 {
+	bits := uint64(1 + a.wordLength() + 1)
+	if a.command&0x20 != 0 {
+		bits++
+	}
+	if a.control&0x80 != 0 {
+		bits++
+	}
+
+	cycles := a.ClockHz * bits / aciaBaud[a.control&0x0F]
+	if cycles == 0 {
+		return 1
+	}
+	return cycles
+}
+
+func (a *ACIA) wordLength() int {
+	return 8 - int((a.control>>5)&0x03)
+}
+
+func (a *ACIA) wordMask() byte {
+	return byte(0xFF >> uint(8-a.wordLength()))
+}
+
+/*
+send writes a character out to the host.
+*/
+func (a *ACIA) send(b byte) {
+	if a.host == nil {
+		return
+	}
+	if _, err := a.host.Write([]byte{b}); err != nil && a.err == nil {
+		a.err = err
+	}
+}
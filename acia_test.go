@@ -0,0 +1,177 @@
+package mos6502
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+/*
+serialHost joins a reader and writer into the host side of a serial connection.
+*/
+type serialHost struct {
+	io.Reader
+	io.Writer
+}
+
+func tickACIA(a *ACIA, n uint64) {
+	for i := uint64(0); i < n; i++ {
+		a.Tick()
+	}
+}
+
+func TestACIAFrameCycles(t *testing.T) {
+	var tests = map[string]struct {
+		control byte
+		command byte
+		cycles  uint64
+	}{
+		"9600 8N1":  {control: 0x1E, command: 0x0B, cycles: 1041},
+		"19200 8N1": {control: 0x1F, command: 0x0B, cycles: 520},
+		"300 7E1":   {control: 0x36, command: 0x6B, cycles: 33333},
+		"1200 8N2":  {control: 0x98, command: 0x0B, cycles: 9166},
+		"external":  {control: 0x10, command: 0x0B, cycles: 86},
+		"110 5N1":   {control: 0x73, command: 0x0B, cycles: 63636},
+	}
+
+	for k, tt := range tests {
+		t.Run(k, func(t *testing.T) {
+			a := NewACIA(nil, 1000000)
+			a.Write(aciaControl, tt.control)
+			a.Write(aciaCommand, tt.command)
+
+			expectUint64(t, tt.cycles, a.frameCycles())
+		})
+	}
+}
+
+func TestACIATransmit(t *testing.T) {
+	var out bytes.Buffer
+	a := NewACIA(serialHost{strings.NewReader(""), &out}, 1000000)
+	a.Write(aciaControl, 0x1E)
+	a.Write(aciaCommand, 0x07)
+
+	a.Write(aciaData, 'A')
+	expectByte(t, 0x00, a.Read(aciaStatus)&aciaTDRE)
+
+	// Loading the shift register empties the data register and, with TIC at 01, interrupts.
+	a.Tick()
+	expectBool(t, true, a.Interrupting())
+	expectByte(t, aciaTDRE|aciaIRQ, a.Read(aciaStatus)&(aciaTDRE|aciaIRQ))
+	expectBool(t, false, a.Interrupting())
+
+	tickACIA(a, 1040)
+	expectString(t, "", out.String())
+	a.Tick()
+	expectString(t, "A", out.String())
+}
+
+func TestACIATransmitterOff(t *testing.T) {
+	var out bytes.Buffer
+	a := NewACIA(serialHost{strings.NewReader(""), &out}, 1000000)
+	a.Write(aciaControl, 0x1F)
+	a.Write(aciaData, 'A')
+
+	tickACIA(a, 10000)
+	expectString(t, "", out.String())
+	expectByte(t, 0x00, a.Read(aciaStatus)&aciaTDRE)
+}
+
+func TestACIAReceive(t *testing.T) {
+	var tests = map[string]struct {
+		command   byte
+		irq       bool
+		echo      string
+		available bool
+	}{
+		"interrupts":   {command: 0x09, irq: true, available: true},
+		"no interrupt": {command: 0x0B, irq: false, available: true},
+		"echo":         {command: 0x1B, irq: false, echo: "hi", available: true},
+		"not ready":    {command: 0x0A, irq: false, available: false},
+	}
+
+	for k, tt := range tests {
+		t.Run(k, func(t *testing.T) {
+			var out bytes.Buffer
+			a := &ACIA{ClockHz: 1000000, host: serialHost{nil, &out}, rx: &hostInput{ch: make(chan byte, 2)}}
+			a.Reset()
+			a.rx.ch <- 'h'
+			a.rx.ch <- 'i'
+			a.Write(aciaControl, 0x1F)
+			a.Write(aciaCommand, tt.command)
+
+			tickACIA(a, 521)
+			expectBool(t, tt.available, a.status&aciaRDRF != 0)
+			expectBool(t, tt.irq, a.Interrupting())
+			if !tt.available {
+				return
+			}
+			expectByte(t, 'h', a.Read(aciaData))
+
+			// The second character is not taken until the first has been read.
+			tickACIA(a, 521)
+			expectByte(t, 'i', a.Read(aciaData))
+			expectString(t, tt.echo, out.String())
+		})
+	}
+}
+
+func TestACIAProgrammedReset(t *testing.T) {
+	a := NewACIA(nil, 1000000)
+	a.Write(aciaControl, 0x1F)
+	a.Write(aciaCommand, 0xEB)
+	a.Write(aciaStatus, 0x00)
+
+	expectByte(t, 0xE0, a.Read(aciaCommand))
+	expectByte(t, 0x1F, a.Read(aciaControl))
+}
+
+func TestACIAHostBridge(t *testing.T) {
+	a := NewACIA(serialHost{strings.NewReader("ok"), io.Discard}, 1000000)
+
+	for _, expected := range []byte("ok") {
+		select {
+		case b := <-a.rx.ch:
+			expectByte(t, expected, b)
+		case <-time.After(time.Second):
+			t.Fatal("Expected the host input to be queued.")
+		}
+	}
+	expectBool(t, true, a.Err() == nil)
+}
+
+/*
+endless is a host which always has more to send.
+*/
+type endless struct{}
+
+func (endless) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 'A'
+	}
+	return len(p), nil
+}
+
+/*
+expectStopped fails unless the background reader of h stops soon.
+*/
+func expectStopped(t *testing.T, h *hostInput) {
+	t.Helper()
+	select {
+	case <-h.stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Expected reading from the host to stop.")
+	}
+}
+
+func TestACIAClose(t *testing.T) {
+	a := NewACIA(serialHost{endless{}, io.Discard}, 1000000)
+	a.Close()
+	expectStopped(t, a.rx)
+
+	// Closing again, or closing an ACIA without a host, does nothing.
+	a.Close()
+	NewACIA(nil, 1000000).Close()
+}
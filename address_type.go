@@ -48,3 +48,17 @@ func (t AddressType) Cycles() (int8, bool, bool) {
 		return 4, false, false
 	}
 }
+
+/*
+Size returns the number of bytes taken by an instruction using the address type, including the opcode.
+*/
+func (t AddressType) Size() int8 {
+	switch t {
+	case a, impl:
+		return 1
+	case abs, absX, absY, ind:
+		return 3
+	default:
+		return 2
+	}
+}
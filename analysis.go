@@ -0,0 +1,515 @@
+package mos6502
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+/*
+ByteKind is what static analysis found a byte of an image to be.
+*/
+type ByteKind uint8
+
+/*
+The kinds of byte in an image.
+*/
+const (
+	// Not reached as code, so taken to be data.
+	ByteData ByteKind = iota
+
+	// The first byte of an instruction, and the bytes of its operand.
+	ByteOpcode
+	ByteOperand
+)
+
+/*
+EdgeKind is how control passes from one block of code to another.
+*/
+type EdgeKind uint8
+
+/*
+The ways control passes between blocks.
+*/
+const (
+	// Running on into the next block, including after a branch not taken or a return from a subroutine.
+	EdgeFall EdgeKind = iota
+
+	// A branch taken, a jump, or a subroutine call.
+	EdgeBranch
+	EdgeJump
+	EdgeCall
+
+	// A jump through a pointer. The edge goes to the pointer, as where it leads is only known when the code runs.
+	EdgeIndirect
+)
+
+/*
+String names the kind of edge.
+*/
+func (k EdgeKind) String() string {
+	switch k {
+	case EdgeFall:
+		return "fall"
+	case EdgeBranch:
+		return "branch"
+	case EdgeJump:
+		return "jump"
+	case EdgeCall:
+		return "call"
+	case EdgeIndirect:
+		return "indirect"
+	}
+	return fmt.Sprintf("EdgeKind(%d)", uint8(k))
+}
+
+/*
+Edge is a way control passes out of a block. To may be outside the image.
+*/
+type Edge struct {
+	To   Address
+	Kind EdgeKind
+}
+
+/*
+Block is a basic block: a run of instructions entered only at the top and left only at the bottom.
+*/
+type Block struct {
+	Start Address
+
+	// Address of each instruction in the block, in order.
+	Instructions []Address
+
+	// Where control can go after the last instruction.
+	Edges []Edge
+}
+
+/*
+Analysis is the result of statically disassembling an image. Code is found by recursive descent: starting from the
+entry points, instructions are followed through branches, jumps and subroutine calls until each path returns, jumps
+indirectly or runs into something that isn't code. Everything never reached is data.
+
+Undocumented opcodes end a path, as they are far more often data than code.
+*/
+type Analysis struct {
+	Origin Address
+	Image  []byte
+
+	// Names for the listing. Addresses without one get a generated label if they need one.
+	Symbols Symbols
+
+	// Where analysis started, with names for the ones taken from the vectors.
+	Entries []Address
+	vectors map[Address]string
+
+	kinds   []ByteKind
+	targets map[Address]EdgeKind
+	blocks  map[Address]*Block
+}
+
+/*
+Analyze disassembles an image loaded at origin. The entry points are the given addresses, along with the NMI, reset
+and IRQ vectors where the image covers them.
+*/
+func Analyze(image []byte, origin Address, entries ...Address) *Analysis {
+	an := &Analysis{
+		Origin:  origin,
+		Image:   image,
+		vectors: map[Address]string{},
+		kinds:   make([]ByteKind, len(image)),
+		targets: map[Address]EdgeKind{},
+		blocks:  map[Address]*Block{},
+	}
+
+	for _, v := range []struct {
+		vector Address
+		name   string
+	}{{ResetVector, "reset"}, {NMIVector, "nmi"}, {IRQVector, "irq"}} {
+		if an.contains(v.vector) && an.contains(v.vector+1) {
+			a := AddressFromBytes(an.at(v.vector+1), an.at(v.vector))
+			if _, ok := an.vectors[a]; !ok {
+				an.vectors[a] = v.name
+			}
+			entries = append(entries, a)
+		}
+	}
+
+	for _, e := range entries {
+		an.Entries = append(an.Entries, e)
+		an.trace(e)
+	}
+	an.split()
+	return an
+}
+
+/*
+contains returns true if the address lies in the image.
+*/
+func (an *Analysis) contains(a Address) bool {
+	return int(a) >= int(an.Origin) && int(a)-int(an.Origin) < len(an.Image)
+}
+
+/*
+at returns the byte of the image at an address, which must lie in it.
+*/
+func (an *Analysis) at(a Address) byte {
+	return an.Image[a-an.Origin]
+}
+
+/*
+Kind returns what the byte at an address was found to be. Addresses outside the image are data.
+*/
+func (an *Analysis) Kind(a Address) ByteKind {
+	if !an.contains(a) {
+		return ByteData
+	}
+	return an.kinds[a-an.Origin]
+}
+
+/*
+Instruction returns the instruction starting at an address, if one was found there.
+*/
+func (an *Analysis) Instruction(a Address) (Operation, bool) {
+	if an.Kind(a) != ByteOpcode {
+		return Operation{}, false
+	}
+	return an.decode(a), true
+}
+
+/*
+decode reads the instruction at an address, which along with its operand must lie in the image.
+*/
+func (an *Analysis) decode(a Address) Operation {
+	return decodeAt(an.at, a)
+}
+
+/*
+decodable returns true if an instruction can start at an address: it is documented, lies wholly in the image, and
+doesn't overlap code already found.
+*/
+func (an *Analysis) decodable(a Address) bool {
+	if !an.contains(a) || an.Kind(a) != ByteData {
+		return false
+	}
+	o := opcodes[an.at(a)]
+	if o.undocumented {
+		return false
+	}
+	for i := Address(1); i < Address(o.size); i++ {
+		if !an.contains(a+i) || a+i < a || an.Kind(a+i) != ByteData {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+trace follows the code from an entry point, marking every instruction it reaches.
+*/
+func (an *Analysis) trace(entry Address) {
+	work := []Address{entry}
+	for len(work) > 0 {
+		pc := work[len(work)-1]
+		work = work[:len(work)-1]
+
+		for an.decodable(pc) {
+			op := an.decode(pc)
+			o := opcodes[op.Code]
+			an.kinds[pc-an.Origin] = ByteOpcode
+			for i := Address(1); i < Address(o.size); i++ {
+				an.kinds[pc+i-an.Origin] = ByteOperand
+			}
+
+			next := pc + Address(o.size)
+			target, kind, falls := flow(op, pc)
+			if kind != EdgeFall && kind != EdgeIndirect {
+				if _, seen := an.targets[target]; !seen || kind == EdgeCall {
+					an.targets[target] = kind
+				}
+				work = append(work, target)
+			}
+			if !falls || next < pc {
+				break
+			}
+			pc = next
+		}
+	}
+}
+
+/*
+flow works out where control goes after the instruction at pc: a branch, jump or call target if it has one, or the
+pointer of an indirect jump, and if it can run on into the next instruction.
+*/
+func flow(op Operation, pc Address) (target Address, kind EdgeKind, falls bool) {
+	o := opcodes[op.Code]
+	switch {
+	case o.mode == rel:
+		return (pc + 2).WithOffset(op.Byte1), EdgeBranch, true
+	case o.name == "JSR":
+		return op.Full(), EdgeCall, true
+	case o.name == "JMP" && o.mode == abs:
+		return op.Full(), EdgeJump, false
+	case o.name == "JMP":
+		return op.Full(), EdgeIndirect, false
+	case o.name == "RTS", o.name == "RTI", o.name == "BRK":
+		return 0, EdgeFall, false
+	}
+	return 0, EdgeFall, true
+}
+
+/*
+split divides the code found into basic blocks and links them up.
+*/
+func (an *Analysis) split() {
+	leaders := map[Address]bool{}
+	for _, e := range an.Entries {
+		leaders[e] = true
+	}
+	for t := range an.targets {
+		leaders[t] = true
+	}
+
+	var b *Block
+	for i := range an.kinds {
+		pc := an.Origin + Address(i)
+		if an.kinds[i] != ByteOpcode {
+			continue
+		}
+		if b == nil || leaders[pc] {
+			if b != nil {
+				b.Edges = append(b.Edges, Edge{To: pc, Kind: EdgeFall})
+			}
+			b = &Block{Start: pc}
+			an.blocks[pc] = b
+		}
+		b.Instructions = append(b.Instructions, pc)
+
+		op := an.decode(pc)
+		target, kind, falls := flow(op, pc)
+		next := pc + Address(op.Size())
+		if kind != EdgeFall {
+			b.Edges = append(b.Edges, Edge{To: target, Kind: kind})
+		}
+
+		// Blocks end at any change of flow, and where the code runs into data.
+		if kind != EdgeFall || !falls || an.Kind(next) != ByteOpcode {
+			if falls && an.Kind(next) == ByteOpcode && next > pc {
+				b.Edges = append(b.Edges, Edge{To: next, Kind: EdgeFall})
+			}
+			b = nil
+		}
+	}
+}
+
+/*
+Blocks returns the basic blocks found, in order of address.
+*/
+func (an *Analysis) Blocks() []*Block {
+	blocks := make([]*Block, 0, len(an.blocks))
+	for _, b := range an.blocks {
+		blocks = append(blocks, b)
+	}
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].Start < blocks[j].Start })
+	return blocks
+}
+
+/*
+Block returns the basic block starting at an address.
+*/
+func (an *Analysis) Block(a Address) (*Block, bool) {
+	b, ok := an.blocks[a]
+	return b, ok
+}
+
+/*
+listingNames names addresses for a listing: symbols and vector names first, then a generated label for anything else
+in the image. References into the middle of an instruction are named from its start, as the label can only go there.
+*/
+type listingNames struct {
+	an     *Analysis
+	labels map[Address]string
+}
+
+func (n listingNames) Name(a uint16) (string, bool) {
+	at := Address(a)
+	if name, ok := n.labels[at]; ok {
+		return name, true
+	}
+	if n.an.Kind(at) == ByteOperand {
+		start := at
+		for n.an.Kind(start) != ByteOpcode {
+			start--
+		}
+		if name, ok := n.labels[start]; ok {
+			return fmt.Sprintf("%s+%d", name, at-start), true
+		}
+	}
+	if n.an.Symbols != nil {
+		return n.an.Symbols.Name(a)
+	}
+	return "", false
+}
+
+/*
+labels works out which addresses in the image need a label in the listing: the entry points, anything jumped to, and
+any data the code refers to by absolute address.
+*/
+func (an *Analysis) labels() map[Address]string {
+	needed := map[Address]bool{}
+	for _, e := range an.Entries {
+		needed[e] = true
+	}
+	for t := range an.targets {
+		needed[t] = true
+	}
+	for i, k := range an.kinds {
+		if k != ByteOpcode {
+			continue
+		}
+		pc := an.Origin + Address(i)
+		switch op := an.decode(pc); opcodes[op.Code].mode {
+		case abs, absX, absY, ind:
+			needed[op.Full()] = true
+		}
+	}
+
+	labels := map[Address]string{}
+	for a := range needed {
+		if !an.contains(a) {
+			continue
+		}
+		// Labels can't go in the middle of an instruction.
+		if an.Kind(a) == ByteOperand {
+			continue
+		}
+
+		if an.Symbols != nil {
+			if name, ok := an.Symbols.Name(uint16(a)); ok {
+				labels[a] = name
+				continue
+			}
+		}
+		if name, ok := an.vectors[a]; ok {
+			labels[a] = name
+		} else {
+			labels[a] = fmt.Sprintf("L%04X", uint16(a))
+		}
+	}
+	return labels
+}
+
+/*
+WriteListing writes the image as ca65 source which assembles back to the same bytes. Code is written as instructions
+with labels for its targets, and data as .byte lines, or .word for the vectors. Absolute operands in the zero page
+are forced with "a:" so they keep their size, and undocumented opcodes only appear inside data.
+*/
+func (an *Analysis) WriteListing(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	labels := an.labels()
+	names := listingNames{an: an, labels: labels}
+
+	fmt.Fprintf(bw, "; Disassembly of $%04X-$%04X\n\n", uint16(an.Origin), int(an.Origin)+len(an.Image)-1)
+	fmt.Fprintf(bw, "        .setcpu \"6502\"\n")
+
+	// Named addresses outside the image become equates so the listing assembles.
+	var external []Address
+	seen := map[Address]bool{}
+	for i, k := range an.kinds {
+		if k != ByteOpcode {
+			continue
+		}
+		pc := an.Origin + Address(i)
+		op := an.decode(pc)
+		var refs []Address
+		switch o := opcodes[op.Code]; o.mode {
+		case abs, absX, absY, ind:
+			refs = append(refs, op.Full())
+		case zpg, zpgX, zpgY, xInd, indY:
+			refs = append(refs, Address(op.Byte1))
+		case rel:
+			refs = append(refs, (pc + 2).WithOffset(op.Byte1))
+		}
+		for _, r := range refs {
+			if an.contains(r) || seen[r] || an.Symbols == nil {
+				continue
+			}
+			if _, ok := an.Symbols.Name(uint16(r)); ok {
+				seen[r] = true
+				external = append(external, r)
+			}
+		}
+	}
+	sort.Slice(external, func(i, j int) bool { return external[i] < external[j] })
+	if len(external) > 0 {
+		fmt.Fprintln(bw)
+	}
+	for _, r := range external {
+		name, _ := an.Symbols.Name(uint16(r))
+		fmt.Fprintf(bw, "%s = $%04X\n", name, uint16(r))
+	}
+
+	fmt.Fprintf(bw, "\n        .org $%04X\n", uint16(an.Origin))
+
+	var data []string
+	flush := func() {
+		if len(data) > 0 {
+			fmt.Fprintf(bw, "        .byte %s\n", strings.Join(data, ","))
+			data = data[:0]
+		}
+	}
+
+	for i := 0; i < len(an.Image); {
+		pc := an.Origin + Address(i)
+		if name, ok := labels[pc]; ok {
+			flush()
+			fmt.Fprintf(bw, "%s:\n", name)
+		}
+
+		switch {
+		case an.kinds[i] == ByteOpcode:
+			flush()
+			op := an.decode(pc)
+			text := op.DisassembleWith(pc, names)
+			switch opcodes[op.Code].mode {
+			case abs, absX, absY:
+				if op.Full() < 0x100 {
+					text = text[:4] + "a:" + text[4:]
+				}
+			}
+			fmt.Fprintf(bw, "        %s\n", text)
+			i += int(op.Size())
+
+		case an.isVector(pc) && i+1 < len(an.Image) && labels[pc+1] == "":
+			flush()
+			target := AddressFromBytes(an.Image[i+1], an.Image[i])
+			name, ok := names.Name(uint16(target))
+			if !ok {
+				name = fmt.Sprintf("$%04X", uint16(target))
+			}
+			fmt.Fprintf(bw, "        .word %s\n", name)
+			i += 2
+
+		default:
+			data = append(data, fmt.Sprintf("$%02X", an.Image[i]))
+			if len(data) == 8 {
+				flush()
+			}
+			i++
+		}
+	}
+	flush()
+	return bw.Flush()
+}
+
+/*
+isVector returns true for the first byte of one of the vectors at the top of memory, when it isn't code.
+*/
+func (an *Analysis) isVector(a Address) bool {
+	switch a {
+	case NMIVector, ResetVector, IRQVector:
+		return an.Kind(a) == ByteData && an.Kind(a+1) == ByteData
+	}
+	return false
+}
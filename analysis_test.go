@@ -0,0 +1,394 @@
+package mos6502
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+/*
+romImage is a small ROM for the top of memory: a reset routine which calls a subroutine and scans a table, a shared
+NMI and IRQ handler, and the vectors.
+*/
+var romImage = []byte{
+	0xA2, 0xFF, // FFE0 LDX #$FF
+	0x9A,             // FFE2 TXS
+	0x20, 0xF3, 0xFF, // FFE3 JSR $FFF3
+	0xA2, 0x00, // FFE6 LDX #$00
+	0xBD, 0xF4, 0xFF, // FFE8 LDA $FFF4,X
+	0xF0, 0x03, // FFEB BEQ $FFF0
+	0xE8,       // FFED INX
+	0xD0, 0xF8, // FFEE BNE $FFE8
+	0x4C, 0xF0, 0xFF, // FFF0 JMP $FFF0
+	0x60,             // FFF3 RTS
+	0x01, 0x02, 0x00, // FFF4 table
+	0x40,       // FFF7 RTI
+	0xFF, 0xFF, // FFF8 unused
+	0xF7, 0xFF, // NMI
+	0xE0, 0xFF, // reset
+	0xF7, 0xFF, // IRQ
+}
+
+func TestAnalyze(t *testing.T) {
+	an := Analyze(romImage, 0xFFE0)
+
+	var kinds = map[string]struct {
+		address Address
+		kind    ByteKind
+	}{
+		"reset":          {address: 0xFFE0, kind: ByteOpcode},
+		"operand":        {address: 0xFFE1, kind: ByteOperand},
+		"subroutine":     {address: 0xFFF3, kind: ByteOpcode},
+		"table":          {address: 0xFFF4, kind: ByteData},
+		"handler":        {address: 0xFFF7, kind: ByteOpcode},
+		"unused":         {address: 0xFFF8, kind: ByteData},
+		"vector":         {address: 0xFFFC, kind: ByteData},
+		"outside before": {address: 0xFFDF, kind: ByteData},
+	}
+
+	for k, tt := range kinds {
+		t.Run(k, func(t *testing.T) {
+			expectUint8(t, uint8(tt.kind), uint8(an.Kind(tt.address)))
+		})
+	}
+
+	var blocks = map[Address][]Edge{
+		0xFFE0: {{To: 0xFFF3, Kind: EdgeCall}, {To: 0xFFE6, Kind: EdgeFall}},
+		0xFFE6: {{To: 0xFFE8, Kind: EdgeFall}},
+		0xFFE8: {{To: 0xFFF0, Kind: EdgeBranch}, {To: 0xFFED, Kind: EdgeFall}},
+		0xFFED: {{To: 0xFFE8, Kind: EdgeBranch}, {To: 0xFFF0, Kind: EdgeFall}},
+		0xFFF0: {{To: 0xFFF0, Kind: EdgeJump}},
+		0xFFF3: nil,
+		0xFFF7: nil,
+	}
+
+	expectUint64(t, uint64(len(blocks)), uint64(len(an.Blocks())))
+	for start, edges := range blocks {
+		b, ok := an.Block(start)
+		if !ok {
+			t.Errorf("Expected a block at %04X.", start)
+			continue
+		}
+		if len(b.Edges) != len(edges) {
+			t.Errorf("Expected edges %v from %04X but got %v.", edges, start, b.Edges)
+			continue
+		}
+		for i := range edges {
+			if edges[i] != b.Edges[i] {
+				t.Errorf("Expected edges %v from %04X but got %v.", edges, start, b.Edges)
+			}
+		}
+	}
+
+	b, _ := an.Block(0xFFE8)
+	if len(b.Instructions) != 2 || b.Instructions[1] != 0xFFEB {
+		t.Errorf("Expected LDA and BEQ in the block but got %v.", b.Instructions)
+	}
+	op, ok := an.Instruction(0xFFE8)
+	expectBool(t, true, ok)
+	expectString(t, "LDA $FFF4,X", op.Disassemble(0xFFE8))
+	_, ok = an.Instruction(0xFFE9)
+	expectBool(t, false, ok)
+}
+
+func TestAnalyzeStops(t *testing.T) {
+	var tests = map[string]struct {
+		image []byte
+		code  int
+	}{
+		"undocumented":     {image: []byte{0xEA, 0xA7, 0x10}, code: 1},
+		"jam":              {image: []byte{0xEA, 0x02}, code: 1},
+		"off the end":      {image: []byte{0xEA, 0xAD, 0x00}, code: 1},
+		"indirect jump":    {image: []byte{0x6C, 0x00, 0x03, 0xEA}, code: 1},
+		"return":           {image: []byte{0x60, 0xEA}, code: 1},
+		"branch both ways": {image: []byte{0xD0, 0x01, 0xEA, 0x60}, code: 3},
+		"into an operand":  {image: []byte{0xD0, 0x01, 0xA9, 0xEA, 0x60}, code: 3},
+	}
+
+	for k, tt := range tests {
+		t.Run(k, func(t *testing.T) {
+			an := Analyze(tt.image, 0x0200, 0x0200)
+			code := 0
+			for a := Address(0x0200); a < 0x0200+Address(len(tt.image)); a++ {
+				if an.Kind(a) == ByteOpcode {
+					code++
+				}
+			}
+			expectUint64(t, uint64(tt.code), uint64(code))
+		})
+	}
+}
+
+func TestAnalysisListing(t *testing.T) {
+	var out strings.Builder
+	if err := Analyze(romImage, 0xFFE0).WriteListing(&out); err != nil {
+		t.Fatal(err)
+	}
+	expectString(t, `; Disassembly of $FFE0-$FFFF
+
+        .setcpu "6502"
+
+        .org $FFE0
+reset:
+        LDX #$FF
+        TXS
+        JSR LFFF3
+        LDX #$00
+LFFE8:
+        LDA LFFF4,X
+        BEQ LFFF0
+        INX
+        BNE LFFE8
+LFFF0:
+        JMP LFFF0
+LFFF3:
+        RTS
+LFFF4:
+        .byte $01,$02,$00
+nmi:
+        RTI
+        .byte $FF,$FF
+        .word nmi
+        .word reset
+        .word nmi
+`, out.String())
+}
+
+func TestAnalysisListingSymbols(t *testing.T) {
+	an := Analyze([]byte{
+		0xAD, 0x10, 0x00, // LDA $0010
+		0x8D, 0x12, 0xD0, // STA $D012
+		0x4C, 0x07, 0x02, // JMP $0207
+	}, 0x0200, 0x0200)
+	an.Symbols = Labels{0x0200: "start", 0xD012: "kbd"}
+
+	var out strings.Builder
+	if err := an.WriteListing(&out); err != nil {
+		t.Fatal(err)
+	}
+	expectString(t, `; Disassembly of $0200-$0208
+
+        .setcpu "6502"
+
+kbd = $D012
+
+        .org $0200
+start:
+        LDA a:$0010
+        STA kbd
+        JMP $0207
+`, out.String())
+}
+
+/*
+assemble assembles the part of ca65's syntax that listings are written in, so they can be checked to build back into
+the image they came from.
+*/
+func assemble(t *testing.T, src string) (Address, []byte) {
+	type line struct {
+		label, op, operand string
+	}
+	var lines []line
+	symbols := map[string]int{}
+	for _, text := range strings.Split(src, "\n") {
+		if i := strings.Index(text, ";"); i >= 0 {
+			text = text[:i]
+		}
+		text = strings.TrimSpace(text)
+		switch {
+		case text == "":
+		case strings.HasSuffix(text, ":"):
+			lines = append(lines, line{label: strings.TrimSuffix(text, ":")})
+		case strings.Contains(text, " = "):
+			name, value, _ := strings.Cut(text, " = ")
+			symbols[name] = parseNumber(t, value)
+		default:
+			op, operand, _ := strings.Cut(text, " ")
+			lines = append(lines, line{op: op, operand: strings.TrimSpace(operand)})
+		}
+	}
+
+	// Looks up an expression, a number or a name with an optional offset. Unknown names are taken to be forward
+	// references and assumed to be absolute, as ca65 does.
+	value := func(expr string) (int, bool) {
+		name, offset, plus := strings.Cut(expr, "+")
+		if strings.HasPrefix(name, "$") {
+			return parseNumber(t, name), len(name) > 3
+		}
+		v, ok := symbols[name]
+		if plus {
+			v += parseNumber(t, offset)
+		}
+		return v, !ok || v > 0xFF
+	}
+
+	// Works out the addressing mode and value of an operand.
+	mode := func(name, operand string) (AddressType, int) {
+		forced := strings.Contains(operand, "a:")
+		operand = strings.Replace(operand, "a:", "", 1)
+		switch {
+		case operand == "":
+			return impl, 0
+		case operand == "A":
+			return a, 0
+		case strings.HasPrefix(operand, "#"):
+			return imm, parseNumber(t, operand[1:])
+		case strings.HasSuffix(operand, ",X)"):
+			v, _ := value(operand[1 : len(operand)-3])
+			return xInd, v
+		case strings.HasSuffix(operand, "),Y"):
+			v, _ := value(operand[1 : len(operand)-3])
+			return indY, v
+		case strings.HasPrefix(operand, "("):
+			v, _ := value(operand[1 : len(operand)-1])
+			return ind, v
+		}
+
+		base, index, _ := strings.Cut(operand, ",")
+		v, wide := value(base)
+		if name[0] == 'B' && name != "BIT" && name != "BRK" {
+			return rel, v
+		}
+		wide = wide || forced || name == "JMP" || name == "JSR"
+		switch {
+		case index == "X" && wide:
+			return absX, v
+		case index == "X":
+			return zpgX, v
+		case index == "Y" && (wide || name != "LDX" && name != "STX"):
+			return absY, v
+		case index == "Y":
+			return zpgY, v
+		case wide:
+			return abs, v
+		}
+		return zpg, v
+	}
+
+	var origin Address
+	var image []byte
+	for pass := 0; pass < 2; pass++ {
+		image = image[:0]
+		for _, l := range lines {
+			pc := int(origin) + len(image)
+			switch l.op {
+			case "":
+				symbols[l.label] = pc
+			case ".setcpu":
+			case ".org":
+				origin = Address(parseNumber(t, l.operand))
+			case ".byte":
+				for _, b := range strings.Split(l.operand, ",") {
+					image = append(image, byte(parseNumber(t, b)))
+				}
+			case ".word":
+				for _, w := range strings.Split(l.operand, ",") {
+					v, _ := value(w)
+					image = append(image, byte(v), byte(v>>8))
+				}
+			default:
+				m, v := mode(l.op, l.operand)
+				code := -1
+				for c, o := range opcodes {
+					if o.name == l.op && o.mode == m && !o.undocumented {
+						code = c
+					}
+				}
+				if code < 0 {
+					t.Fatalf("No opcode for %s %s.", l.op, l.operand)
+				}
+				image = append(image, byte(code))
+				switch {
+				case m == rel:
+					image = append(image, byte(v-pc-2))
+				case opcodes[code].mode.Size() == 2:
+					image = append(image, byte(v))
+				case opcodes[code].mode.Size() == 3:
+					image = append(image, byte(v), byte(v>>8))
+				}
+			}
+		}
+	}
+	return origin, image
+}
+
+func parseNumber(t *testing.T, s string) int {
+	v, err := strconv.ParseInt(strings.TrimPrefix(s, "$"), 16, 32)
+	if err != nil {
+		t.Fatalf("Bad number %q.", s)
+	}
+	return int(v)
+}
+
+/*
+randomCode returns an image filled with random documented instructions, ending in vectors which point into it. Its
+branches and jumps land all over, giving overlapping code and references into the middle of instructions.
+*/
+func randomCode(r *rand.Rand, size int) []byte {
+	var image []byte
+	for len(image) < size-6 {
+		code := byte(r.Intn(256))
+		if opcodes[code].undocumented {
+			continue
+		}
+		image = append(image, code)
+		for i := int8(1); i < opcodes[code].size; i++ {
+			image = append(image, byte(r.Intn(256)))
+		}
+	}
+	image = image[:size-6]
+	for i := 0; i < 3; i++ {
+		image = append(image, byte(r.Intn(256)), byte(0xFC+r.Intn(4)))
+	}
+	return image
+}
+
+/*
+Test that listings assemble back into the images they came from, for real programs and for random code.
+*/
+func TestAnalysisRoundTrip(t *testing.T) {
+	images := map[string]struct {
+		image  []byte
+		origin Address
+	}{
+		"rom": {image: romImage, origin: 0xFFE0},
+	}
+	for _, name := range []string{"sieve.bin", "crc16.bin"} {
+		image, err := os.ReadFile("testdata/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		images[name] = struct {
+			image  []byte
+			origin Address
+		}{image: image, origin: 0x0200}
+	}
+
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 20; i++ {
+		image := randomCode(r, 0x400)
+		images["random "+strconv.Itoa(i)] = struct {
+			image  []byte
+			origin Address
+		}{image: image, origin: 0xFC00}
+	}
+
+	for k, tt := range images {
+		t.Run(k, func(t *testing.T) {
+			an := Analyze(tt.image, tt.origin, tt.origin)
+			var out strings.Builder
+			if err := an.WriteListing(&out); err != nil {
+				t.Fatal(err)
+			}
+
+			origin, image := assemble(t, out.String())
+			expectAddress(t, tt.origin, origin)
+			if !bytes.Equal(tt.image, image) {
+				t.Errorf("Expected the listing to assemble back to the image:\n%s", out.String())
+			}
+		})
+	}
+}
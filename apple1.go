@@ -0,0 +1,144 @@
+package mos6502
+
+import (
+	"fmt"
+	"io"
+)
+
+/*
+Where the Apple-1 places its PIA and the Woz Monitor.
+*/
+const (
+	apple1PIA     Address = 0xD010
+	apple1Monitor Address = 0xFF00
+)
+
+/*
+Apple1 is a Core wired up as an Apple-1. The PIA at $D010-$D013 bridges the keyboard and display to a terminal, the
+Woz Monitor ROM sits at $FF00 and RAM fills the rest of memory, which covers the 4KB at $0000 and the 4KB at $E000 for
+BASIC. Clock the machine by ticking Core.
+*/
+type Apple1 struct {
+	Core Core
+	PIA  *PIA
+
+	terminal *apple1Terminal
+}
+
+/*
+NewApple1 returns an Apple-1 running the given 256 byte Woz Monitor ROM, with its keyboard and display connected to
+terminal. The machine has been reset and is ready to be ticked. Without a terminal it runs with no keyboard or
+display.
+*/
+func NewApple1(monitor []byte, terminal io.ReadWriter) (*Apple1, error) {
+	if len(monitor) != 0x100 {
+		return nil, fmt.Errorf("Woz Monitor ROM should be 256 bytes, got %d", len(monitor))
+	}
+
+	m := &Apple1{PIA: NewPIA()}
+	m.terminal = &apple1Terminal{pia: m.PIA}
+	if terminal != nil {
+		m.terminal.out = terminal
+		m.terminal.in = readHost(terminal)
+	}
+
+	// The keyboard and display strobes rest low, and the display is never busy.
+	m.PIA.CA1, m.PIA.CB1 = false, false
+	m.PIA.PortB.Input = 0x00
+
+	m.Core.Bus.Map(apple1PIA, apple1PIA+3, m.PIA)
+	m.Core.Bus.Map(apple1Monitor, 0xFFFF, ROM(monitor))
+	m.Core.Attach(m.PIA)
+	m.Core.Attach(m.terminal)
+	m.Reset()
+	return m, nil
+}
+
+/*
+Reset presses the RESET button, resetting the PIA and the processor.
+*/
+func (m *Apple1) Reset() {
+	m.PIA.Reset()
+	m.Core.Reset()
+}
+
+/*
+Close stops reading keys from the terminal in the background. The terminal itself is left open.
+*/
+func (m *Apple1) Close() {
+	m.terminal.in.Close()
+}
+
+/*
+Err returns the first error from the terminal connection, other than it reaching the end of its input.
+*/
+func (m *Apple1) Err() error {
+	if m.terminal.err != nil {
+		return m.terminal.err
+	}
+	return m.terminal.in.Err()
+}
+
+/*
+apple1Terminal stands in for the Apple-1 keyboard and display hardware, moving characters between the PIA and a
+terminal.
+*/
+type apple1Terminal struct {
+	pia *PIA
+	out io.Writer
+	in  *hostInput
+	err error
+}
+
+/*
+Tick presents a key on port A with a rising edge on CA1 once the last one has been read, and takes a character off
+port B when CB2 strobes low, acknowledging it with a rising edge on CB1.
+*/
+func (t *apple1Terminal) Tick() {
+	p := t.pia
+	p.CA1, p.CB1 = false, false
+
+	if p.cra&piaIRQ1 == 0 {
+		if b, ok := t.in.poll(); ok {
+			p.PortA.Input = apple1Key(b)
+			p.CA1 = true
+		}
+	}
+
+	if !p.CB2Output() {
+		t.display(p.PortB.Pins() & 0x7F)
+		p.CB1 = true
+	}
+}
+
+/*
+display writes a character to the terminal. The Apple-1 only has a carriage return, which becomes a new line, and
+other control characters show nothing.
+*/
+func (t *apple1Terminal) display(c byte) {
+	switch {
+	case t.out == nil:
+		return
+	case c == '\r':
+		c = '\n'
+	case c < 0x20:
+		return
+	}
+	if _, err := t.out.Write([]byte{c}); err != nil && t.err == nil {
+		t.err = err
+	}
+}
+
+/*
+apple1Key converts a character from the terminal to what the Apple-1 keyboard sends: upper case ASCII with bit 7 set,
+with either line ending becoming a carriage return.
+*/
+func apple1Key(b byte) byte {
+	switch {
+	case b == '\n':
+		b = '\r'
+	case b >= 'a' && b <= 'z':
+		b -= 'a' - 'A'
+	}
+	return b | 0x80
+}
@@ -0,0 +1,93 @@
+package mos6502
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+/*
+apple1ForTest builds an Apple-1 around a monitor ROM which only holds the reset vector, with keys fed straight in
+rather than through a background reader.
+*/
+func apple1ForTest(t *testing.T, keys string, out *bytes.Buffer) *Apple1 {
+	rom := make([]byte, 0x100)
+	rom[0xFC], rom[0xFD] = 0x00, 0xFF
+
+	m, err := NewApple1(rom, serialHost{strings.NewReader(""), out})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.terminal.in = &hostInput{ch: make(chan byte, len(keys))}
+	for _, k := range []byte(keys) {
+		m.terminal.in.ch <- k
+	}
+
+	// Set up the PIA as the Woz Monitor does.
+	m.Core.Bus.Write(0xD012, 0x7F)
+	m.Core.Bus.Write(0xD011, 0xA7)
+	m.Core.Bus.Write(0xD013, 0xA7)
+	return m
+}
+
+/*
+tickApple1 ticks the devices of the machine without the processor.
+*/
+func tickApple1(m *Apple1, n int) {
+	for i := 0; i < n; i++ {
+		m.PIA.Tick()
+		m.terminal.Tick()
+	}
+}
+
+func TestNewApple1(t *testing.T) {
+	_, err := NewApple1(make([]byte, 0x80), nil)
+	expectBool(t, true, err != nil)
+
+	var out bytes.Buffer
+	m := apple1ForTest(t, "", &out)
+	expectAddress(t, 0xFF00, m.Core.PC)
+	expectBool(t, true, m.Core.Interrupt)
+
+	// The monitor cannot be written over.
+	m.Core.Bus.Write(0xFF00, 0xEA)
+	expectByte(t, 0x00, m.Core.Bus.Read(0xFF00))
+}
+
+func TestApple1Keyboard(t *testing.T) {
+	var out bytes.Buffer
+	m := apple1ForTest(t, "a\n", &out)
+
+	for _, expected := range []byte{0xC1, 0x8D} {
+		tickApple1(m, 2)
+		expectByte(t, 0x80, m.Core.Bus.Read(0xD011)&0x80)
+		expectByte(t, expected, m.Core.Bus.Read(0xD010))
+		expectByte(t, 0x00, m.Core.Bus.Read(0xD011)&0x80)
+	}
+
+	tickApple1(m, 10)
+	expectByte(t, 0x00, m.Core.Bus.Read(0xD011)&0x80)
+}
+
+func TestApple1Display(t *testing.T) {
+	var out bytes.Buffer
+	m := apple1ForTest(t, "", &out)
+
+	for _, c := range []byte("\x80HI\r") {
+		expectByte(t, 0x00, m.Core.Bus.Read(0xD012)&0x80)
+		m.Core.Bus.Write(0xD012, c|0x80)
+		tickApple1(m, 2)
+	}
+
+	expectString(t, "HI\n", out.String())
+}
+
+func TestApple1Close(t *testing.T) {
+	m, err := NewApple1(make([]byte, 0x100), serialHost{endless{}, &bytes.Buffer{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Close()
+	expectStopped(t, m.terminal.in)
+}
@@ -0,0 +1,280 @@
+package mos6502
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+/*
+programCore returns a Core with a program from testdata loaded and started at $0200.
+*/
+func programCore(tb testing.TB, name string) *Core {
+	image, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	c := &Core{PC: 0x0200, SP: 0xFF}
+	for i, b := range image {
+		c.Bus.Write(0x0200+Address(i), b)
+	}
+	return c
+}
+
+/*
+crcData fills the block the CRC program reads, returning what it should come to.
+*/
+func crcData(c *Core) uint16 {
+	crc := uint16(0xFFFF)
+	for i := 0; i < 0x1000; i++ {
+		b := byte(i*7 + i>>8)
+		c.Bus.Write(0x3000+Address(i), b)
+
+		crc ^= uint16(b) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+/*
+runProgram runs a Core until its program traps, failing if it stops for anything else.
+*/
+func runProgram(tb testing.TB, c *Core) {
+	expectLoop(tb, c, c.Run(context.Background(), Budget{Cycles: 100000000, StopOnLoop: true}))
+}
+
+/*
+expectLoop fails unless a program stopped by looping on itself.
+*/
+func expectLoop(tb testing.TB, c *Core, r StopReason) {
+	if r != StopLoop {
+		tb.Fatalf("Expected the program to loop but it stopped with \"%v\" at %04X.", r, c.PC)
+	}
+}
+
+/*
+Test that the benchmark programs get the right answers, so they time real work.
+*/
+func TestPrograms(t *testing.T) {
+	t.Run("sieve", func(t *testing.T) {
+		c := programCore(t, "sieve.bin")
+		runProgram(t, c)
+		expectUint16(t, 1028, uint16(AddressFromBytes(c.Bus.Read(0x01), c.Bus.Read(0x00))))
+	})
+
+	t.Run("crc16", func(t *testing.T) {
+		c := programCore(t, "crc16.bin")
+		crc := crcData(c)
+		runProgram(t, c)
+		expectUint16(t, crc, uint16(AddressFromBytes(c.Bus.Read(0x13), c.Bus.Read(0x12))))
+	})
+}
+
+func BenchmarkAddressing(b *testing.B) {
+	var benchmarks = map[string]Operation{
+		"accumulator": {Code: 0x0A},
+		"absolute":    {Code: 0xAD, Byte1: 0x12, Byte2: 0x34},
+		"absolute X":  {Code: 0xBD, Byte1: 0x12, Byte2: 0x34},
+		"absolute Y":  {Code: 0xB9, Byte1: 0x12, Byte2: 0x34},
+		"immediate":   {Code: 0xA9, Byte1: 0x12},
+		"implied":     {Code: 0xE8},
+		"indirect":    {Code: 0x6C, Byte1: 0x00, Byte2: 0x40},
+		"indirect X":  {Code: 0xA1, Byte1: 0x40},
+		"indirect Y":  {Code: 0xB1, Byte1: 0x40},
+		"relative":    {Code: 0xD0, Byte1: 0x00},
+		"zeropage":    {Code: 0xA5, Byte1: 0x40},
+		"zeropage X":  {Code: 0xB5, Byte1: 0x40},
+		"zeropage Y":  {Code: 0xB6, Byte1: 0x40},
+	}
+
+	for k, op := range benchmarks {
+		b.Run(k, func(b *testing.B) {
+			c := &Core{X: 0x01, Y: 0x01}
+			c.Bus.Write(0x0040, 0x00)
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				c.Execute(op)
+			}
+		})
+	}
+}
+
+func BenchmarkBusRead(b *testing.B) {
+	var benchmarks = map[string]struct {
+		bus  func() *Bus
+		addr Address
+	}{
+		"map": {
+			bus:  func() *Bus { return &Bus{data: map[Address]byte{0x1234: 0x56}} },
+			addr: 0x1234,
+		},
+		"flat": {
+			bus:  func() *Bus { b := &Bus{}; b.Write(0x1234, 0x56); return b },
+			addr: 0x1234,
+		},
+		"device": {
+			bus:  func() *Bus { b := &Bus{}; b.Map(0x0080, 0x00FF, &RIOTRAM{}); return b },
+			addr: 0x00C0,
+		},
+		"beside device": {
+			bus:  func() *Bus { b := &Bus{}; b.Write(0, 0); b.Map(0x0080, 0x00FF, &RIOTRAM{}); return b },
+			addr: 0x0040,
+		},
+	}
+
+	for k, bb := range benchmarks {
+		b.Run(k, func(b *testing.B) {
+			bus := bb.bus()
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				bus.Read(bb.addr)
+			}
+		})
+	}
+}
+
+func BenchmarkBusWrite(b *testing.B) {
+	var benchmarks = map[string]struct {
+		bus  func() *Bus
+		addr Address
+	}{
+		"flat": {
+			bus:  func() *Bus { b := &Bus{}; b.Write(0, 0); return b },
+			addr: 0x1234,
+		},
+		"device": {
+			bus:  func() *Bus { b := &Bus{}; b.Map(0x0080, 0x00FF, &RIOTRAM{}); return b },
+			addr: 0x00C0,
+		},
+		"beside device": {
+			bus:  func() *Bus { b := &Bus{}; b.Write(0, 0); b.Map(0x0080, 0x00FF, &RIOTRAM{}); return b },
+			addr: 0x0040,
+		},
+	}
+
+	for k, bb := range benchmarks {
+		b.Run(k, func(b *testing.B) {
+			bus := bb.bus()
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				bus.Write(bb.addr, byte(i))
+			}
+		})
+	}
+}
+
+/*
+benchmarkProgram times a program from testdata from start to finish, reporting the emulated clock rate reached.
+*/
+func benchmarkProgram(b *testing.B, setup func(b *testing.B) *Core) {
+	benchmarkEngine(b, setup, runProgram)
+}
+
+/*
+benchmarkEngine times a program from start to finish using the given way of running it.
+*/
+func benchmarkEngine(b *testing.B, setup func(b *testing.B) *Core, run func(tb testing.TB, c *Core)) {
+	b.ReportAllocs()
+	var cycles uint64
+	var elapsed time.Duration
+
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		c := setup(b)
+		b.StartTimer()
+
+		start := time.Now()
+		run(b, c)
+		elapsed += time.Since(start)
+		cycles += c.Cycles()
+	}
+
+	b.ReportMetric(float64(cycles)/elapsed.Seconds()/1e6, "MHz")
+}
+
+func BenchmarkSieve(b *testing.B) {
+	benchmarkProgram(b, func(b *testing.B) *Core {
+		return programCore(b, "sieve.bin")
+	})
+}
+
+func BenchmarkCRC16(b *testing.B) {
+	benchmarkProgram(b, func(b *testing.B) *Core {
+		c := programCore(b, "crc16.bin")
+		crcData(c)
+		return c
+	})
+}
+
+func BenchmarkBlockCache(b *testing.B) {
+	var benchmarks = map[string]func(b *testing.B) *Core{
+		"sieve": func(b *testing.B) *Core { return programCore(b, "sieve.bin") },
+		"crc16": func(b *testing.B) *Core {
+			c := programCore(b, "crc16.bin")
+			crcData(c)
+			return c
+		},
+	}
+
+	for k, setup := range benchmarks {
+		b.Run(k, func(b *testing.B) {
+			benchmarkEngine(b, setup, func(tb testing.TB, c *Core) {
+				expectLoop(tb, c, NewBlockCache(c).Run(context.Background(), Budget{Cycles: 100000000, StopOnLoop: true}))
+			})
+		})
+	}
+}
+
+/*
+BenchmarkDevices runs the CRC program with a full set of chips attached and mapped, and a VIA timer interrupting it
+every 100 cycles.
+*/
+func BenchmarkDevices(b *testing.B) {
+	benchmarkProgram(b, func(b *testing.B) *Core {
+		c := programCore(b, "crc16.bin")
+		crcData(c)
+
+		// The handler acknowledges the timer: PHA, LDA $9004, PLA, RTI.
+		for i, d := range []byte{0x48, 0xAD, 0x04, 0x90, 0x68, 0x40} {
+			c.Bus.Write(0x0400+Address(i), d)
+		}
+		c.Bus.Write(IRQVector, 0x00)
+		c.Bus.Write(IRQVector+1, 0x04)
+
+		via := NewVIA()
+		via.Write(viaACR, 0x40)
+		via.Write(viaIER, 0x80|viaIntT1)
+		via.Write(viaT1CL, 100)
+		via.Write(viaT1CH, 0x00)
+		c.Bus.Map(0x9000, 0x900F, via)
+		c.Attach(via)
+		c.ConnectIRQ(via)
+
+		cia := NewCIA(Clock1MHz, 60)
+		c.Bus.Map(0xDC00, 0xDC0F, cia)
+		c.Attach(cia)
+		c.ConnectIRQ(cia)
+
+		riot := NewRIOT()
+		c.Bus.Map(0x0080, 0x00FF, &riot.RAM)
+		c.Bus.Map(0x0280, 0x029F, riot)
+		c.Attach(riot)
+		c.ConnectIRQ(riot)
+
+		return c
+	})
+}
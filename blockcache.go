@@ -0,0 +1,322 @@
+package mos6502
+
+import "context"
+
+/*
+maxBlock is the most instructions decoded into one block.
+*/
+const maxBlock = 64
+
+/*
+BlockCache is an execution engine which decodes each straight run of code the first time it is reached, and keeps
+the decoded instructions by address so later passes skip fetching and decoding. Blocks end at any instruction which
+can change the flow of control. Writes to memory holding a block throw it away, so self-modifying code still behaves
+as it does under the interpreter.
+
+Code in pages with devices mapped is never cached, as reading it may have side effects or give a different answer
+each time; it runs through the interpreter instead.
+*/
+type BlockCache struct {
+	Core *Core
+
+	blocks map[Address]*block
+
+	// The blocks with code on each page, so a write only has to check those.
+	pages [0x100][]*block
+}
+
+/*
+block is a run of decoded instructions, covering the bytes from start up to but not including end. Blocks never reach
+the last byte of memory, so end is always above start.
+*/
+type block struct {
+	start Address
+	end   Address
+	code  []decoded
+
+	// Set once a write has touched the block, so a run in progress knows to stop.
+	stale bool
+}
+
+/*
+decoded is an instruction ready to run.
+*/
+type decoded struct {
+	run    func(c *Core) uint8
+	size   Address
+	cycles uint8
+}
+
+/*
+NewBlockCache returns a BlockCache running c. It watches c's Bus for writes to cached code, so only one may be used
+with a Core at a time.
+*/
+func NewBlockCache(c *Core) *BlockCache {
+	bc := &BlockCache{Core: c, blocks: map[Address]*block{}}
+	c.Bus.watcher = bc.invalidate
+	return bc
+}
+
+/*
+Flush throws away every cached block.
+*/
+func (bc *BlockCache) Flush() {
+	for p := range bc.pages {
+		bc.pages[p] = nil
+		bc.Core.Bus.watch(byte(p), false)
+	}
+	for _, b := range bc.blocks {
+		b.stale = true
+	}
+	bc.blocks = map[Address]*block{}
+}
+
+/*
+Len returns the number of blocks in the cache.
+*/
+func (bc *BlockCache) Len() int {
+	return len(bc.blocks)
+}
+
+/*
+Run executes instructions from the cache, stopping for the same reasons and at the same points as Core.Run.
+*/
+func (bc *BlockCache) Run(ctx context.Context, b Budget) StopReason {
+	c := bc.Core
+	done := ctx.Done()
+	start := c.cycles
+
+	for c.opCycles > 0 {
+		c.Tick()
+	}
+
+	var n, cancel uint64
+	for {
+		if n >= cancel && done != nil {
+			select {
+			case <-done:
+				return StopCancelled
+			default:
+			}
+			cancel = n + cancelEvery
+		}
+
+		// Trapped addresses always go through the interpreter, which runs their traps, as does everything while the
+		// Core is observed or detecting self-modifying code.
+		var blk *block
+		if len(c.observers) == 0 && c.smc == nil && (len(c.traps) == 0 || c.traps[c.PC] == nil) {
+			blk = bc.lookup(c.PC)
+		}
+		if blk == nil {
+			if r, stop := c.check(b, n, start); stop {
+				return r
+			}
+
+			pc := c.PC
+			c.Step()
+			n++
+			if r, stop := c.stuck(pc, b); stop {
+				return r
+			}
+			continue
+		}
+
+		// Without devices, interrupt lines, breakpoints, stop addresses or traps to look after, the whole block runs in a
+		// tight loop.
+		if len(c.devices) == 0 && len(c.irqs) == 0 && len(c.nmis) == 0 && len(c.breakpoints) == 0 &&
+			len(c.stops) == 0 && len(c.traps) == 0 {
+			for i := range blk.code {
+				if b.Instructions > 0 && n >= b.Instructions {
+					return StopInstructions
+				}
+				if b.Cycles > 0 && c.cycles-start >= b.Cycles {
+					return StopCycles
+				}
+
+				d := &blk.code[i]
+				pc := c.PC
+				c.PC += d.size
+				c.cycles += uint64(d.cycles + d.run(c))
+				n++
+
+				if b.StopOnLoop && c.PC == pc {
+					return StopLoop
+				}
+				if blk.stale {
+					break
+				}
+			}
+			continue
+		}
+
+		for i := range blk.code {
+			if r, stop := c.check(b, n, start); stop {
+				return r
+			}
+			if i > 0 && len(c.traps) > 0 && c.traps[c.PC] != nil {
+				break
+			}
+
+			d := &blk.code[i]
+			pc := c.PC
+			c.clock()
+			interrupted := c.serviceInterrupt()
+			if !interrupted {
+				c.PC += d.size
+				c.opCycles = d.cycles + d.run(c) - 1
+			}
+			c.finish()
+			n++
+
+			if b.StopOnLoop && c.PC == pc {
+				return StopLoop
+			}
+			if interrupted || blk.stale {
+				break
+			}
+		}
+	}
+}
+
+/*
+lookup returns the block starting at an address, decoding it if it is not cached. Returns nil where code can't be
+cached.
+*/
+func (bc *BlockCache) lookup(pc Address) *block {
+	if b, ok := bc.blocks[pc]; ok {
+		return b
+	}
+
+	bus := &bc.Core.Bus
+	b := &block{start: pc, end: pc}
+	for len(b.code) < maxBlock {
+		op, ok := bc.fetch(b.end)
+		if !ok || op.Jams() {
+			break
+		}
+
+		o := opcodes[op.Code]
+		b.code = append(b.code, decoded{run: translate(o, op), size: Address(o.size), cycles: o.cycles})
+		b.end += Address(o.size)
+
+		if endsBlock(o) {
+			break
+		}
+	}
+	if len(b.code) == 0 {
+		return nil
+	}
+
+	bc.blocks[pc] = b
+	for p := int(b.start >> 8); p <= int((b.end-1)>>8); p++ {
+		bc.pages[p] = append(bc.pages[p], b)
+		bus.watch(byte(p), true)
+	}
+	return b
+}
+
+/*
+fetch reads the instruction at an address for a block, failing if any of it lies in a page with devices or past the
+end of memory.
+*/
+func (bc *BlockCache) fetch(a Address) (Operation, bool) {
+	bus := &bc.Core.Bus
+	if bus.io[a>>8] {
+		return Operation{}, false
+	}
+	size := Address(opcodes[bus.Read(a)].size)
+	if int(a)+int(size) >= 0x10000 || bus.io[(a+size-1)>>8] {
+		return Operation{}, false
+	}
+	return decodeAt(bus.Read, a), true
+}
+
+/*
+translate turns an instruction into a closure. Operands which can't change, immediate values and fixed addresses,
+are worked out here once rather than every time the instruction runs.
+*/
+func translate(o opcode, op Operation) func(c *Core) uint8 {
+	switch o.mode {
+	case impl:
+		if f, ok := implied[o.name]; ok {
+			return func(c *Core) uint8 {
+				f(c)
+				return 0
+			}
+		}
+	case imm:
+		if f, ok := reads[o.name]; ok {
+			v := op.Byte1
+			return func(c *Core) uint8 {
+				f(c, v)
+				return 0
+			}
+		}
+	case zpg, abs:
+		a, _ := (&Core{}).effective(o.mode, op)
+		if f, ok := reads[o.name]; ok {
+			return func(c *Core) uint8 {
+				f(c, c.read(a))
+				return 0
+			}
+		}
+		if f, ok := writes[o.name]; ok {
+			return func(c *Core) uint8 {
+				c.write(a, f(c))
+				return 0
+			}
+		}
+	}
+
+	h := dispatch[op.Code]
+	return func(c *Core) uint8 {
+		return h(c, op)
+	}
+}
+
+/*
+endsBlock returns true for instructions which may not continue with the one after.
+*/
+func endsBlock(o opcode) bool {
+	switch o.name {
+	case "BRK", "JMP", "JSR", "RTI", "RTS":
+		return true
+	}
+	return o.mode == rel
+}
+
+/*
+invalidate throws away any blocks holding the byte at an address, as it is about to be written.
+*/
+func (bc *BlockCache) invalidate(a Address) {
+	p := a >> 8
+	blocks := bc.pages[p]
+	for i := 0; i < len(blocks); i++ {
+		b := blocks[i]
+		if a < b.start || a >= b.end {
+			continue
+		}
+		b.stale = true
+		delete(bc.blocks, b.start)
+		for q := int(b.start >> 8); q <= int((b.end-1)>>8); q++ {
+			bc.pages[q] = without(bc.pages[q], b)
+			if len(bc.pages[q]) == 0 {
+				bc.Core.Bus.watch(byte(q), false)
+			}
+		}
+		blocks = bc.pages[p]
+		i--
+	}
+}
+
+/*
+without returns the blocks with one taken out.
+*/
+func without(blocks []*block, b *block) []*block {
+	for i, o := range blocks {
+		if o == b {
+			return append(blocks[:i], blocks[i+1:]...)
+		}
+	}
+	return blocks
+}
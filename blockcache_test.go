@@ -0,0 +1,138 @@
+package mos6502
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+)
+
+/*
+expectSameCore fails if two Cores differ in registers, flags, cycles or memory.
+*/
+func expectSameCore(t *testing.T, expected *Core, actual *Core) {
+	t.Helper()
+	expectCore(t, expected, actual)
+	expectUint64(t, expected.Cycles(), actual.Cycles())
+	for a := 0; a < 0x10000; a++ {
+		if e, g := expected.Bus.Read(Address(a)), actual.Bus.Read(Address(a)); e != g {
+			t.Fatalf("Expected %02X at %04X but got %02X.", e, a, g)
+		}
+	}
+}
+
+/*
+differential runs the same setup under the interpreter and the block cache, comparing where they stop and the state
+they stop in.
+*/
+func differential(t *testing.T, budget Budget, setup func() *Core) *BlockCache {
+	t.Helper()
+	interpreted, cached := setup(), setup()
+	bc := NewBlockCache(cached)
+
+	expected := interpreted.Run(context.Background(), budget)
+	actual := bc.Run(context.Background(), budget)
+
+	expectString(t, expected.String(), actual.String())
+	expectSameCore(t, interpreted, cached)
+	return bc
+}
+
+func TestBlockCachePrograms(t *testing.T) {
+	t.Run("sieve", func(t *testing.T) {
+		differential(t, Budget{StopOnLoop: true}, func() *Core { return programCore(t, "sieve.bin") })
+	})
+
+	t.Run("crc16", func(t *testing.T) {
+		differential(t, Budget{StopOnLoop: true}, func() *Core {
+			c := programCore(t, "crc16.bin")
+			crcData(c)
+			return c
+		})
+	})
+
+	t.Run("budgets", func(t *testing.T) {
+		budgets := []Budget{{Instructions: 1}, {Instructions: 1000}, {Cycles: 1}, {Cycles: 12345}, {StopOnLoop: true}}
+		for _, budget := range budgets {
+			differential(t, budget, func() *Core { return programCore(t, "sieve.bin") })
+		}
+	})
+}
+
+func TestBlockCacheSelfModifying(t *testing.T) {
+	bc := differential(t, Budget{StopOnLoop: true}, func() *Core {
+		c := &Core{PC: 0x0200, SP: 0xFF}
+		for i, b := range []byte{
+			0xA9, 0xE8, // LDA #$E8 (INX)
+			0x8D, 0x07, 0x02, // STA $0207
+			0xEA,       // NOP
+			0xEA,       // NOP
+			0xEA,       // NOP, becomes INX
+			0xE0, 0x03, // CPX #$03
+			0xD0, 0xF4, // BNE $0200
+			0x4C, 0x0C, 0x02, // JMP $020C
+		} {
+			c.Bus.Write(0x0200+Address(i), b)
+		}
+		return c
+	})
+	expectByte(t, 0x03, bc.Core.X)
+}
+
+func TestBlockCacheInterrupts(t *testing.T) {
+	differential(t, Budget{StopOnLoop: true}, func() *Core {
+		c := programCore(t, "crc16.bin")
+		crcData(c)
+
+		// PHA, LDA $9004, PLA, RTI
+		for i, d := range []byte{0x48, 0xAD, 0x04, 0x90, 0x68, 0x40} {
+			c.Bus.Write(0x0400+Address(i), d)
+		}
+		c.Bus.Write(IRQVector, 0x00)
+		c.Bus.Write(IRQVector+1, 0x04)
+
+		via := NewVIA()
+		via.Write(viaACR, 0x40)
+		via.Write(viaIER, 0x80|viaIntT1)
+		via.Write(viaT1CL, 37)
+		via.Write(viaT1CH, 0x00)
+		c.Bus.Map(0x9000, 0x900F, via)
+		c.Attach(via)
+		c.ConnectIRQ(via)
+		return c
+	})
+}
+
+/*
+Test random memory as code, which jumps about, hits undocumented opcodes and overwrites itself.
+*/
+func TestBlockCacheRandom(t *testing.T) {
+	for seed := int64(0); seed < 200; seed++ {
+		differential(t, Budget{Instructions: 5000}, func() *Core {
+			r := rand.New(rand.NewSource(seed))
+			c := &Core{PC: Address(r.Intn(0x10000)), SP: byte(r.Intn(0x100))}
+			for a := 0; a < 0x10000; a++ {
+				c.Bus.Write(Address(a), byte(r.Intn(0x100)))
+			}
+			return c
+		})
+	}
+}
+
+func TestBlockCacheInvalidate(t *testing.T) {
+	c := loopCore()
+	bc := NewBlockCache(c)
+	bc.Run(context.Background(), Budget{Instructions: 10})
+	// The loop is cached from $0200, then from the branch target at $0202.
+	expectUint64(t, 2, uint64(bc.Len()))
+
+	// Writing beside the code leaves it cached, writing over it does not.
+	c.Bus.Write(0x0208, 0x00)
+	expectUint64(t, 2, uint64(bc.Len()))
+	c.Bus.Write(0x0203, 0xD0)
+	expectUint64(t, 0, uint64(bc.Len()))
+
+	bc.Run(context.Background(), Budget{Instructions: 10})
+	bc.Flush()
+	expectUint64(t, 0, uint64(bc.Len()))
+	expectBool(t, false, c.Bus.watched[0x02])
+}
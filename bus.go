@@ -4,20 +4,203 @@ package mos6502
 Bus struct which can be used to emulate a memory bus.
 */
 type Bus struct {
-	// Not sure yet.
+	// Sparse memory, from before the first write.
 	data map[Address]byte
+
+	// Flat memory, made on the first write.
+	mem *[0x10000]byte
+
+	// Devices mapped over ranges of the bus, later mappings take priority.
+	devices []mapping
+
+	// Pages with a device mapped somewhere in them, pages the watcher wants to hear about writes to, and pages the
+	// guard does.
+	io      [0x100]bool
+	watched [0x100]bool
+	guarded [0x100]bool
+
+	// Pages which can be read or written straight from flat memory, worked out from the above whenever they change.
+	fastRead  [0x100]bool
+	fastWrite [0x100]bool
+
+	// Told of writes to watched pages.
+	watcher func(a Address)
+
+	// Told of writes to guarded pages before they are made, with the value being written.
+	guard func(a Address, d byte)
+
+	// Told of every read and write with the value read or written while set, which sends them all down the slow
+	// path.
+	tracer func(a Address, d byte, write bool)
+}
+
+/*
+mapping is a device placed on the bus between start and end, inclusive.
+*/
+type mapping struct {
+	start  Address
+	end    Address
+	device Device
 }
 
 /*
 Content returns the value at a specific address on the bus.
+
+Deprecated: use Read.
 */
-func (b *Bus) Content(a Address) *byte {
-	return *(*b.data[a])
+func (b *Bus) Content(a Address) byte {
+	return b.Read(a)
 }
 
 /*
 SetContent sets the content at a specific address on the bus.
+
+Deprecated: use Write.
 */
 func (b *Bus) SetContent(a Address, d byte) {
-	b.data[a] = d
+	b.Write(a, d)
+}
+
+/*
+Map places a device on the bus between start and end, inclusive. Reads and writes in that range go to the device
+instead of memory.
+*/
+func (b *Bus) Map(start Address, end Address, d Device) {
+	b.devices = append(b.devices, mapping{start: start, end: end, device: d})
+	for p := int(start >> 8); p <= int(end>>8); p++ {
+		b.io[p] = true
+		b.refresh(byte(p))
+	}
+}
+
+/*
+Read returns the value at an address, asking a mapped device if there is one.
+*/
+func (b *Bus) Read(a Address) byte {
+	if b.fastRead[a>>8] {
+		return b.mem[a]
+	}
+	return b.slowRead(a)
+}
+
+/*
+slowRead reads from a page with devices on it, or before there is flat memory, or while accesses are observed.
+*/
+func (b *Bus) slowRead(a Address) byte {
+	var d byte
+	if m, ok := b.mapped(a); ok {
+		d = m.device.Read(a - m.start)
+	} else if b.mem != nil {
+		d = b.mem[a]
+	} else {
+		d = b.data[a]
+	}
+
+	if b.tracer != nil {
+		b.tracer(a, d, false)
+	}
+	return d
+}
+
+/*
+peek returns the value in memory at an address, without asking any device mapped there or telling anyone of the
+read.
+*/
+func (b *Bus) peek(a Address) byte {
+	if b.mem != nil {
+		return b.mem[a]
+	}
+	return b.data[a]
+}
+
+/*
+Write stores a value at an address, handing it to a mapped device if there is one.
+*/
+func (b *Bus) Write(a Address, d byte) {
+	if b.fastWrite[a>>8] {
+		b.mem[a] = d
+		return
+	}
+	b.slowWrite(a, d)
+}
+
+/*
+slowWrite writes to a page with devices on it or being watched or guarded, or while accesses are observed, or makes
+the flat memory on the first write.
+*/
+func (b *Bus) slowWrite(a Address, d byte) {
+	if b.watched[a>>8] && b.watcher != nil {
+		b.watcher(a)
+	}
+	if b.guarded[a>>8] && b.guard != nil {
+		b.guard(a, d)
+	}
+	if b.tracer != nil {
+		b.tracer(a, d, true)
+	}
+	if m, ok := b.mapped(a); ok {
+		m.device.Write(a-m.start, d)
+		return
+	}
+	if b.mem == nil {
+		b.mem = new([0x10000]byte)
+		for k, v := range b.data {
+			b.mem[k] = v
+		}
+		b.data = nil
+		for p := 0; p < 0x100; p++ {
+			b.refresh(byte(p))
+		}
+	}
+	b.mem[a] = d
+}
+
+/*
+watch has the watcher told about writes to a page, or stops it being told.
+*/
+func (b *Bus) watch(page byte, on bool) {
+	b.watched[page] = on
+	b.refresh(page)
+}
+
+/*
+protect has the guard told about writes to a page, or stops it being told.
+*/
+func (b *Bus) protect(page byte, on bool) {
+	b.guarded[page] = on
+	b.refresh(page)
+}
+
+/*
+trace has f told of every read and write with its value, or stops it being told if f is nil.
+*/
+func (b *Bus) trace(f func(a Address, d byte, write bool)) {
+	b.tracer = f
+	for p := 0; p < 0x100; p++ {
+		b.refresh(byte(p))
+	}
+}
+
+/*
+refresh works out again whether a page can be read and written straight from flat memory.
+*/
+func (b *Bus) refresh(page byte) {
+	b.fastRead[page] = b.mem != nil && !b.io[page] && b.tracer == nil
+	b.fastWrite[page] = b.fastRead[page] && !b.watched[page] && !b.guarded[page]
+}
+
+/*
+mapped finds the device mapping covering an address, if any.
+*/
+func (b *Bus) mapped(a Address) (mapping, bool) {
+	if !b.io[a>>8] {
+		return mapping{}, false
+	}
+	for i := len(b.devices) - 1; i >= 0; i-- {
+		m := b.devices[i]
+		if a >= m.start && a <= m.end {
+			return m, true
+		}
+	}
+	return mapping{}, false
 }
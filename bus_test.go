@@ -40,3 +40,31 @@ func TestWithOffset(t *testing.T) {
 		})
 	}
 }
+
+func TestBusMemory(t *testing.T) {
+	b := Bus{data: map[Address]byte{0x1234: 0x56}}
+	expectByte(t, 0x56, b.Read(0x1234))
+
+	// The first write moves everything into flat memory.
+	b.Write(0x0000, 0x01)
+	expectByte(t, 0x56, b.Read(0x1234))
+	expectByte(t, 0x01, b.Read(0x0000))
+	expectBool(t, true, b.data == nil)
+}
+
+func TestBusDevicePages(t *testing.T) {
+	var b Bus
+	b.Write(0xD00F, 0x11)
+	b.Write(0xD020, 0x22)
+
+	rom := ROM{0xAA, 0xBB}
+	b.Map(0xD010, 0xD01F, rom)
+
+	// Addresses sharing a page with the device still reach memory.
+	expectByte(t, 0x11, b.Read(0xD00F))
+	expectByte(t, 0x22, b.Read(0xD020))
+	expectByte(t, 0xBB, b.Read(0xD011))
+
+	b.Write(0xD010, 0x00)
+	expectByte(t, 0xAA, b.Read(0xD010))
+}
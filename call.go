@@ -0,0 +1,85 @@
+package mos6502
+
+import (
+	"context"
+	"fmt"
+)
+
+/*
+CallReturn is where a routine run by Call returns to. Call pushes the address before it, as JSR would, and the call is
+over when the routine's RTS brings the processor there with the stack back where it started.
+*/
+const CallReturn Address = 0x0000
+
+/*
+DefaultCallCycles is the cycle budget Call gives a routine, ten seconds of a 1MHz processor.
+*/
+const DefaultCallCycles = 10000000
+
+/*
+CallError is returned when a called routine stops before it returns: the reason it stopped and where.
+*/
+type CallError struct {
+	Reason StopReason
+	PC     Address
+}
+
+func (e *CallError) Error() string {
+	return fmt.Sprintf("mos6502: call stopped with %s at $%04X", e.Reason, uint16(e.PC))
+}
+
+/*
+Call runs the subroutine at entry as if it had been called with JSR, returning the registers it leaves and the cycles
+it took. Any registers given are set first, other than the program counter. Calls are given DefaultCallCycles to
+return.
+*/
+func (c *Core) Call(entry Address, in ...Registers) (Registers, uint64, error) {
+	return c.CallContext(context.Background(), Budget{Cycles: DefaultCallCycles}, entry, in...)
+}
+
+/*
+CallContext is Call with a context and budget. The routine is stopped with a CallError if it uses up the budget, ctx
+is cancelled, or it stops for any of the other reasons Run would before it returns. Nested calls which pass through
+CallReturn on the way don't end the call, as the stack is still deeper than it started.
+*/
+func (c *Core) CallContext(ctx context.Context, b Budget, entry Address, in ...Registers) (Registers, uint64, error) {
+	for _, r := range in {
+		c.SetRegisters(r)
+	}
+
+	// RTS adds one to the address it pulls.
+	back := CallReturn
+	back--
+	sp := c.SP
+	c.push(byte(back >> 8))
+	c.push(byte(back))
+	c.PC = entry
+
+	done := ctx.Done()
+	start := c.cycles
+	stop := func(r StopReason) (Registers, uint64, error) {
+		return c.Registers(), c.cycles - start, &CallError{Reason: r, PC: c.PC}
+	}
+
+	for n := uint64(0); ; n++ {
+		if n%cancelEvery == 0 && done != nil {
+			select {
+			case <-done:
+				return stop(StopCancelled)
+			default:
+			}
+		}
+		if r, halt := c.check(b, n, start); halt {
+			return stop(r)
+		}
+
+		pc := c.PC
+		c.Step()
+		if c.PC == CallReturn && c.SP == sp {
+			return c.Registers(), c.cycles - start, nil
+		}
+		if r, halt := c.stuck(pc, b); halt {
+			return stop(r)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package mos6502
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCall(t *testing.T) {
+	c := profileCore(map[Address][]byte{
+		0x0300: {
+			0x20, 0x08, 0x03, // 0300 JSR $0308
+			0x86, 0x10, // 0303 STX $10
+			0x98, // 0305 TYA
+			0x60, // 0306 RTS
+			0x00, // 0307
+			0xE8, // 0308 INX
+			0xC8, // 0309 INY
+			0x60, // 030A RTS
+		},
+	})
+	c.PC = 0x1234
+
+	regs, cycles, err := c.Call(0x0300, Registers{X: 0x41, Y: 0x7F, SP: 0xF0, P: 0x01})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectUint64(t, 6+2+2+6+3+2+6, cycles)
+	expectAddress(t, CallReturn, regs.PC)
+	expectByte(t, 0x80, regs.AC)
+	expectByte(t, 0x42, regs.X)
+	expectByte(t, 0x80, regs.Y)
+	expectByte(t, 0xF0, regs.SP)
+	expectByte(t, 0xA1, regs.P)
+	expectByte(t, 0x42, c.Bus.Read(0x10))
+	expectUint64(t, cycles, c.Cycles())
+}
+
+func TestCallNested(t *testing.T) {
+	// Counts X down calling itself, so it passes through the return address with the stack still deep.
+	c := profileCore(map[Address][]byte{
+		0x0000: {0x60}, // 0000 RTS, never run
+		0x0200: {
+			0xE0, 0x00, // 0200 CPX #$00
+			0xF0, 0x04, // 0202 BEQ $0208
+			0xCA,             // 0204 DEX
+			0x20, 0x00, 0x02, // 0205 JSR $0200
+			0xC8, // 0208 INY
+			0x60, // 0209 RTS
+		},
+	})
+
+	regs, _, err := c.Call(0x0200, Registers{X: 3, SP: 0xFF})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectByte(t, 4, regs.Y)
+	expectByte(t, 0xFF, regs.SP)
+}
+
+func TestCallStops(t *testing.T) {
+	var tests = map[string]struct {
+		code   []byte
+		budget Budget
+		reason StopReason
+		pc     Address
+	}{
+		"budget": {code: []byte{0xEA, 0x4C, 0x00, 0x02}, budget: Budget{Cycles: 20}, reason: StopCycles, pc: 0x0200},
+		"loop": {
+			code: []byte{0xEA, 0x4C, 0x01, 0x02}, budget: Budget{StopOnLoop: true}, reason: StopLoop, pc: 0x0201,
+		},
+		"jam": {code: []byte{0xEA, 0x02}, reason: StopJam, pc: 0x0201},
+	}
+
+	for k, tt := range tests {
+		t.Run(k, func(t *testing.T) {
+			c := profileCore(map[Address][]byte{0x0200: tt.code})
+			_, _, err := c.CallContext(context.Background(), tt.budget, 0x0200)
+
+			var ce *CallError
+			if !errors.As(err, &ce) {
+				t.Fatalf("Expected a CallError but got %v.", err)
+			}
+			expectString(t, tt.reason.String(), ce.Reason.String())
+			expectAddress(t, tt.pc, ce.PC)
+		})
+	}
+
+	t.Run("cancelled", func(t *testing.T) {
+		c := profileCore(map[Address][]byte{0x0200: {0xEA, 0x4C, 0x00, 0x02}})
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, _, err := c.CallContext(ctx, Budget{}, 0x0200)
+		expectString(t, "mos6502: call stopped with cancelled at $0200", err.Error())
+	})
+}
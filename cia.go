@@ -0,0 +1,535 @@
+package mos6502
+
+/*
+CIA registers, as offsets from where the chip is mapped. Only four address lines are decoded, so the registers repeat
+every 16 bytes.
+*/
+const (
+	ciaPRA Address = iota
+	ciaPRB
+	ciaDDRA
+	ciaDDRB
+	ciaTALo
+	ciaTAHi
+	ciaTBLo
+	ciaTBHi
+	ciaTODTenths
+	ciaTODSec
+	ciaTODMin
+	ciaTODHr
+	ciaSDR
+	ciaICR
+	ciaCRA
+	ciaCRB
+)
+
+/*
+CIA interrupt sources, as laid out in the ICR.
+*/
+const (
+	ciaIntTA byte = 1 << iota
+	ciaIntTB
+	ciaIntAlarm
+	ciaIntSP
+	ciaIntFlag
+	ciaIntIR byte = 0x80
+)
+
+/*
+CIA control register bits. Start, PB output, output mode, run mode and load are the same for both timers.
+*/
+const (
+	ciaStart   byte = 0x01
+	ciaPBOn    byte = 0x02
+	ciaToggle  byte = 0x04
+	ciaOneShot byte = 0x08
+	ciaLoad    byte = 0x10
+	ciaCNTIn   byte = 0x20
+	ciaSPOut   byte = 0x40
+	ciaTOD50Hz byte = 0x80
+	ciaAlarm   byte = 0x80
+)
+
+/*
+CIA emulates the MOS 6526 Complex Interface Adapter: two 8-bit ports, two interval timers which can be chained, a
+time-of-day clock with an alarm, a serial shift register and interrupt masking through the ICR. Its interrupt output
+can be wired to either Core.ConnectIRQ or Core.ConnectNMI, as the two CIAs in a Commodore 64 are.
+*/
+type CIA struct {
+	PortA Port
+	PortB Port
+
+	// Levels on the CNT, SP and FLAG inputs, driven from outside the chip.
+	CNT  bool
+	SP   bool
+	FLAG bool
+
+	// Frequency of the clock the chip is ticked with, and of the mains signal on the TOD pin.
+	ClockHz uint64
+	TODHz   uint64
+
+	icr  byte
+	mask byte
+	cra  byte
+	crb  byte
+
+	// Timers, their latches and the state of their PB6 and PB7 outputs.
+	ta      uint16
+	taLatch uint16
+	tb      uint16
+	tbLatch uint16
+	taOut   bool
+	tbOut   bool
+	taPulse bool
+	tbPulse bool
+
+	// Time of day in BCD as tenths, seconds, minutes and hours, with the alarm and the copy latched for reading.
+	tod        [4]byte
+	alarm      [4]byte
+	latch      [4]byte
+	latched    bool
+	todStopped bool
+	todWait    uint64
+	todPulses  byte
+
+	// Serial data register, the shift register behind it and the bits left to shift.
+	sdr     byte
+	shift   byte
+	bits    uint8
+	pending bool
+	spOut   bool
+	cntOut  bool
+
+	cntLast  bool
+	flagLast bool
+}
+
+/*
+NewCIA returns a CIA in its reset state, ticked at clockHz with a todHz mains signal on its TOD pin. The port and
+control line inputs are pulled high.
+*/
+func NewCIA(clockHz uint64, todHz uint64) *CIA {
+	c := &CIA{
+		PortA:   Port{Input: 0xFF},
+		PortB:   Port{Input: 0xFF},
+		CNT:     true,
+		SP:      true,
+		FLAG:    true,
+		ClockHz: clockHz,
+		TODHz:   todHz,
+	}
+	c.Reset()
+	return c
+}
+
+/*
+Reset clears the registers as the RES line does. The timer latches are set high and the clock starts from 1:00:00.0
+AM.
+*/
+func (c *CIA) Reset() {
+	c.PortA.setData(0)
+	c.PortA.setDDR(0)
+	c.PortB.setData(0)
+	c.PortB.setDDR(0)
+	c.icr, c.mask, c.cra, c.crb = 0, 0, 0, 0
+	c.ta, c.taLatch, c.tb, c.tbLatch = 0xFFFF, 0xFFFF, 0xFFFF, 0xFFFF
+	c.taOut, c.tbOut, c.taPulse, c.tbPulse = false, false, false, false
+	c.tod = [4]byte{0x00, 0x00, 0x00, 0x01}
+	c.alarm = [4]byte{}
+	c.latched, c.todStopped = false, false
+	c.todWait, c.todPulses = c.todCycles(), 0
+	c.sdr, c.shift, c.bits, c.pending = 0, 0, 0, false
+	c.spOut, c.cntOut = true, true
+	c.cntLast, c.flagLast = c.CNT, c.FLAG
+	c.updatePB()
+}
+
+/*
+Read returns the value of a register. Reading the ICR clears every flag, and reading the hours latches the clock
+until the tenths are read.
+*/
+func (c *CIA) Read(a Address) byte {
+	switch a & 0x0F {
+	case ciaPRA:
+		return c.PortA.Pins()
+	case ciaPRB:
+		return c.PortB.Pins()
+	case ciaDDRA:
+		return c.PortA.DDR
+	case ciaDDRB:
+		return c.PortB.DDR
+	case ciaTALo:
+		return byte(c.ta)
+	case ciaTAHi:
+		return byte(c.ta >> 8)
+	case ciaTBLo:
+		return byte(c.tb)
+	case ciaTBHi:
+		return byte(c.tb >> 8)
+	case ciaTODTenths:
+		if !c.latched {
+			return c.tod[0]
+		}
+		c.latched = false
+		return c.latch[0]
+	case ciaTODSec, ciaTODMin, ciaTODHr:
+		i := a&0x0F - ciaTODTenths
+		if a&0x0F == ciaTODHr && !c.latched {
+			c.latch = c.tod
+			c.latched = true
+		}
+		if c.latched {
+			return c.latch[i]
+		}
+		return c.tod[i]
+	case ciaSDR:
+		return c.sdr
+	case ciaICR:
+		icr := c.icr
+		if c.Interrupting() {
+			icr |= ciaIntIR
+		}
+		c.icr = 0
+		return icr
+	case ciaCRA:
+		return c.cra
+	default:
+		return c.crb
+	}
+}
+
+/*
+Write stores a value in a register. Writing the ICR sets or clears mask bits depending on bit 7, and writing the
+clock sets the alarm instead while CRB bit 7 is set.
+*/
+func (c *CIA) Write(a Address, d byte) {
+	switch a & 0x0F {
+	case ciaPRA:
+		c.PortA.setData(d)
+	case ciaPRB:
+		c.PortB.setData(d)
+	case ciaDDRA:
+		c.PortA.setDDR(d)
+	case ciaDDRB:
+		c.PortB.setDDR(d)
+	case ciaTALo:
+		c.taLatch = (c.taLatch & 0xFF00) | uint16(d)
+	case ciaTAHi:
+		c.taLatch = (c.taLatch & 0x00FF) | uint16(d)<<8
+		if c.cra&ciaStart == 0 {
+			c.ta = c.taLatch
+		}
+	case ciaTBLo:
+		c.tbLatch = (c.tbLatch & 0xFF00) | uint16(d)
+	case ciaTBHi:
+		c.tbLatch = (c.tbLatch & 0x00FF) | uint16(d)<<8
+		if c.crb&ciaStart == 0 {
+			c.tb = c.tbLatch
+		}
+	case ciaTODTenths, ciaTODSec, ciaTODMin, ciaTODHr:
+		c.writeTOD(a&0x0F-ciaTODTenths, d)
+	case ciaSDR:
+		c.sdr = d
+		if c.cra&ciaSPOut != 0 {
+			c.pending = true
+		}
+	case ciaICR:
+		if d&0x80 != 0 {
+			c.mask |= d & 0x1F
+		} else {
+			c.mask &^= d & 0x1F
+		}
+	case ciaCRA:
+		if d&ciaStart != 0 && c.cra&ciaStart == 0 {
+			c.taOut = true
+		}
+		if d&ciaLoad != 0 {
+			c.ta = c.taLatch
+		}
+		if (d^c.cra)&ciaSPOut != 0 {
+			c.bits, c.pending = 0, false
+		}
+		c.cra = d &^ ciaLoad
+		c.updatePB()
+	default:
+		if d&ciaStart != 0 && c.crb&ciaStart == 0 {
+			c.tbOut = true
+		}
+		if d&ciaLoad != 0 {
+			c.tb = c.tbLatch
+		}
+		c.crb = d &^ ciaLoad
+		c.updatePB()
+	}
+}
+
+/*
+Tick advances the chip by one cycle of the system clock.
+*/
+func (c *CIA) Tick() {
+	cntRising := c.CNT && !c.cntLast
+	c.cntLast = c.CNT
+
+	if !c.FLAG && c.flagLast {
+		c.icr |= ciaIntFlag
+	}
+	c.flagLast = c.FLAG
+
+	if c.taPulse || c.tbPulse {
+		c.taPulse, c.tbPulse = false, false
+		c.updatePB()
+	}
+
+	underflowA := false
+	if c.cra&ciaStart != 0 && (c.cra&ciaCNTIn == 0 || cntRising) {
+		underflowA = c.countA()
+	}
+
+	var countB bool
+	switch (c.crb >> 5) & 0x03 {
+	case 0:
+		countB = true
+	case 1:
+		countB = cntRising
+	case 2:
+		countB = underflowA
+	default:
+		countB = underflowA && c.CNT
+	}
+	if c.crb&ciaStart != 0 && countB {
+		c.countB()
+	}
+
+	if c.cra&ciaSPOut == 0 && cntRising {
+		c.shiftIn()
+	}
+
+	c.tickTOD()
+}
+
+/*
+Interrupting reports if the IRQ output is asserted, which it is while any source unmasked in the ICR is flagged.
+*/
+func (c *CIA) Interrupting() bool {
+	return c.icr&c.mask&0x1F != 0
+}
+
+/*
+SPOutput returns the level the chip drives on SP while the serial port is shifting out.
+*/
+func (c *CIA) SPOutput() bool {
+	return c.spOut
+}
+
+/*
+CNTOutput returns the level the chip drives on CNT while the serial port is shifting out.
+*/
+func (c *CIA) CNTOutput() bool {
+	return c.cntOut
+}
+
+/*
+countA counts timer A down once, reloading it when it underflows. Returns true on an underflow, which also clocks
+the serial port when it is shifting out.
+*/
+func (c *CIA) countA() bool {
+	if c.ta > 0 {
+		c.ta--
+		return false
+	}
+
+	c.ta = c.taLatch
+	c.icr |= ciaIntTA
+	if c.cra&ciaOneShot != 0 {
+		c.cra &^= ciaStart
+	}
+	c.taOut = !c.taOut
+	c.taPulse = true
+	c.updatePB()
+
+	if c.cra&ciaSPOut != 0 {
+		c.shiftOut()
+	}
+	return true
+}
+
+/*
+countB counts timer B down once, reloading it when it underflows.
+*/
+func (c *CIA) countB() {
+	if c.tb > 0 {
+		c.tb--
+		return
+	}
+
+	c.tb = c.tbLatch
+	c.icr |= ciaIntTB
+	if c.crb&ciaOneShot != 0 {
+		c.crb &^= ciaStart
+	}
+	c.tbOut = !c.tbOut
+	c.tbPulse = true
+	c.updatePB()
+}
+
+/*
+updatePB hands PB6 and PB7 to the timers while their control registers ask for it, either toggling on every
+underflow or pulsing high for a cycle.
+*/
+func (c *CIA) updatePB() {
+	var mask, levels byte
+	if c.cra&ciaPBOn != 0 {
+		mask |= 0x40
+		if (c.cra&ciaToggle != 0 && c.taOut) || (c.cra&ciaToggle == 0 && c.taPulse) {
+			levels |= 0x40
+		}
+	}
+	if c.crb&ciaPBOn != 0 {
+		mask |= 0x80
+		if (c.crb&ciaToggle != 0 && c.tbOut) || (c.crb&ciaToggle == 0 && c.tbPulse) {
+			levels |= 0x80
+		}
+	}
+	c.PortB.force(mask, levels)
+}
+
+/*
+shiftOut moves the serial port along half a bit on a timer A underflow. CNT falls as each bit is put on SP and rises
+halfway through it, and once eight bits have gone the next waiting byte is taken.
+*/
+func (c *CIA) shiftOut() {
+	if c.bits == 0 {
+		if !c.pending {
+			return
+		}
+		c.shift, c.bits, c.pending = c.sdr, 8, false
+	}
+
+	if c.cntOut {
+		c.cntOut = false
+		c.spOut = c.shift&0x80 != 0
+		c.shift <<= 1
+		return
+	}
+
+	c.cntOut = true
+	c.bits--
+	if c.bits == 0 {
+		c.icr |= ciaIntSP
+	}
+}
+
+/*
+shiftIn takes a bit from SP on a rising edge of CNT, and once eight have arrived moves them to the data register.
+*/
+func (c *CIA) shiftIn() {
+	c.shift <<= 1
+	if c.SP {
+		c.shift |= 0x01
+	}
+	c.bits++
+	if c.bits == 8 {
+		c.sdr = c.shift
+		c.bits = 0
+		c.icr |= ciaIntSP
+	}
+}
+
+/*
+tickTOD counts cycles towards the next pulse on the TOD pin, and counts pulses towards the next tenth of a second.
+*/
+func (c *CIA) tickTOD() {
+	if c.TODHz == 0 {
+		return
+	}
+	if c.todWait > 1 {
+		c.todWait--
+		return
+	}
+	c.todWait = c.todCycles()
+
+	c.todPulses++
+	per := byte(6)
+	if c.cra&ciaTOD50Hz != 0 {
+		per = 5
+	}
+	if c.todPulses < per {
+		return
+	}
+	c.todPulses = 0
+
+	if c.todStopped {
+		return
+	}
+	c.advanceTOD()
+	if c.tod == c.alarm {
+		c.icr |= ciaIntAlarm
+	}
+}
+
+/*
+advanceTOD moves the clock on a tenth of a second, carrying through seconds and minutes into the 12 hour clock.
+*/
+func (c *CIA) advanceTOD() {
+	if c.tod[0] = (c.tod[0] + 1) & 0x0F; c.tod[0] < 0x0A {
+		return
+	}
+	c.tod[0] = 0
+
+	for i := 1; i <= 2; i++ {
+		if c.tod[i] = bcdIncrement(c.tod[i]); c.tod[i] < 0x60 {
+			return
+		}
+		c.tod[i] = 0
+	}
+
+	pm := c.tod[3] & 0x80
+	hr := bcdIncrement(c.tod[3] & 0x1F)
+	switch hr {
+	case 0x12:
+		pm ^= 0x80
+	case 0x13:
+		hr = 0x01
+	}
+	c.tod[3] = pm | hr
+}
+
+/*
+writeTOD sets a register of the clock, or of the alarm while CRB bit 7 is set. Writing the hours stops the clock until
+the tenths are written, so it can be set without carrying part way through.
+*/
+func (c *CIA) writeTOD(i Address, d byte) {
+	d &= [4]byte{0x0F, 0x7F, 0x7F, 0x9F}[i]
+	if c.crb&ciaAlarm != 0 {
+		c.alarm[i] = d
+		return
+	}
+
+	c.tod[i] = d
+	switch i {
+	case 0:
+		c.todStopped = false
+	case 3:
+		c.todStopped = true
+	}
+}
+
+/*
+todCycles returns the number of clock cycles between pulses on the TOD pin.
+*/
+func (c *CIA) todCycles() uint64 {
+	if c.TODHz == 0 || c.ClockHz <= c.TODHz {
+		return 1
+	}
+	return c.ClockHz / c.TODHz
+}
+
+/*
+bcdIncrement adds one to a two digit BCD number.
+*/
+func bcdIncrement(b byte) byte {
+	if b&0x0F < 0x09 {
+		return b + 1
+	}
+	return (b & 0xF0) + 0x10
+}
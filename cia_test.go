@@ -0,0 +1,217 @@
+package mos6502
+
+import "testing"
+
+func tickCIA(c *CIA, n int) {
+	for i := 0; i < n; i++ {
+		c.Tick()
+	}
+}
+
+func TestCIATimerA(t *testing.T) {
+	var tests = map[string]struct {
+		cra     byte
+		ticks   int
+		flagged bool
+		counter uint16
+		running bool
+	}{
+		"counting":             {cra: 0x01, ticks: 3, flagged: false, counter: 0x0001, running: true},
+		"underflow reloads":    {cra: 0x01, ticks: 5, flagged: true, counter: 0x0004, running: true},
+		"continuous":           {cra: 0x01, ticks: 10, flagged: true, counter: 0x0004, running: true},
+		"one-shot stops":       {cra: 0x09, ticks: 10, flagged: true, counter: 0x0004, running: false},
+		"stopped":              {cra: 0x00, ticks: 10, flagged: false, counter: 0x0004, running: false},
+		"counting CNT instead": {cra: 0x21, ticks: 10, flagged: false, counter: 0x0004, running: true},
+	}
+
+	for k, tt := range tests {
+		t.Run(k, func(t *testing.T) {
+			c := NewCIA(1000000, 0)
+			c.Write(ciaTALo, 0x04)
+			c.Write(ciaTAHi, 0x00)
+			c.Write(ciaCRA, tt.cra)
+
+			tickCIA(c, tt.ticks)
+
+			expectBool(t, tt.flagged, c.Read(ciaICR)&ciaIntTA != 0)
+			expectUint16(t, tt.counter, uint16(AddressFromBytes(c.Read(ciaTAHi), c.Read(ciaTALo))))
+			expectBool(t, tt.running, c.Read(ciaCRA)&ciaStart != 0)
+		})
+	}
+}
+
+func TestCIAChainedTimers(t *testing.T) {
+	c := NewCIA(1000000, 0)
+	c.Write(ciaTALo, 0x01)
+	c.Write(ciaTAHi, 0x00)
+	c.Write(ciaTBLo, 0x02)
+	c.Write(ciaTBHi, 0x00)
+
+	// Timer B counts timer A underflows, which come every other cycle.
+	c.Write(ciaCRB, 0x41)
+	c.Write(ciaCRA, 0x01)
+
+	tickCIA(c, 5)
+	expectByte(t, ciaIntTA, c.Read(ciaICR))
+	tickCIA(c, 1)
+	expectByte(t, ciaIntTA|ciaIntTB, c.Read(ciaICR))
+}
+
+func TestCIAInterruptMask(t *testing.T) {
+	c := NewCIA(1000000, 0)
+	c.Write(ciaTALo, 0x00)
+	c.Write(ciaTAHi, 0x00)
+	c.Write(ciaCRA, 0x09)
+	c.Tick()
+	expectBool(t, false, c.Interrupting())
+
+	c.Write(ciaICR, 0x80|ciaIntTA)
+	expectBool(t, true, c.Interrupting())
+
+	// Reading the ICR clears the flags, and IR shows the interrupt was asserted.
+	expectByte(t, ciaIntIR|ciaIntTA, c.Read(ciaICR))
+	expectBool(t, false, c.Interrupting())
+	expectByte(t, 0x00, c.Read(ciaICR))
+
+	c.FLAG = false
+	c.Tick()
+	expectBool(t, false, c.Interrupting())
+	c.Write(ciaICR, 0x80|ciaIntFlag)
+	c.Write(ciaICR, ciaIntTA)
+	expectBool(t, true, c.Interrupting())
+}
+
+func TestCIAPB6(t *testing.T) {
+	var tests = map[string]struct {
+		cra    byte
+		levels []bool
+	}{
+		"pulse":  {cra: 0x03, levels: []bool{false, false, true, false, true}},
+		"toggle": {cra: 0x07, levels: []bool{true, true, false, false, true}},
+	}
+
+	for k, tt := range tests {
+		t.Run(k, func(t *testing.T) {
+			c := NewCIA(1000000, 0)
+			c.Write(ciaTALo, 0x01)
+			c.Write(ciaTAHi, 0x00)
+			c.Write(ciaCRA, tt.cra)
+
+			for i, level := range tt.levels {
+				if i > 0 {
+					c.Tick()
+				}
+				expectBool(t, level, c.PortB.Pins()&0x40 != 0)
+			}
+		})
+	}
+}
+
+func TestCIATimeOfDay(t *testing.T) {
+	var tests = map[string]struct {
+		start  [4]byte
+		tenths int
+		end    [4]byte
+	}{
+		"tenth":       {start: [4]byte{0x00, 0x00, 0x00, 0x01}, tenths: 1, end: [4]byte{0x01, 0x00, 0x00, 0x01}},
+		"second":      {start: [4]byte{0x09, 0x09, 0x00, 0x01}, tenths: 1, end: [4]byte{0x00, 0x10, 0x00, 0x01}},
+		"minute":      {start: [4]byte{0x09, 0x59, 0x00, 0x01}, tenths: 1, end: [4]byte{0x00, 0x00, 0x01, 0x01}},
+		"hour":        {start: [4]byte{0x09, 0x59, 0x59, 0x09}, tenths: 1, end: [4]byte{0x00, 0x00, 0x00, 0x10}},
+		"noon":        {start: [4]byte{0x09, 0x59, 0x59, 0x11}, tenths: 1, end: [4]byte{0x00, 0x00, 0x00, 0x92}},
+		"one pm":      {start: [4]byte{0x09, 0x59, 0x59, 0x92}, tenths: 1, end: [4]byte{0x00, 0x00, 0x00, 0x81}},
+		"midnight":    {start: [4]byte{0x09, 0x59, 0x59, 0x91}, tenths: 1, end: [4]byte{0x00, 0x00, 0x00, 0x12}},
+		"ten seconds": {start: [4]byte{0x00, 0x00, 0x00, 0x01}, tenths: 100, end: [4]byte{0x00, 0x10, 0x00, 0x01}},
+	}
+
+	for k, tt := range tests {
+		t.Run(k, func(t *testing.T) {
+			// A 60Hz signal on a 600Hz clock gives a tenth every 60 cycles.
+			c := NewCIA(600, 60)
+			for i := 3; i >= 0; i-- {
+				c.Write(ciaTODTenths+Address(i), tt.start[i])
+			}
+
+			tickCIA(c, 60*tt.tenths)
+
+			for i := 3; i >= 0; i-- {
+				expectByte(t, tt.end[i], c.Read(ciaTODTenths+Address(i)))
+			}
+		})
+	}
+}
+
+func TestCIATimeOfDayLatchAndStop(t *testing.T) {
+	c := NewCIA(600, 50)
+	c.Write(ciaCRA, ciaTOD50Hz)
+
+	// Writing the hours stops the clock until the tenths are written.
+	c.Write(ciaTODHr, 0x01)
+	tickCIA(c, 120)
+	expectByte(t, 0x00, c.Read(ciaTODTenths))
+	c.Write(ciaTODTenths, 0x00)
+	tickCIA(c, 60)
+	expectByte(t, 0x01, c.Read(ciaTODTenths))
+
+	// Reading the hours latches the clock until the tenths are read.
+	expectByte(t, 0x01, c.Read(ciaTODHr))
+	tickCIA(c, 600)
+	expectByte(t, 0x00, c.Read(ciaTODSec))
+	expectByte(t, 0x01, c.Read(ciaTODTenths))
+	expectByte(t, 0x01, c.Read(ciaTODSec))
+}
+
+func TestCIAAlarm(t *testing.T) {
+	c := NewCIA(600, 60)
+	c.Write(ciaICR, 0x80|ciaIntAlarm)
+
+	c.Write(ciaCRB, ciaAlarm)
+	c.Write(ciaTODHr, 0x01)
+	c.Write(ciaTODMin, 0x00)
+	c.Write(ciaTODSec, 0x01)
+	c.Write(ciaTODTenths, 0x00)
+	c.Write(ciaCRB, 0x00)
+
+	// Setting the alarm leaves the clock alone.
+	expectByte(t, 0x00, c.Read(ciaTODSec))
+	expectByte(t, 0x00, c.Read(ciaTODTenths))
+
+	tickCIA(c, 60*9)
+	expectBool(t, false, c.Interrupting())
+	tickCIA(c, 60)
+	expectBool(t, true, c.Interrupting())
+}
+
+func TestCIASerialPort(t *testing.T) {
+	c := NewCIA(1000000, 0)
+	c.Write(ciaTALo, 0x00)
+	c.Write(ciaTAHi, 0x00)
+	c.Write(ciaCRA, ciaStart|ciaSPOut)
+	c.Write(ciaSDR, 0xA5)
+
+	var out byte
+	for i := 0; i < 8; i++ {
+		expectBool(t, false, c.Read(ciaICR)&ciaIntSP != 0)
+		c.Tick()
+		expectBool(t, false, c.CNTOutput())
+		c.Tick()
+		expectBool(t, true, c.CNTOutput())
+		out <<= 1
+		if c.SPOutput() {
+			out |= 0x01
+		}
+	}
+	expectByte(t, 0xA5, out)
+	expectBool(t, true, c.Read(ciaICR)&ciaIntSP != 0)
+
+	// Switching to input takes bits from SP on each rising edge of CNT.
+	c.Write(ciaCRA, 0x00)
+	for _, bit := range []bool{false, true, true, false, false, true, false, true} {
+		c.SP = bit
+		c.CNT = false
+		c.Tick()
+		c.CNT = true
+		c.Tick()
+	}
+	expectBool(t, true, c.Read(ciaICR)&ciaIntSP != 0)
+	expectByte(t, 0x65, c.Read(ciaSDR))
+}
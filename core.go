@@ -1,16 +1,5 @@
 package mos6502
 
-func (b *byte) hasBit(bit uint8) bool {
-	if bit > 7 {
-		return false
-	}
-	return (*b & (1 << bit)) != 0
-}
-
-func (b *byte) withBit(bit uint8) byte {
-	return *b + (byte(1 << bit))
-}
-
 /*
 Core of the mos6502 processor.
 */
@@ -34,214 +23,175 @@ type Core struct {
 	// Status flags; bomined make up the SR register.
 	Negative  bool // bit 7
 	Overflow  bool // bit 6
-	Break     bool // bit 4
 	Decimal   bool // bit 3
 	Interrupt bool // bit 2
 	Zero      bool // bit 1
 	Carry     bool // bit 0
 
+	// Deprecated: the B bit only exists in copies of the status pushed on the stack, see Status. Break is ignored.
+	Break bool
+
 	// The connection to get memory stored values from.
 	Bus Bus
 
 	opCycles uint8
-	op       chan int
+	cycles   uint64
+
+	// Set when the last instruction fetched was a JAM opcode, which left the processor locked up where it was.
+	jammed bool
+
+	// Addresses Run stops at before executing the instruction there.
+	breakpoints map[Address]bool
+	stops       map[Address]bool
+
+	// Devices clocked along with the processor, and the interrupt lines wired into it.
+	devices []Ticker
+	irqs    []Interrupter
+	nmis    []Interrupter
+	nmiLast bool
+
+	// Watches for writes to fetched code while set.
+	smc *smcDetector
+
+	// Go handlers run in place of the code at their addresses.
+	traps map[Address]Trap
+
+	// Told of what the processor does, in the order attached.
+	observers []Observer
 }
 
 /*
-Tick the processor once, causing operatons to be performed.
+Tick the processor once, causing operatons to be performed. An instruction does all its work on its first tick and
+spends the rest idling, so the Core stays in step with devices ticked alongside it.
 */
 func (c *Core) Tick() {
+	c.clock()
+
 	if c.opCycles > 0 {
 		c.opCycles--
 		return
 	}
 
-	// Wait for previous operation to finish:
-	<-c.op
+	if c.serviceInterrupt() {
+		return
+	}
 
-	// READ in Op.
-	// c.Bus.ReadOp()
-	readCh := make(chan Operation)
-	go c.ReadInstruction(readCh)
+	c.step()
+}
 
-	// If chan exists, recieve from it.
-	go c.readyExecution(c.op, readCh)
+/*
+clock counts a cycle and ticks the attached devices through it.
+*/
+func (c *Core) clock() {
+	c.cycles++
+	for _, d := range c.devices {
+		d.Tick()
+	}
 }
 
 /*
-ReadInstruction reads in the next op.
+Fetch reads the instruction at the program counter from the Bus, a byte at a time in the order the processor reads
+them. For three byte instructions Byte1 holds the high byte of the operand, so that Full returns the operand address.
 */
-func (c *Core) ReadInstruction(ch chan Operation) {
-	ch <- Operation{Code: 0x69}
+func (c *Core) Fetch() Operation {
+	return decodeAt(c.read, c.PC)
 }
 
 /*
-Execute an operation, sending it to a channel.
+Cycles returns the number of cycles the processor has run since it was created.
 */
-func (c *Core) readyExecution(ch chan int, readCh chan Operation) {
-	op := <-readCh
-	c.Execute(op)
-	ch <- 1
+func (c *Core) Cycles() uint64 {
+	return c.cycles
 }
 
+/*
+Execute performs an operation. The program counter should already have moved past it, as it would have once the
+operation was fetched.
+*/
 func (c *Core) Execute(op Operation) {
-	v := c.Value(op)
-	switch {
-	case (op.Code & 0xE3) == 0x61:
-		c.ADC(v)
-	}
+	dispatch[op.Code](c, op)
+}
+
+/*
+read returns the value at an address on the Bus.
+*/
+func (c *Core) read(a Address) byte {
+	return c.Bus.Read(a)
+}
+
+/*
+write stores a value at an address on the Bus.
+*/
+func (c *Core) write(a Address, d byte) {
+	c.Bus.Write(a, d)
 }
 
 /*
-IndirectAddress locates the proper address on the memory bus given the addresses's address.
+IndirectAddress locates the proper address on the memory bus given the addresses's address. As on the NMOS parts,
+the pointer never crosses a page, so a pointer at $xxFF takes its high byte from $xx00.
 */
 func (c *Core) IndirectAddress(start Address) Address {
-	return AddressFromBytes(c.Bus.Content(start+1), c.Bus.Content(start))
+	return AddressFromBytes(c.read(start&0xFF00|(start+1)&0x00FF), c.read(start))
 }
 
 /*
 Address returns the address of the data used by the operation.
 */
 func (c *Core) Address(op Operation) Address {
-	switch op.Addressing() {
+	a, _ := c.effective(opcodes[op.Code].mode, op)
+	return a
+}
+
+/*
+effective works out the address an addressing mode refers to, and if indexing it crossed a page.
+*/
+func (c *Core) effective(mode AddressType, op Operation) (Address, bool) {
+	switch mode {
 	case abs:
-		return op.Full()
+		return op.Full(), false
 	case absX:
-		return op.Full() + Address(c.X)
+		return indexed(op.Full(), c.X)
 	case absY:
-		return op.Full() + Address(c.Y)
+		return indexed(op.Full(), c.Y)
 	case ind:
-		return c.IndirectAddress(op.Full())
+		return c.IndirectAddress(op.Full()), false
 	case xInd:
-		return c.IndirectAddress(Address(op.Byte1 + c.X))
+		return c.IndirectAddress(Address(op.Byte1 + c.X)), false
 	case indY:
-		return c.IndirectAddress(Address(op.Byte1)) + Address(c.Y)
+		return indexed(c.IndirectAddress(Address(op.Byte1)), c.Y)
 	case rel:
-		return c.PC.WithOffset(op.Byte1)
+		return c.PC.WithOffset(op.Byte1), false
 	case zpg:
-		return Address(op.Byte1)
+		return Address(op.Byte1), false
 	case zpgX:
-		return Address(op.Byte1) + Address(c.X)
+		return Address(op.Byte1 + c.X), false
 	case zpgY:
-		return Address(op.Byte1) + Address(c.Y)
+		return Address(op.Byte1 + c.Y), false
 	}
 
-	return 0
+	return 0, false
+}
+
+/*
+indexed adds an index register to a base address, reporting if the result is on another page.
+*/
+func indexed(base Address, i byte) (Address, bool) {
+	a := base + Address(i)
+	return a, a&0xFF00 != base&0xFF00
 }
 
 /*
 Value returns the value to be used by the operation. This depends on the addressing type.
 */
-func (c *Core) Value(op Operation) *byte {
-	switch op.Addressing() {
+func (c *Core) Value(op Operation) byte {
+	switch opcodes[op.Code].mode {
 	case a:
-		return &c.AC
+		return c.AC
 	case imm:
-		return &(op.Byte1)
+		return op.Byte1
 	case impl:
 		return 0
 	default:
-		return c.Bus.Content(c.Address(op))
-	}
-}
-
-func (c *Core) ADC(v *byte) {
-	a = &v
-	likeSignedN := a > 0x7F && c.AC > 0x7F
-	likeSignedP := a < 0x80 && c.AC < 0x80
-	carry := 0
-	if c.Carry {
-		carry = 1
-	}
-	c.setACandFlags(c.AC + a + carry)
-
-	// Overflow: If two negatives are over FF or two positives over F3
-	c.Overflow = (likeSignedN && c.AC < 0x80) || (likeSignedP && c.AC > 0x7F)
-}
-
-func (c *Core) branch(v *byte) bool {
-	o := c.PC
-	c.PC = c.PC + Address(*v)
-	return (o & 0xFF00) != (c.PC & 0xFF00)
-}
-
-func (c *Core) setACAndFlags(ac *byte) {
-	oldAC := c.AC
-	c.AC = ac
-
-	// Flags
-	// Zero: True if the value is zero.
-	c.Zero = c.AC == 0x00
-
-	// Negative: True if the first bit is set.
-	c.Negative = c.AC > 0x7F
-
-	// Carry: True if the value is greater than a byte.
-	c.Carry = c.AC < oldAC
-}
-
-func (c *Core) AND(v *byte) {
-	c.setACAndFlags(c.AC & *v)
-}
-
-func (c *Core) ASL(v *byte) {
-	c.Carry = *v > 0x79
-	*v = *v << 1
-}
-
-func (c *Core) BCC(v *byte) (bool, bool) {
-	if c.Carry {
-		return false, false
-	}
-	return true, branch(v)
-}
-
-func (c *Core) BCS(v *byte) (bool, bool) {
-	if !c.Carry {
-		return false, false
-	}
-	return true, branch(v)
-}
-
-func (c *Core) BEQ(v *byte) (bool, bool) {
-	if c.Zero {
-		return false, false
-	}
-	return true, branch(v)
-}
-
-func (c *Core) BIT(v *byte) {
-	r := c.AC & v
-
-	c.Zero = r == 0x00
-	c.Overflow = (r & 0x40) == 0x40
-	c.Negative = (r & 0x80) == 0x80
-}
-
-func (c *Core) BMI(v *byte) (bool, bool) {
-	if !c.Negative {
-		return false, false
-	}
-	return true, branch(v)
-}
-
-func (c *Core) BNE(v *byte) (bool, bool) {
-	if !c.Zero {
-		return false, false
-	}
-	return true, branch(v)
-}
-
-func (c *Core) BPL(v *byte) (bool, bool) {
-	if c.Negative {
-		return false, false
-	}
-	return true, branch(v)
-}
-
-func (c *Core) BRK(v *byte) (bool, bool) {
-	if c.Negative {
-		return false, false
+		return c.read(c.Address(op))
 	}
-	return true, branch(v)
 }
@@ -1,7 +1,10 @@
 package mos6502
 
 import (
+	"context"
+	"fmt"
 	"testing"
+	"time"
 )
 
 type addressTestCase struct {
@@ -168,3 +171,145 @@ func TestOPS(t *testing.T) {
 		})
 	}
 }
+
+/*
+loopCore returns a Core running a counting loop from $0200, which never ends.
+*/
+func loopCore() *Core {
+	c := &Core{PC: 0x0200, SP: 0xFF}
+	for i, b := range []byte{
+		0xA2, 0x00, // LDX #$00
+		0xE8,       // INX
+		0xD0, 0xFD, // BNE $0202
+		0x4C, 0x00, 0x02, // JMP $0200
+	} {
+		c.Bus.Write(0x0200+Address(i), b)
+	}
+	return c
+}
+
+func TestStepDoesNotAllocate(t *testing.T) {
+	c := loopCore()
+	allocs := testing.AllocsPerRun(1000, func() {
+		c.Step()
+		c.Tick()
+	})
+	if allocs != 0 {
+		t.Errorf("Expected no allocations but got %v per instruction.", allocs)
+	}
+}
+
+func TestTickCycles(t *testing.T) {
+	c := loopCore()
+
+	// LDX takes 2 cycles, INX 2, and the taken BNE 3.
+	for _, pc := range []Address{0x0202, 0x0202, 0x0203, 0x0203, 0x0202, 0x0202, 0x0202} {
+		c.Tick()
+		expectAddress(t, pc, c.PC)
+	}
+	expectUint64(t, 7, c.Cycles())
+}
+
+/*
+readLog is a device of memory which logs the addresses read from it.
+*/
+type readLog struct {
+	mem   [0x100]byte
+	reads []Address
+}
+
+func (r *readLog) Read(a Address) byte {
+	r.reads = append(r.reads, a)
+	return r.mem[a]
+}
+
+func (r *readLog) Write(a Address, d byte) {
+	r.mem[a] = d
+}
+
+func TestFetchOrder(t *testing.T) {
+	log := &readLog{}
+	copy(log.mem[:], []byte{0xAD, 0x34, 0x12}) // LDA $1234
+	c := &Core{PC: 0x0200}
+	c.Bus.Map(0x0200, 0x02FF, log)
+
+	op := c.Fetch()
+	expectAddress(t, 0x1234, op.Full())
+	expectString(t, "[0 1 2]", fmt.Sprint(log.reads))
+}
+
+func BenchmarkRun(b *testing.B) {
+	c := loopCore()
+	b.ReportAllocs()
+	b.ResetTimer()
+	start := time.Now()
+
+	c.Run(context.Background(), Budget{Instructions: uint64(b.N)})
+
+	b.ReportMetric(float64(b.N)/time.Since(start).Seconds(), "instructions/s")
+}
+
+/*
+resultRegisters names where the result of each instruction which sets Zero and Negative from its result ends up:
+"M" for memory at the operand address, or the register.
+*/
+var resultRegisters = map[string]string{
+	"LDA": "AC", "LDX": "X", "LDY": "Y",
+	"TAX": "X", "TAY": "Y", "TXA": "AC", "TYA": "AC", "TSX": "X", "PLA": "AC",
+	"INX": "X", "INY": "Y", "DEX": "X", "DEY": "Y",
+	"AND": "AC", "ORA": "AC", "EOR": "AC", "ADC": "AC", "SBC": "AC",
+	"INC": "M", "DEC": "M", "ASL": "M", "LSR": "M", "ROL": "M", "ROR": "M",
+}
+
+/*
+Test that executing any operation from any state doesn't panic, and that Zero and Negative match the result byte of
+the instructions which set them from it.
+*/
+func FuzzExecute(f *testing.F) {
+	f.Add(byte(0xA9), byte(0x00), byte(0x00), byte(0x00), byte(0x00), byte(0x00), byte(0xFF), byte(0x00), byte(0x00))
+	f.Add(byte(0x69), byte(0x80), byte(0x00), byte(0x80), byte(0x00), byte(0x00), byte(0xFF), byte(0x01), byte(0x00))
+	f.Add(byte(0xFE), byte(0x12), byte(0x34), byte(0x00), byte(0xFF), byte(0x00), byte(0xFF), byte(0x00), byte(0x7F))
+	f.Add(byte(0x91), byte(0xFF), byte(0x00), byte(0x00), byte(0x00), byte(0xFF), byte(0x00), byte(0xFF), byte(0x55))
+
+	f.Fuzz(func(t *testing.T, code, byte1, byte2, ac, x, y, sp, sr, fill byte) {
+		c := &Core{PC: 0x0200, AC: ac, X: x, Y: y, SP: sp}
+		c.SetStatus(sr)
+		c.Bus.Write(0, 0)
+		for i := range c.Bus.mem {
+			c.Bus.mem[i] = byte(i) ^ fill
+		}
+
+		op := Operation{Code: code, Byte1: byte1, Byte2: byte2}
+		o := opcodes[code]
+		register, ok := resultRegisters[o.name]
+		if o.undocumented || (o.name == "ADC" || o.name == "SBC") && c.Decimal {
+			ok = false
+		}
+		var at Address
+		if register == "M" && o.mode != a {
+			at = c.Address(op)
+		}
+
+		c.PC += Address(op.Size())
+		c.Execute(op)
+		if !ok {
+			return
+		}
+
+		var result byte
+		switch {
+		case register == "AC", register == "M" && o.mode == a:
+			result = c.AC
+		case register == "X":
+			result = c.X
+		case register == "Y":
+			result = c.Y
+		default:
+			result = c.Bus.Read(at)
+		}
+		if c.Zero != (result == 0) || c.Negative != (result&0x80 != 0) {
+			t.Errorf("Expected Z=%t N=%t for %s (%02X) giving %02X but got Z=%t N=%t.", result == 0, result&0x80 != 0,
+				o.name, code, result, c.Zero, c.Negative)
+		}
+	})
+}
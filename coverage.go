@@ -0,0 +1,349 @@
+package mos6502
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+/*
+CoverageFlags records how a byte of memory was used.
+*/
+type CoverageFlags uint8
+
+/*
+The ways a byte of memory can be used.
+*/
+const (
+	// Fetched as the opcode or operand of an instruction.
+	CoveredOpcode CoverageFlags = 1 << iota
+	CoveredOperand
+
+	// Read or written as data, including by the stack and interrupt vectors.
+	CoveredRead
+	CoveredWritten
+)
+
+/*
+Coverage observes a Core and records which bytes were executed as instructions, which were read and written as data,
+and which way each branch went. Every access goes through the Bus slow path while it is recording, so it is best kept
+for test runs.
+*/
+type Coverage struct {
+	NopObserver
+	Core *Core
+
+	// Names shown in listings.
+	Symbols Symbols
+
+	flags [0x10000]CoverageFlags
+
+	// Times each instruction was run, by address of its opcode.
+	executed map[Address]uint64
+
+	// Times each branch was taken and not taken.
+	branches map[Address]*[2]uint64
+
+	// The latest reads, oldest first, which are held back in case they turn out to be the fetch of an instruction.
+	reads   [3]Address
+	pending int
+}
+
+/*
+NewCoverage returns a Coverage recording c. It observes every access made on c's Bus, including those made by traps
+and the host, until Close is called.
+*/
+func NewCoverage(c *Core) *Coverage {
+	cv := &Coverage{Core: c, executed: map[Address]uint64{}, branches: map[Address]*[2]uint64{}}
+	c.Observe(cv)
+	return cv
+}
+
+/*
+Close stops observing the Core, so its Bus goes back to full speed once nothing else observes it.
+*/
+func (cv *Coverage) Close() {
+	cv.Core.Unobserve(cv)
+}
+
+/*
+Read holds back a read until it is known not to be part of fetching an instruction. No fetch is longer than three
+bytes, so anything older than the last three reads is data.
+*/
+func (cv *Coverage) Read(a Address, d byte) {
+	if cv.pending == len(cv.reads) {
+		cv.flags[cv.reads[0]] |= CoveredRead
+		copy(cv.reads[:], cv.reads[1:])
+		cv.pending--
+	}
+	cv.reads[cv.pending] = a
+	cv.pending++
+}
+
+/*
+Written records a write.
+*/
+func (cv *Coverage) Written(a Address, d byte) {
+	cv.flags[a] |= CoveredWritten
+}
+
+/*
+Fetched records an instruction as executed. The reads held back which fetched it aren't data, any before them are.
+*/
+func (cv *Coverage) Fetched(c *Core, pc Address, op Operation) {
+	cv.flush(cv.pending - int(op.Size()))
+	cv.pending = 0
+
+	cv.executed[pc]++
+	cv.flags[pc] |= CoveredOpcode
+	for i := Address(1); i < Address(op.Size()); i++ {
+		cv.flags[pc+i] |= CoveredOperand
+	}
+}
+
+/*
+Retired records the way a branch went, and the data the instruction read.
+*/
+func (cv *Coverage) Retired(c *Core, pc Address, op Operation, cycles int) {
+	cv.flush(cv.pending)
+	if opcodes[op.Code].mode != rel {
+		return
+	}
+	b, ok := cv.branches[pc]
+	if !ok {
+		b = &[2]uint64{}
+		cv.branches[pc] = b
+	}
+	if c.PC == pc+2 {
+		b[1]++
+	} else {
+		b[0]++
+	}
+}
+
+/*
+Interrupted records the reads of the vector as data.
+*/
+func (cv *Coverage) Interrupted(c *Core, vector Address) {
+	cv.flush(cv.pending)
+}
+
+/*
+flush records the first n reads held back as data, and stops holding them.
+*/
+func (cv *Coverage) flush(n int) {
+	for _, a := range cv.reads[:n] {
+		cv.flags[a] |= CoveredRead
+	}
+	cv.pending = copy(cv.reads[:], cv.reads[n:cv.pending])
+}
+
+/*
+At returns how the byte at an address has been used.
+*/
+func (cv *Coverage) At(a Address) CoverageFlags {
+	return cv.flags[a]
+}
+
+/*
+Executed returns the number of times the instruction at an address was run.
+*/
+func (cv *Coverage) Executed(a Address) uint64 {
+	return cv.executed[a]
+}
+
+/*
+Branch returns the number of times the branch at an address was taken and not taken.
+*/
+func (cv *Coverage) Branch(a Address) (taken uint64, notTaken uint64) {
+	if b, ok := cv.branches[a]; ok {
+		return b[0], b[1]
+	}
+	return 0, 0
+}
+
+/*
+WriteListing writes an annotated disassembly of memory from start to end inclusive. Executed instructions are listed
+with the times they ran and branches with the ways they went. Other bytes touched are listed as data marked with how
+they were used, and runs of untouched bytes are skipped over. Addresses with symbols get a label line.
+*/
+func (cv *Coverage) WriteListing(w io.Writer, start, end Address) error {
+	bw := bufio.NewWriter(w)
+	bus := &cv.Core.Bus
+
+	for a := int(start); a <= int(end); {
+		at := Address(a)
+		f := cv.flags[at]
+		if cv.Symbols != nil {
+			if name, ok := cv.Symbols.Name(uint16(at)); ok {
+				fmt.Fprintf(bw, "%22s%s:\n", "", name)
+			}
+		}
+
+		switch {
+		case f&CoveredOpcode != 0:
+			op := decodeAt(bus.Read, at)
+			size := op.Size()
+
+			note := ""
+			if taken, notTaken := cv.Branch(at); taken+notTaken > 0 {
+				note = fmt.Sprintf("taken %d, not taken %d", taken, notTaken)
+				if taken == 0 || notTaken == 0 {
+					note += ", one way only"
+				}
+			}
+			listingLine(bw, fmt.Sprint(cv.executed[at]), at, fmt.Sprintf("% X", op.Bytes()), op.DisassembleWith(at, cv.Symbols), note)
+			a += int(size)
+
+		case f != 0:
+			d := bus.Read(at)
+			listingLine(bw, "", at, fmt.Sprintf("%02X", d), fmt.Sprintf(".byte $%02X", d), f.String())
+			a++
+
+		default:
+			n := 1
+			for a+n <= int(end) && cv.flags[Address(a+n)] == 0 {
+				n++
+			}
+			listingLine(bw, "", at, "", fmt.Sprintf(".res %d", n), "untouched")
+			a += n
+		}
+	}
+	return bw.Flush()
+}
+
+/*
+listingLine writes a line of a coverage listing.
+*/
+func listingLine(w io.Writer, count string, at Address, bytes string, asm string, note string) {
+	line := fmt.Sprintf("%8s  %04X  %-8s  %-16s", count, uint16(at), bytes, asm)
+	if note != "" {
+		line += "; " + note
+	}
+	fmt.Fprintln(w, strings.TrimRight(line, " "))
+}
+
+/*
+String lists the ways a byte was used.
+*/
+func (f CoverageFlags) String() string {
+	var uses []string
+	for _, u := range []struct {
+		flag CoverageFlags
+		name string
+	}{
+		{CoveredOpcode, "opcode"},
+		{CoveredOperand, "operand"},
+		{CoveredRead, "read"},
+		{CoveredWritten, "written"},
+	} {
+		if f&u.flag != 0 {
+			uses = append(uses, u.name)
+		}
+	}
+	return strings.Join(uses, ", ")
+}
+
+/*
+SourceLine is a line of an assembly source file.
+*/
+type SourceLine struct {
+	File string
+	Line int
+}
+
+/*
+SourceMap gives the source line each instruction was assembled from, by address of its opcode. Data should be left
+out, or it will be reported as code which never ran.
+*/
+type SourceMap map[Address]SourceLine
+
+/*
+WriteLCOV writes the coverage of the instructions in a source map in the lcov tracefile format, for genhtml and other
+coverage tools. A line's count is that of its most run instruction, and each branch on it is reported taken and not
+taken.
+*/
+func (cv *Coverage) WriteLCOV(w io.Writer, m SourceMap) error {
+	type line struct {
+		count    uint64
+		branches [][2]uint64
+	}
+	files := map[string]map[int]*line{}
+
+	addrs := make([]Address, 0, len(m))
+	for a := range m {
+		addrs = append(addrs, a)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return addrs[i] < addrs[j] })
+
+	for _, a := range addrs {
+		src := m[a]
+		if files[src.File] == nil {
+			files[src.File] = map[int]*line{}
+		}
+		l, ok := files[src.File][src.Line]
+		if !ok {
+			l = &line{}
+			files[src.File][src.Line] = l
+		}
+
+		n := cv.executed[a]
+		if n > l.count {
+			l.count = n
+		}
+		if opcodes[cv.Core.Bus.Read(a)].mode == rel {
+			taken, notTaken := cv.Branch(a)
+			l.branches = append(l.branches, [2]uint64{taken, notTaken})
+		}
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "TN:")
+	for _, name := range names {
+		lines := files[name]
+		numbers := make([]int, 0, len(lines))
+		for n := range lines {
+			numbers = append(numbers, n)
+		}
+		sort.Ints(numbers)
+
+		fmt.Fprintf(bw, "SF:%s\n", name)
+		var found, hit, branches, branchesHit int
+		for _, n := range numbers {
+			l := lines[n]
+			for i, b := range l.branches {
+				for way, count := range b {
+					branches++
+					if l.count == 0 {
+						fmt.Fprintf(bw, "BRDA:%d,0,%d,-\n", n, i*2+way)
+					} else {
+						fmt.Fprintf(bw, "BRDA:%d,0,%d,%d\n", n, i*2+way, count)
+					}
+					if count > 0 {
+						branchesHit++
+					}
+				}
+			}
+		}
+		fmt.Fprintf(bw, "BRF:%d\nBRH:%d\n", branches, branchesHit)
+
+		for _, n := range numbers {
+			found++
+			if lines[n].count > 0 {
+				hit++
+			}
+			fmt.Fprintf(bw, "DA:%d,%d\n", n, lines[n].count)
+		}
+		fmt.Fprintf(bw, "LF:%d\nLH:%d\n", found, hit)
+		fmt.Fprintln(bw, "end_of_record")
+	}
+	return bw.Flush()
+}
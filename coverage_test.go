@@ -0,0 +1,169 @@
+package mos6502
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+/*
+coverageCore returns a Core running a loop which copies three bytes from $0210 to $0220, then falls through a branch
+which is never taken.
+*/
+func coverageCore() *Core {
+	return profileCore(map[Address][]byte{
+		0x0200: {
+			0xA2, 0x02, // LDX #$02
+			0xBD, 0x10, 0x02, // LDA $0210,X
+			0x9D, 0x20, 0x02, // STA $0220,X
+			0xCA,       // DEX
+			0x10, 0xF7, // BPL $0202
+			0xF0, 0x00, // BEQ $020D
+			0x4C, 0x0D, 0x02, // JMP $020D
+		},
+		0x0210: {0x11, 0x22, 0x33},
+	})
+}
+
+func TestCoverage(t *testing.T) {
+	c := coverageCore()
+	cv := NewCoverage(c)
+	defer cv.Close()
+	expectLoop(t, c, c.Run(context.Background(), Budget{StopOnLoop: true}))
+
+	var tests = map[string]struct {
+		address  Address
+		flags    CoverageFlags
+		executed uint64
+	}{
+		"run once":     {address: 0x0200, flags: CoveredOpcode, executed: 1},
+		"operand":      {address: 0x0201, flags: CoveredOperand},
+		"loop":         {address: 0x0202, flags: CoveredOpcode, executed: 3},
+		"high operand": {address: 0x0204, flags: CoveredOperand},
+		"jump":         {address: 0x020D, flags: CoveredOpcode, executed: 1},
+		"read":         {address: 0x0210, flags: CoveredRead},
+		"read last":    {address: 0x0212, flags: CoveredRead},
+		"untouched":    {address: 0x0213},
+		"written":      {address: 0x0220, flags: CoveredWritten},
+		"not written":  {address: 0x0223},
+	}
+
+	for k, tt := range tests {
+		t.Run(k, func(t *testing.T) {
+			expectUint8(t, uint8(tt.flags), uint8(cv.At(tt.address)))
+			expectUint64(t, tt.executed, cv.Executed(tt.address))
+		})
+	}
+
+	taken, notTaken := cv.Branch(0x0209)
+	expectUint64(t, 2, taken)
+	expectUint64(t, 1, notTaken)
+	taken, notTaken = cv.Branch(0x020B)
+	expectUint64(t, 0, taken)
+	expectUint64(t, 1, notTaken)
+}
+
+func TestCoverageInterrupt(t *testing.T) {
+	c := profileCore(map[Address][]byte{
+		0x0200:    {0x00, 0xEA, 0xEA}, // BRK, NOP, NOP
+		0x0300:    {0x40},             // RTI
+		IRQVector: {0x00, 0x03},
+	})
+	cv := NewCoverage(c)
+	c.Step()
+	c.Step()
+	c.Step()
+	expectAddress(t, 0x0203, c.PC)
+
+	// The stack is written by BRK and read by RTI, and the vector is data too.
+	for _, a := range []Address{0x01FD, 0x01FE, 0x01FF} {
+		expectUint8(t, uint8(CoveredRead|CoveredWritten), uint8(cv.At(a)))
+	}
+	expectUint8(t, uint8(CoveredRead), uint8(cv.At(IRQVector)))
+	expectUint8(t, uint8(CoveredRead), uint8(cv.At(IRQVector+1)))
+	expectUint8(t, 0, uint8(cv.At(0x0201)))
+	expectUint8(t, uint8(CoveredOpcode), uint8(cv.At(0x0202)))
+
+	// Once closed the bus goes back to the fast path, and nothing more is recorded.
+	cv.Close()
+	expectBool(t, true, c.Bus.fastRead[0x01])
+	c.Bus.Write(0x0400, 0x01)
+	expectUint8(t, 0, uint8(cv.At(0x0400)))
+}
+
+func TestCoverageListing(t *testing.T) {
+	c := coverageCore()
+	cv := NewCoverage(c)
+	cv.Symbols = Labels{0x0202: "copy", 0x0210: "source"}
+	defer cv.Close()
+	expectLoop(t, c, c.Run(context.Background(), Budget{StopOnLoop: true}))
+
+	var out strings.Builder
+	if err := cv.WriteListing(&out, 0x0200, 0x0221); err != nil {
+		t.Fatal(err)
+	}
+	expectString(t, `       1  0200  A2 02     LDX #$02
+                      copy:
+       3  0202  BD 10 02  LDA source,X
+       3  0205  9D 20 02  STA $0220,X
+       3  0208  CA        DEX
+       3  0209  10 F7     BPL copy        ; taken 2, not taken 1
+       1  020B  F0 00     BEQ $020D       ; taken 0, not taken 1, one way only
+       1  020D  4C 0D 02  JMP $020D
+                      source:
+          0210  11        .byte $11       ; read
+          0211  22        .byte $22       ; read
+          0212  33        .byte $33       ; read
+          0213            .res 13         ; untouched
+          0220  11        .byte $11       ; written
+          0221  22        .byte $22       ; written
+`, out.String())
+}
+
+func TestCoverageLCOV(t *testing.T) {
+	c := coverageCore()
+	cv := NewCoverage(c)
+	defer cv.Close()
+	expectLoop(t, c, c.Run(context.Background(), Budget{StopOnLoop: true}))
+
+	m := SourceMap{
+		0x0200: {File: "copy.s", Line: 3},
+		0x0202: {File: "copy.s", Line: 4},
+		0x0205: {File: "copy.s", Line: 5},
+		0x0208: {File: "copy.s", Line: 6},
+		0x0209: {File: "copy.s", Line: 6},
+		0x020B: {File: "copy.s", Line: 7},
+		0x020D: {File: "copy.s", Line: 8},
+		0x0300: {File: "lib.s", Line: 2},
+	}
+
+	var out strings.Builder
+	if err := cv.WriteLCOV(&out, m); err != nil {
+		t.Fatal(err)
+	}
+	expectString(t, `TN:
+SF:copy.s
+BRDA:6,0,0,2
+BRDA:6,0,1,1
+BRDA:7,0,0,0
+BRDA:7,0,1,1
+BRF:4
+BRH:3
+DA:3,1
+DA:4,3
+DA:5,3
+DA:6,3
+DA:7,1
+DA:8,1
+LF:6
+LH:6
+end_of_record
+SF:lib.s
+BRF:0
+BRH:0
+DA:2,0
+LF:1
+LH:0
+end_of_record
+`, out.String())
+}
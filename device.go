@@ -0,0 +1,201 @@
+package mos6502
+
+import (
+	"io"
+	"sync"
+)
+
+/*
+Device is a peripheral which can be mapped onto the Bus. The addresses given to a device are relative to the start of
+the range it was mapped at, so a device does not need to know where it lives in memory.
+*/
+type Device interface {
+	Read(a Address) byte
+	Write(a Address, d byte)
+}
+
+/*
+Ticker is anything that is advanced by the system clock, one cycle per call.
+*/
+type Ticker interface {
+	Tick()
+}
+
+/*
+Interrupter is a device with an interrupt request output. Interrupting reports if the line is currently asserted.
+*/
+type Interrupter interface {
+	Interrupting() bool
+}
+
+/*
+Port is the peripheral side of an 8-bit parallel I/O port with a data direction register.
+*/
+type Port struct {
+	// Output register; only the bits set in DDR are driven onto the pins.
+	Data byte
+
+	// Data direction register, a set bit makes the matching pin an output.
+	DDR byte
+
+	// Levels driven onto the pins by outside hardware. Only the bits clear in DDR are used.
+	Input byte
+
+	// Called with the new pin levels whenever the chip changes what it drives, if set.
+	OnWrite func(pins byte)
+
+	// Pins taken over by other parts of the chip, such as timer outputs, and the levels they are held at.
+	forceMask byte
+	forced    byte
+}
+
+/*
+Pins returns the level seen on each pin of the port, outputs from the chip and inputs from outside.
+*/
+func (p *Port) Pins() byte {
+	pins := (p.Data & p.DDR) | (p.Input &^ p.DDR)
+	return (pins &^ p.forceMask) | (p.forced & p.forceMask)
+}
+
+/*
+force hands the pins in mask over to the chip, holding them at the given levels regardless of the DDR.
+*/
+func (p *Port) force(mask byte, levels byte) {
+	if p.forceMask == mask && p.forced&mask == levels&mask {
+		return
+	}
+	p.forceMask = mask
+	p.forced = levels & mask
+	p.notify()
+}
+
+/*
+setData updates the output register and tells any listener about the change.
+*/
+func (p *Port) setData(d byte) {
+	p.Data = d
+	p.notify()
+}
+
+/*
+setDDR updates the data direction register and tells any listener about the change.
+*/
+func (p *Port) setDDR(d byte) {
+	p.DDR = d
+	p.notify()
+}
+
+func (p *Port) notify() {
+	if p.OnWrite != nil {
+		p.OnWrite(p.Pins())
+	}
+}
+
+/*
+ROM is read-only memory which can be mapped onto the Bus. Writes are ignored, and it repeats if mapped over a range
+larger than itself.
+*/
+type ROM []byte
+
+/*
+Read returns a byte of the ROM.
+*/
+func (r ROM) Read(a Address) byte {
+	return r[int(a)%len(r)]
+}
+
+/*
+Write does nothing, the ROM cannot be changed.
+*/
+func (r ROM) Write(a Address, d byte) {}
+
+/*
+hostInput reads from a host connection in the background, so devices can poll for characters without blocking the
+emulator. Reading stops when the host returns an error, or when the hostInput is closed.
+*/
+type hostInput struct {
+	ch chan byte
+
+	// Closed by Close to stop reading, and by the reader once it has stopped.
+	quit    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+
+	mu  sync.Mutex
+	err error
+}
+
+/*
+readHost starts reading characters from r into a new hostInput.
+*/
+func readHost(r io.Reader) *hostInput {
+	h := &hostInput{ch: make(chan byte, 256), quit: make(chan struct{}), stopped: make(chan struct{})}
+	go h.read(r)
+	return h
+}
+
+/*
+Close stops reading from the host. Characters not yet polled are dropped. A read already waiting on the host is left
+to return, after which the reader stops without waiting for its characters to be polled.
+*/
+func (h *hostInput) Close() {
+	if h == nil || h.quit == nil {
+		return
+	}
+	h.once.Do(func() { close(h.quit) })
+}
+
+/*
+poll returns the next character from the host, if one is waiting.
+*/
+func (h *hostInput) poll() (byte, bool) {
+	if h == nil {
+		return 0, false
+	}
+	select {
+	case b := <-h.ch:
+		return b, true
+	default:
+		return 0, false
+	}
+}
+
+/*
+Err returns the error which stopped reading, other than the host reaching the end of its input.
+*/
+func (h *hostInput) Err() error {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.err
+}
+
+func (h *hostInput) read(r io.Reader) {
+	defer close(h.stopped)
+	buf := make([]byte, 64)
+	for {
+		n, err := r.Read(buf)
+		for _, b := range buf[:n] {
+			select {
+			case h.ch <- b:
+			case <-h.quit:
+				return
+			}
+		}
+		select {
+		case <-h.quit:
+			return
+		default:
+		}
+		if err != nil {
+			if err != io.EOF {
+				h.mu.Lock()
+				h.err = err
+				h.mu.Unlock()
+			}
+			return
+		}
+	}
+}
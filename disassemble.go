@@ -0,0 +1,73 @@
+package mos6502
+
+import "fmt"
+
+/*
+Disassemble returns the operation in assembly language, as written at the address at. Branches show the address they
+go to rather than their offset.
+*/
+func (o Operation) Disassemble(at Address) string {
+	return o.DisassembleWith(at, nil)
+}
+
+/*
+DisassembleWith returns the operation in assembly language like Disassemble, with the addresses it refers to named
+from symbols where they have a name.
+*/
+func (o Operation) DisassembleWith(at Address, symbols Symbols) string {
+	op := opcodes[o.Code]
+
+	// Names an operand address, or writes it in hex with as many digits as the instruction has for it.
+	operand := func(a Address, digits int) string {
+		if symbols != nil {
+			if name, ok := symbols.Name(uint16(a)); ok {
+				return name
+			}
+		}
+		return fmt.Sprintf("$%0*X", digits, uint16(a))
+	}
+
+	switch op.mode {
+	case a:
+		return op.name + " A"
+	case impl:
+		return op.name
+	case imm:
+		return fmt.Sprintf("%s #$%02X", op.name, o.Byte1)
+	case zpg:
+		return fmt.Sprintf("%s %s", op.name, operand(Address(o.Byte1), 2))
+	case zpgX:
+		return fmt.Sprintf("%s %s,X", op.name, operand(Address(o.Byte1), 2))
+	case zpgY:
+		return fmt.Sprintf("%s %s,Y", op.name, operand(Address(o.Byte1), 2))
+	case xInd:
+		return fmt.Sprintf("%s (%s,X)", op.name, operand(Address(o.Byte1), 2))
+	case indY:
+		return fmt.Sprintf("%s (%s),Y", op.name, operand(Address(o.Byte1), 2))
+	case abs:
+		return fmt.Sprintf("%s %s", op.name, operand(o.Full(), 4))
+	case absX:
+		return fmt.Sprintf("%s %s,X", op.name, operand(o.Full(), 4))
+	case absY:
+		return fmt.Sprintf("%s %s,Y", op.name, operand(o.Full(), 4))
+	case ind:
+		return fmt.Sprintf("%s (%s)", op.name, operand(o.Full(), 4))
+	case rel:
+		return fmt.Sprintf("%s %s", op.name, operand((at+2).WithOffset(o.Byte1), 4))
+	}
+	return op.name
+}
+
+/*
+Bytes returns the bytes of the operation in the order they sit in memory.
+*/
+func (o Operation) Bytes() []byte {
+	switch o.Size() {
+	case 3:
+		return []byte{o.Code, o.Byte2, o.Byte1}
+	case 2:
+		return []byte{o.Code, o.Byte1}
+	default:
+		return []byte{o.Code}
+	}
+}
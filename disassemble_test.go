@@ -0,0 +1,75 @@
+package mos6502
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDisassemble(t *testing.T) {
+	var tests = map[string]struct {
+		op       Operation
+		expected string
+	}{
+		"accumulator":  {op: Operation{Code: 0x0A}, expected: "ASL A"},
+		"implied":      {op: Operation{Code: 0xE8}, expected: "INX"},
+		"immediate":    {op: Operation{Code: 0xA9, Byte1: 0x12}, expected: "LDA #$12"},
+		"zeropage":     {op: Operation{Code: 0x85, Byte1: 0x40}, expected: "STA $40"},
+		"zeropage X":   {op: Operation{Code: 0xB5, Byte1: 0x40}, expected: "LDA $40,X"},
+		"zeropage Y":   {op: Operation{Code: 0xB6, Byte1: 0x40}, expected: "LDX $40,Y"},
+		"indirect X":   {op: Operation{Code: 0xA1, Byte1: 0x40}, expected: "LDA ($40,X)"},
+		"indirect Y":   {op: Operation{Code: 0xB1, Byte1: 0x40}, expected: "LDA ($40),Y"},
+		"absolute":     {op: Operation{Code: 0x20, Byte1: 0x12, Byte2: 0x34}, expected: "JSR $1234"},
+		"absolute X":   {op: Operation{Code: 0xBD, Byte1: 0x12, Byte2: 0x34}, expected: "LDA $1234,X"},
+		"absolute Y":   {op: Operation{Code: 0x99, Byte1: 0x12, Byte2: 0x34}, expected: "STA $1234,Y"},
+		"indirect":     {op: Operation{Code: 0x6C, Byte1: 0xFF, Byte2: 0xFC}, expected: "JMP ($FFFC)"},
+		"branch back":  {op: Operation{Code: 0xD0, Byte1: 0xFE}, expected: "BNE $0200"},
+		"branch on":    {op: Operation{Code: 0x10, Byte1: 0x10}, expected: "BPL $0212"},
+		"undocumented": {op: Operation{Code: 0xA7, Byte1: 0x10}, expected: "LAX $10"},
+	}
+
+	for k, tt := range tests {
+		t.Run(k, func(t *testing.T) {
+			expectString(t, tt.expected, tt.op.Disassemble(0x0200))
+		})
+	}
+}
+
+func TestDisassembleWith(t *testing.T) {
+	symbols := Labels{0x0040: "ptr", 0x1234: "table", 0x0200: "loop"}
+	var tests = map[string]struct {
+		op       Operation
+		expected string
+	}{
+		"zeropage":   {op: Operation{Code: 0x85, Byte1: 0x40}, expected: "STA ptr"},
+		"indirect Y": {op: Operation{Code: 0xB1, Byte1: 0x40}, expected: "LDA (ptr),Y"},
+		"absolute X": {op: Operation{Code: 0xBD, Byte1: 0x12, Byte2: 0x34}, expected: "LDA table,X"},
+		"branch":     {op: Operation{Code: 0xD0, Byte1: 0xFE}, expected: "BNE loop"},
+		"immediate":  {op: Operation{Code: 0xA9, Byte1: 0x40}, expected: "LDA #$40"},
+		"no name":    {op: Operation{Code: 0x20, Byte1: 0x12, Byte2: 0x35}, expected: "JSR $1235"},
+	}
+
+	for k, tt := range tests {
+		t.Run(k, func(t *testing.T) {
+			expectString(t, tt.expected, tt.op.DisassembleWith(0x0200, symbols))
+		})
+	}
+}
+
+func TestOperationBytes(t *testing.T) {
+	var tests = map[string]struct {
+		op       Operation
+		expected []byte
+	}{
+		"one":   {op: Operation{Code: 0xE8}, expected: []byte{0xE8}},
+		"two":   {op: Operation{Code: 0xA9, Byte1: 0x12}, expected: []byte{0xA9, 0x12}},
+		"three": {op: Operation{Code: 0x20, Byte1: 0x12, Byte2: 0x34}, expected: []byte{0x20, 0x34, 0x12}},
+	}
+
+	for k, tt := range tests {
+		t.Run(k, func(t *testing.T) {
+			if !bytes.Equal(tt.expected, tt.op.Bytes()) {
+				t.Errorf("Expected % X but got % X.", tt.expected, tt.op.Bytes())
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package mos6502
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+/*
+dotEdgeAttributes gives how each kind of edge is drawn.
+*/
+var dotEdgeAttributes = map[EdgeKind]string{
+	EdgeFall:     "",
+	EdgeBranch:   ` [color="darkgreen", label="taken"]`,
+	EdgeJump:     ` [style=bold]`,
+	EdgeCall:     ` [style=dashed, label="call"]`,
+	EdgeIndirect: ` [style=dotted, label="indirect"]`,
+}
+
+/*
+WriteDOT writes the control flow graph of the code from start to end inclusive in the Graphviz DOT language. Each basic
+block starting in the region is a box listing its instructions, with edges out of it for running on, branches taken,
+jumps, subroutine calls and indirect jumps. Anything those edges lead to outside the region, such as a subroutine
+elsewhere or the pointer of an indirect jump, is drawn as an ellipse with its name.
+*/
+func (an *Analysis) WriteDOT(w io.Writer, start, end Address) error {
+	bw := bufio.NewWriter(w)
+	labels := an.labels()
+	names := listingNames{an: an, labels: labels}
+
+	var blocks []*Block
+	inside := map[Address]bool{}
+	for _, b := range an.Blocks() {
+		if b.Start >= start && b.Start <= end {
+			blocks = append(blocks, b)
+			inside[b.Start] = true
+		}
+	}
+
+	id := func(e Edge) string {
+		switch {
+		case e.Kind == EdgeIndirect:
+			return fmt.Sprintf("p%04X", uint16(e.To))
+		case inside[e.To]:
+			return fmt.Sprintf("b%04X", uint16(e.To))
+		}
+		return fmt.Sprintf("x%04X", uint16(e.To))
+	}
+
+	fmt.Fprintf(bw, "digraph \"$%04X-$%04X\" {\n", uint16(start), uint16(end))
+	fmt.Fprintf(bw, "\tnode [shape=box, fontname=\"monospace\"];\n")
+
+	for _, b := range blocks {
+		var label strings.Builder
+		if name, ok := labels[b.Start]; ok {
+			label.WriteString(dotEscape(name) + ":\\l")
+		}
+		for _, pc := range b.Instructions {
+			text := an.decode(pc).DisassembleWith(pc, names)
+			label.WriteString(fmt.Sprintf("%04X  %s\\l", uint16(pc), dotEscape(text)))
+		}
+		fmt.Fprintf(bw, "\tb%04X [label=\"%s\"];\n", uint16(b.Start), label.String())
+	}
+
+	// Nodes for where edges leave the region, each drawn once.
+	var outside []Edge
+	seen := map[string]bool{}
+	for _, b := range blocks {
+		for _, e := range b.Edges {
+			if n := id(e); n[0] != 'b' && !seen[n] {
+				seen[n] = true
+				outside = append(outside, e)
+			}
+		}
+	}
+	sort.Slice(outside, func(i, j int) bool { return id(outside[i]) < id(outside[j]) })
+	for _, e := range outside {
+		name, ok := names.Name(uint16(e.To))
+		if !ok {
+			name = fmt.Sprintf("$%04X", uint16(e.To))
+		}
+		if e.Kind == EdgeIndirect {
+			name = "(" + name + ")"
+		}
+		fmt.Fprintf(bw, "\t%s [shape=ellipse, label=\"%s\"];\n", id(e), dotEscape(name))
+	}
+
+	for _, b := range blocks {
+		for _, e := range b.Edges {
+			fmt.Fprintf(bw, "\tb%04X -> %s%s;\n", uint16(b.Start), id(e), dotEdgeAttributes[e.Kind])
+		}
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+/*
+dotEscape escapes text for a quoted DOT string.
+*/
+func dotEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
@@ -0,0 +1,72 @@
+package mos6502
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteDOT(t *testing.T) {
+	var tests = map[string]struct {
+		image      []byte
+		origin     Address
+		entries    []Address
+		symbols    Symbols
+		start, end Address
+		expected   string
+	}{
+		"rom": {
+			image: romImage, origin: 0xFFE0, start: 0xFFE0, end: 0xFFF2,
+			expected: `digraph "$FFE0-$FFF2" {
+	node [shape=box, fontname="monospace"];
+	bFFE0 [label="reset:\lFFE0  LDX #$FF\lFFE2  TXS\lFFE3  JSR LFFF3\l"];
+	bFFE6 [label="FFE6  LDX #$00\l"];
+	bFFE8 [label="LFFE8:\lFFE8  LDA LFFF4,X\lFFEB  BEQ LFFF0\l"];
+	bFFED [label="FFED  INX\lFFEE  BNE LFFE8\l"];
+	bFFF0 [label="LFFF0:\lFFF0  JMP LFFF0\l"];
+	xFFF3 [shape=ellipse, label="LFFF3"];
+	bFFE0 -> xFFF3 [style=dashed, label="call"];
+	bFFE0 -> bFFE6;
+	bFFE6 -> bFFE8;
+	bFFE8 -> bFFF0 [color="darkgreen", label="taken"];
+	bFFE8 -> bFFED;
+	bFFED -> bFFE8 [color="darkgreen", label="taken"];
+	bFFED -> bFFF0;
+	bFFF0 -> bFFF0 [style=bold];
+}
+`,
+		},
+		"indirect": {
+			image:   []byte{0x20, 0xD2, 0xFF, 0x6C, 0x00, 0x03},
+			origin:  0x0200,
+			entries: []Address{0x0200},
+			symbols: Labels{0xFFD2: "CHROUT", 0x0300: `vec"tor`},
+			start:   0x0200, end: 0x0205,
+			expected: `digraph "$0200-$0205" {
+	node [shape=box, fontname="monospace"];
+	b0200 [label="L0200:\l0200  JSR CHROUT\l"];
+	b0203 [label="0203  JMP (vec\"tor)\l"];
+	p0300 [shape=ellipse, label="(vec\"tor)"];
+	xFFD2 [shape=ellipse, label="CHROUT"];
+	b0200 -> xFFD2 [style=dashed, label="call"];
+	b0200 -> b0203;
+	b0203 -> p0300 [style=dotted, label="indirect"];
+}
+`,
+		},
+	}
+
+	for k, tt := range tests {
+		t.Run(k, func(t *testing.T) {
+			an := Analyze(tt.image, tt.origin, tt.entries...)
+			an.Symbols = tt.symbols
+
+			var b strings.Builder
+			if err := an.WriteDOT(&b, tt.start, tt.end); err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != tt.expected {
+				t.Errorf("Expected graph:\n%s\nbut got:\n%s", tt.expected, b.String())
+			}
+		})
+	}
+}
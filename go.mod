@@ -0,0 +1,3 @@
+module github.com/jakew/mos6502
+
+go 1.18
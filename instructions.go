@@ -0,0 +1,523 @@
+package mos6502
+
+/*
+setNZ sets the Zero and Negative flags from a result.
+*/
+func (c *Core) setNZ(v byte) {
+	c.Zero = v == 0x00
+	c.Negative = v > 0x7F
+}
+
+/*
+carry returns the Carry flag as a bit.
+*/
+func (c *Core) carry() byte {
+	if c.Carry {
+		return 1
+	}
+	return 0
+}
+
+/*
+ADC adds a value and the carry to the accumulator, in binary or BCD depending on the Decimal flag.
+*/
+func (c *Core) ADC(v byte) {
+	if c.Decimal {
+		c.adcDecimal(v)
+		return
+	}
+
+	sum := uint16(c.AC) + uint16(v) + uint16(c.carry())
+	r := byte(sum)
+
+	// Overflow: the operands had the same sign, and the result does not.
+	c.Overflow = (c.AC^r)&(v^r)&0x80 != 0
+	c.Carry = sum > 0xFF
+	c.AC = r
+	c.setNZ(r)
+}
+
+/*
+adcDecimal adds in BCD. As on the NMOS parts, Zero comes from the binary sum and Negative and Overflow from the sum
+before the high digit is adjusted.
+*/
+func (c *Core) adcDecimal(v byte) {
+	ac, b, carry := int(c.AC), int(v), int(c.carry())
+
+	lo := ac&0x0F + b&0x0F + carry
+	if lo > 0x09 {
+		lo = (lo+0x06)&0x0F + 0x10
+	}
+	r := ac&0xF0 + b&0xF0 + lo
+
+	c.Zero = byte(ac+b+carry) == 0x00
+	c.Negative = r&0x80 != 0
+	c.Overflow = (ac^r)&0x80 != 0 && (ac^b)&0x80 == 0
+	if r > 0x9F {
+		r += 0x60
+	}
+	c.Carry = r > 0xFF
+	c.AC = byte(r)
+}
+
+/*
+SBC subtracts a value and the borrow, the inverse of the carry, from the accumulator, in binary or BCD depending on
+the Decimal flag.
+*/
+func (c *Core) SBC(v byte) {
+	if !c.Decimal {
+		c.ADC(^v)
+		return
+	}
+
+	// The flags are set as in binary, only the result is adjusted.
+	ac, b, borrow := int(c.AC), int(v), 1-int(c.carry())
+	c.Decimal = false
+	c.ADC(^v)
+	c.Decimal = true
+
+	lo := ac&0x0F - b&0x0F - borrow
+	if lo < 0 {
+		lo = (lo-0x06)&0x0F - 0x10
+	}
+	r := ac&0xF0 - b&0xF0 + lo
+	if r < 0 {
+		r -= 0x60
+	}
+	c.AC = byte(r)
+}
+
+/*
+AND the accumulator with a value.
+*/
+func (c *Core) AND(v byte) {
+	c.AC &= v
+	c.setNZ(c.AC)
+}
+
+/*
+ORA ors the accumulator with a value.
+*/
+func (c *Core) ORA(v byte) {
+	c.AC |= v
+	c.setNZ(c.AC)
+}
+
+/*
+EOR exclusive ors the accumulator with a value.
+*/
+func (c *Core) EOR(v byte) {
+	c.AC ^= v
+	c.setNZ(c.AC)
+}
+
+/*
+BIT tests a value against the accumulator. Negative and Overflow are copied from the top bits of the value.
+*/
+func (c *Core) BIT(v byte) {
+	c.Zero = c.AC&v == 0x00
+	c.Overflow = v&0x40 != 0
+	c.Negative = v&0x80 != 0
+}
+
+/*
+compare sets the flags as subtracting a value from a register would.
+*/
+func (c *Core) compare(r byte, v byte) {
+	c.Carry = r >= v
+	c.setNZ(r - v)
+}
+
+/*
+CMP compares the accumulator with a value.
+*/
+func (c *Core) CMP(v byte) {
+	c.compare(c.AC, v)
+}
+
+/*
+CPX compares the X register with a value.
+*/
+func (c *Core) CPX(v byte) {
+	c.compare(c.X, v)
+}
+
+/*
+CPY compares the Y register with a value.
+*/
+func (c *Core) CPY(v byte) {
+	c.compare(c.Y, v)
+}
+
+/*
+LDA loads the accumulator.
+*/
+func (c *Core) LDA(v byte) {
+	c.AC = v
+	c.setNZ(v)
+}
+
+/*
+LDX loads the X register.
+*/
+func (c *Core) LDX(v byte) {
+	c.X = v
+	c.setNZ(v)
+}
+
+/*
+LDY loads the Y register.
+*/
+func (c *Core) LDY(v byte) {
+	c.Y = v
+	c.setNZ(v)
+}
+
+/*
+ASL shifts a value left, the top bit going into the carry.
+*/
+func (c *Core) ASL(v byte) byte {
+	c.Carry = v > 0x7F
+	v <<= 1
+	c.setNZ(v)
+	return v
+}
+
+/*
+LSR shifts a value right, the bottom bit going into the carry.
+*/
+func (c *Core) LSR(v byte) byte {
+	c.Carry = v&0x01 != 0
+	v >>= 1
+	c.setNZ(v)
+	return v
+}
+
+/*
+ROL rotates a value left through the carry.
+*/
+func (c *Core) ROL(v byte) byte {
+	r := v<<1 | c.carry()
+	c.Carry = v > 0x7F
+	c.setNZ(r)
+	return r
+}
+
+/*
+ROR rotates a value right through the carry.
+*/
+func (c *Core) ROR(v byte) byte {
+	r := v>>1 | c.carry()<<7
+	c.Carry = v&0x01 != 0
+	c.setNZ(r)
+	return r
+}
+
+/*
+INC increments a value.
+*/
+func (c *Core) INC(v byte) byte {
+	v++
+	c.setNZ(v)
+	return v
+}
+
+/*
+DEC decrements a value.
+*/
+func (c *Core) DEC(v byte) byte {
+	v--
+	c.setNZ(v)
+	return v
+}
+
+/*
+branch moves the program counter by a signed offset if the condition holds. Returns if the branch was taken and if
+it crossed a page, each of which costs a cycle.
+*/
+func (c *Core) branch(cond bool, offset byte) (bool, bool) {
+	if !cond {
+		return false, false
+	}
+	o := c.PC
+	c.PC = c.PC.WithOffset(offset)
+	return true, (o & 0xFF00) != (c.PC & 0xFF00)
+}
+
+func (c *Core) BCC(offset byte) (bool, bool) {
+	return c.branch(!c.Carry, offset)
+}
+
+func (c *Core) BCS(offset byte) (bool, bool) {
+	return c.branch(c.Carry, offset)
+}
+
+func (c *Core) BEQ(offset byte) (bool, bool) {
+	return c.branch(c.Zero, offset)
+}
+
+func (c *Core) BMI(offset byte) (bool, bool) {
+	return c.branch(c.Negative, offset)
+}
+
+func (c *Core) BNE(offset byte) (bool, bool) {
+	return c.branch(!c.Zero, offset)
+}
+
+func (c *Core) BPL(offset byte) (bool, bool) {
+	return c.branch(!c.Negative, offset)
+}
+
+func (c *Core) BVC(offset byte) (bool, bool) {
+	return c.branch(!c.Overflow, offset)
+}
+
+func (c *Core) BVS(offset byte) (bool, bool) {
+	return c.branch(c.Overflow, offset)
+}
+
+/*
+JMP continues from an address.
+*/
+func (c *Core) JMP(a Address) {
+	c.PC = a
+}
+
+/*
+JSR calls a subroutine, pushing the address of the last byte of the instruction.
+*/
+func (c *Core) JSR(a Address) {
+	ret := c.PC - 1
+	c.push(byte(ret >> 8))
+	c.push(byte(ret))
+	c.PC = a
+}
+
+/*
+RTS returns from a subroutine.
+*/
+func (c *Core) RTS() {
+	lo := c.pull()
+	hi := c.pull()
+	c.PC = AddressFromBytes(hi, lo) + 1
+}
+
+/*
+BRK interrupts the processor from software. The byte after the opcode is skipped, and the status is pushed with the
+B bit set so the handler can tell a BRK from an IRQ.
+*/
+func (c *Core) BRK() {
+	c.PC++
+	c.push(byte(c.PC >> 8))
+	c.push(byte(c.PC))
+	c.push(c.Status() | statusBreak)
+	c.Interrupt = true
+	c.PC = AddressFromBytes(c.read(IRQVector+1), c.read(IRQVector))
+}
+
+/*
+RTI returns from an interrupt, restoring the status and program counter.
+*/
+func (c *Core) RTI() {
+	c.SetStatus(c.pull())
+	lo := c.pull()
+	hi := c.pull()
+	c.PC = AddressFromBytes(hi, lo)
+}
+
+func (c *Core) PHA() {
+	c.push(c.AC)
+}
+
+/*
+PHP pushes the status, with the B bit set.
+*/
+func (c *Core) PHP() {
+	c.push(c.Status() | statusBreak)
+}
+
+func (c *Core) PLA() {
+	c.AC = c.pull()
+	c.setNZ(c.AC)
+}
+
+func (c *Core) PLP() {
+	c.SetStatus(c.pull())
+}
+
+func (c *Core) CLC() { c.Carry = false }
+func (c *Core) CLD() { c.Decimal = false }
+func (c *Core) CLI() { c.Interrupt = false }
+func (c *Core) CLV() { c.Overflow = false }
+func (c *Core) SEC() { c.Carry = true }
+func (c *Core) SED() { c.Decimal = true }
+func (c *Core) SEI() { c.Interrupt = true }
+func (c *Core) NOP() {}
+
+func (c *Core) DEX() {
+	c.X--
+	c.setNZ(c.X)
+}
+
+func (c *Core) DEY() {
+	c.Y--
+	c.setNZ(c.Y)
+}
+
+func (c *Core) INX() {
+	c.X++
+	c.setNZ(c.X)
+}
+
+func (c *Core) INY() {
+	c.Y++
+	c.setNZ(c.Y)
+}
+
+func (c *Core) TAX() {
+	c.X = c.AC
+	c.setNZ(c.X)
+}
+
+func (c *Core) TAY() {
+	c.Y = c.AC
+	c.setNZ(c.Y)
+}
+
+func (c *Core) TSX() {
+	c.X = c.SP
+	c.setNZ(c.X)
+}
+
+func (c *Core) TXA() {
+	c.AC = c.X
+	c.setNZ(c.AC)
+}
+
+/*
+TXS copies X to the stack pointer, the only transfer which leaves the flags alone.
+*/
+func (c *Core) TXS() {
+	c.SP = c.X
+}
+
+func (c *Core) TYA() {
+	c.AC = c.Y
+	c.setNZ(c.AC)
+}
+
+/*
+The undocumented instructions below follow the NMOS parts. Most combine two documented ones; the few which depend
+on analogue effects use the values seen on most chips.
+*/
+
+func (c *Core) alr(v byte) {
+	c.AC = c.LSR(c.AC & v)
+}
+
+func (c *Core) anc(v byte) {
+	c.AND(v)
+	c.Carry = c.Negative
+}
+
+func (c *Core) ane(v byte) {
+	c.AC = (c.AC | 0xEE) & c.X & v
+	c.setNZ(c.AC)
+}
+
+func (c *Core) arr(v byte) {
+	c.AC = (c.AC&v)>>1 | c.carry()<<7
+	c.setNZ(c.AC)
+	c.Carry = c.AC&0x40 != 0
+	c.Overflow = (c.AC>>6^c.AC>>5)&0x01 != 0
+}
+
+func (c *Core) las(v byte) {
+	c.SP &= v
+	c.AC, c.X = c.SP, c.SP
+	c.setNZ(c.SP)
+}
+
+func (c *Core) lax(v byte) {
+	c.AC, c.X = v, v
+	c.setNZ(v)
+}
+
+func (c *Core) lxa(v byte) {
+	c.AC = (c.AC | 0xEE) & v
+	c.X = c.AC
+	c.setNZ(c.AC)
+}
+
+func (c *Core) sbx(v byte) {
+	r := c.AC & c.X
+	c.Carry = r >= v
+	c.X = r - v
+	c.setNZ(c.X)
+}
+
+func (c *Core) dcp(v byte) byte {
+	v--
+	c.CMP(v)
+	return v
+}
+
+func (c *Core) isc(v byte) byte {
+	v++
+	c.SBC(v)
+	return v
+}
+
+func (c *Core) rla(v byte) byte {
+	v = c.ROL(v)
+	c.AND(v)
+	return v
+}
+
+func (c *Core) rra(v byte) byte {
+	v = c.ROR(v)
+	c.ADC(v)
+	return v
+}
+
+func (c *Core) slo(v byte) byte {
+	v = c.ASL(v)
+	c.ORA(v)
+	return v
+}
+
+func (c *Core) sre(v byte) byte {
+	v = c.LSR(v)
+	c.EOR(v)
+	return v
+}
+
+func (c *Core) sha(a Address) {
+	c.storeHigh(a, c.Y, c.AC&c.X)
+}
+
+func (c *Core) shx(a Address) {
+	c.storeHigh(a, c.Y, c.X)
+}
+
+func (c *Core) shy(a Address) {
+	c.storeHigh(a, c.X, c.Y)
+}
+
+func (c *Core) tas(a Address) {
+	c.SP = c.AC & c.X
+	c.storeHigh(a, c.Y, c.SP)
+}
+
+/*
+storeHigh stores a value anded with one more than the high byte of the unindexed address. When indexing crosses a
+page the stored value also replaces the high byte of the address written to.
+*/
+func (c *Core) storeHigh(a Address, index byte, v byte) {
+	base := a - Address(index)
+	v &= byte(base>>8) + 1
+	if a&0xFF00 != base&0xFF00 {
+		a = AddressFromBytes(v, byte(a))
+	}
+	c.write(a, v)
+}
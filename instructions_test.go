@@ -0,0 +1,219 @@
+package mos6502
+
+import "testing"
+
+func TestInstructions(t *testing.T) {
+	var tests = map[string]opTest{
+		"SBC borrow": {
+			op:       Operation{Code: 0xE9, Byte1: 0x01},
+			start:    Core{AC: 0x00},
+			expected: Core{AC: 0xFE, Negative: true},
+		},
+		"SBC overflow": {
+			op:       Operation{Code: 0xE9, Byte1: 0x01},
+			start:    Core{AC: 0x80, Carry: true},
+			expected: Core{AC: 0x7F, Carry: true, Overflow: true},
+		},
+		"ADC decimal": {
+			op:       Operation{Code: 0x69, Byte1: 0x27},
+			start:    Core{AC: 0x15, Decimal: true},
+			expected: Core{AC: 0x42, Decimal: true},
+		},
+		"ADC decimal carry": {
+			op:       Operation{Code: 0x69, Byte1: 0x01},
+			start:    Core{AC: 0x99, Decimal: true},
+			expected: Core{AC: 0x00, Decimal: true, Carry: true, Negative: true},
+		},
+		"SBC decimal": {
+			op:       Operation{Code: 0xE9, Byte1: 0x15},
+			start:    Core{AC: 0x42, Decimal: true, Carry: true},
+			expected: Core{AC: 0x27, Decimal: true, Carry: true},
+		},
+		"SBC decimal borrow": {
+			op:       Operation{Code: 0xE9, Byte1: 0x01},
+			start:    Core{AC: 0x00, Decimal: true, Carry: true},
+			expected: Core{AC: 0x99, Decimal: true, Negative: true},
+		},
+		"CMP equal": {
+			op:       Operation{Code: 0xC9, Byte1: 0x40},
+			start:    Core{AC: 0x40},
+			expected: Core{AC: 0x40, Zero: true, Carry: true},
+		},
+		"CPX less": {
+			op:       Operation{Code: 0xE0, Byte1: 0x41},
+			start:    Core{X: 0x40},
+			expected: Core{X: 0x40, Negative: true},
+		},
+		"BIT": {
+			op:       Operation{Code: 0x24, Byte1: 0x10},
+			start:    Core{AC: 0x01, Bus: Bus{data: map[Address]byte{0x0010: 0xC0}}},
+			expected: Core{AC: 0x01, Zero: true, Negative: true, Overflow: true},
+		},
+		"ASL A": {
+			op:       Operation{Code: 0x0A},
+			start:    Core{AC: 0x81},
+			expected: Core{AC: 0x02, Carry: true},
+		},
+		"ROR A": {
+			op:       Operation{Code: 0x6A},
+			start:    Core{AC: 0x01, Carry: true},
+			expected: Core{AC: 0x80, Carry: true, Negative: true},
+		},
+		"LDX zpg,Y wraps": {
+			op:       Operation{Code: 0xB6, Byte1: 0xF0},
+			start:    Core{Y: 0x20, Bus: Bus{data: map[Address]byte{0x0010: 0x00}}},
+			expected: Core{Y: 0x20, Zero: true},
+		},
+		"LDY zpg,X": {
+			op:       Operation{Code: 0xB4, Byte1: 0x10},
+			start:    Core{X: 0x02, Y: 0x01, Bus: Bus{data: map[Address]byte{0x0011: 0x7F, 0x0012: 0x80}}},
+			expected: Core{X: 0x02, Y: 0x80, Negative: true},
+		},
+		"LDY abs,X": {
+			op:       Operation{Code: 0xBC, Byte1: 0x03, Byte2: 0x00},
+			start:    Core{X: 0x02, Y: 0x01, Bus: Bus{data: map[Address]byte{0x0301: 0x7F, 0x0302: 0x80}}},
+			expected: Core{X: 0x02, Y: 0x80, Negative: true},
+		},
+		"TXS keeps flags": {
+			op:       Operation{Code: 0x9A},
+			start:    Core{SP: 0xFF, X: 0x00},
+			expected: Core{SP: 0x00},
+		},
+		"BNE taken": {
+			op:       Operation{Code: 0xD0, Byte1: 0xFE},
+			start:    Core{PC: 0x0202},
+			expected: Core{PC: 0x0200},
+		},
+		"BEQ not taken": {
+			op:       Operation{Code: 0xF0, Byte1: 0xFE},
+			start:    Core{PC: 0x0202},
+			expected: Core{PC: 0x0202},
+		},
+		"JMP indirect page bug": {
+			op:       Operation{Code: 0x6C, Byte1: 0x12, Byte2: 0xFF},
+			start:    Core{Bus: Bus{data: map[Address]byte{0x12FF: 0x34, 0x1200: 0x56, 0x1300: 0x78}}},
+			expected: Core{PC: 0x5634},
+		},
+		"LAX": {
+			op:       Operation{Code: 0xA7, Byte1: 0x10},
+			start:    Core{Bus: Bus{data: map[Address]byte{0x0010: 0x80}}},
+			expected: Core{AC: 0x80, X: 0x80, Negative: true},
+		},
+		"SBX": {
+			op:       Operation{Code: 0xCB, Byte1: 0x02},
+			start:    Core{AC: 0x0F, X: 0xFC},
+			expected: Core{AC: 0x0F, X: 0x0A, Carry: true},
+		},
+	}
+
+	for k, tt := range tests {
+		t.Run(k, func(t *testing.T) {
+			tt.start.Execute(tt.op)
+			expectCore(t, &tt.expected, &tt.start)
+		})
+	}
+}
+
+func TestReadModifyWrite(t *testing.T) {
+	var tests = map[string]struct {
+		op     Operation
+		start  byte
+		result byte
+		ac     byte
+	}{
+		"INC":         {op: Operation{Code: 0xE6, Byte1: 0x10}, start: 0xFF, result: 0x00},
+		"DEC abs":     {op: Operation{Code: 0xCE, Byte1: 0x00, Byte2: 0x10}, start: 0x00, result: 0xFF},
+		"LSR":         {op: Operation{Code: 0x46, Byte1: 0x10}, start: 0x03, result: 0x01},
+		"SLO":         {op: Operation{Code: 0x07, Byte1: 0x10}, start: 0x41, result: 0x82, ac: 0x83},
+		"DCP":         {op: Operation{Code: 0xC7, Byte1: 0x10}, start: 0x01, result: 0x00},
+		"ISC":         {op: Operation{Code: 0xE7, Byte1: 0x10}, start: 0x00, result: 0x01, ac: 0xFF},
+		"STA":         {op: Operation{Code: 0x85, Byte1: 0x10}, start: 0x00, result: 0x01, ac: 0x01},
+		"SAX":         {op: Operation{Code: 0x87, Byte1: 0x10}, start: 0xFF, result: 0x00},
+		"NOP zpg":     {op: Operation{Code: 0x04, Byte1: 0x10}, start: 0x12, result: 0x12, ac: 0x01},
+		"ASL address": {op: Operation{Code: 0x06, Byte1: 0x10}, start: 0x40, result: 0x80, ac: 0x01},
+	}
+
+	for k, tt := range tests {
+		t.Run(k, func(t *testing.T) {
+			c := &Core{AC: 0x01}
+			c.Bus.Write(0x0010, tt.start)
+			c.Execute(tt.op)
+			expectByte(t, tt.result, c.Bus.Read(0x0010))
+			if tt.ac != 0 {
+				expectByte(t, tt.ac, c.AC)
+			}
+		})
+	}
+}
+
+func TestStepCycles(t *testing.T) {
+	var tests = map[string]struct {
+		code   []byte
+		cycles int
+	}{
+		"DEC abs":   {code: []byte{0xCE, 0x00, 0x03}, cycles: 6},
+		"LDY zpg,X": {code: []byte{0xB4, 0x10}, cycles: 4},
+		"LDY abs,X": {code: []byte{0xBC, 0x00, 0x03}, cycles: 4},
+	}
+
+	for k, tt := range tests {
+		t.Run(k, func(t *testing.T) {
+			c := &Core{PC: 0x0200}
+			for i, b := range tt.code {
+				c.Bus.Write(0x0200+Address(i), b)
+			}
+			expectUint64(t, uint64(tt.cycles), uint64(c.Step()))
+		})
+	}
+}
+
+func TestSubroutines(t *testing.T) {
+	c := &Core{PC: 0x0203, SP: 0xFF}
+
+	// JSR pushes the address of its own last byte.
+	c.Execute(Operation{Code: 0x20, Byte1: 0x30, Byte2: 0x00})
+	expectAddress(t, 0x3000, c.PC)
+	expectByte(t, 0xFD, c.SP)
+	expectByte(t, 0x02, c.Bus.Read(0x01FF))
+	expectByte(t, 0x02, c.Bus.Read(0x01FE))
+
+	c.Execute(Operation{Code: 0x60})
+	expectAddress(t, 0x0203, c.PC)
+	expectByte(t, 0xFF, c.SP)
+}
+
+func TestBRKAndRTI(t *testing.T) {
+	c := &Core{PC: 0x0201, SP: 0xFF, Carry: true}
+	c.Bus.Write(0xFFFE, 0x00)
+	c.Bus.Write(0xFFFF, 0x80)
+
+	c.Execute(Operation{Code: 0x00})
+	expectAddress(t, 0x8000, c.PC)
+	expectBool(t, true, c.Interrupt)
+	expectByte(t, 0x02, c.Bus.Read(0x01FF))
+	expectByte(t, 0x02, c.Bus.Read(0x01FE))
+	expectByte(t, 0x31, c.Bus.Read(0x01FD))
+
+	c.Execute(Operation{Code: 0x40})
+	expectAddress(t, 0x0202, c.PC)
+	expectBool(t, false, c.Interrupt)
+	expectBool(t, true, c.Carry)
+	expectByte(t, 0xFF, c.SP)
+}
+
+func TestStackInstructions(t *testing.T) {
+	c := &Core{SP: 0xFF, AC: 0x80, Negative: true, Decimal: true}
+
+	c.Execute(Operation{Code: 0x08})
+	c.Execute(Operation{Code: 0x48})
+	expectByte(t, 0x38|0x80, c.Bus.Read(0x01FF))
+	expectByte(t, 0x80, c.Bus.Read(0x01FE))
+
+	c.AC, c.Negative, c.Decimal = 0x00, false, false
+	c.Execute(Operation{Code: 0x68})
+	expectByte(t, 0x80, c.AC)
+	c.Execute(Operation{Code: 0x28})
+	expectBool(t, true, c.Decimal)
+	expectBool(t, true, c.Negative)
+	expectByte(t, 0xFF, c.SP)
+}
@@ -0,0 +1,171 @@
+package mos6502
+
+/*
+Vectors the processor jumps through when interrupted.
+*/
+const (
+	NMIVector   Address = 0xFFFA
+	ResetVector Address = 0xFFFC
+	IRQVector   Address = 0xFFFE
+)
+
+/*
+Attach connects a device to the Core's clock, so it is ticked once for every tick of the processor.
+*/
+func (c *Core) Attach(t Ticker) {
+	c.devices = append(c.devices, t)
+}
+
+/*
+ConnectIRQ wires a device's interrupt output to the maskable IRQ input. The line is level triggered and shared, so
+any connected device can hold it.
+*/
+func (c *Core) ConnectIRQ(i Interrupter) {
+	c.irqs = append(c.irqs, i)
+}
+
+/*
+ConnectNMI wires a device's interrupt output to the NMI input. The line is edge triggered, an interrupt is taken
+only when it goes from released to asserted.
+*/
+func (c *Core) ConnectNMI(i Interrupter) {
+	c.nmis = append(c.nmis, i)
+}
+
+/*
+Reset starts the processor as the RES line does. Interrupts are masked, the stack pointer drops by three as if the
+return state had been pushed, and execution continues from the address in the reset vector.
+*/
+func (c *Core) Reset() {
+	c.SP -= 3
+	c.Interrupt = true
+	c.PC = AddressFromBytes(c.Bus.Read(ResetVector+1), c.Bus.Read(ResetVector))
+
+	// The reset sequence takes 7 cycles, like an interrupt.
+	c.opCycles = 6
+
+	for _, o := range c.observers {
+		o.Reset(c)
+	}
+}
+
+/*
+serviceInterrupt checks the interrupt lines between instructions, and if one should be taken pushes the return state
+and jumps through its vector. Returns true if an interrupt was taken.
+*/
+func (c *Core) serviceInterrupt() bool {
+	if len(c.nmis) == 0 && len(c.irqs) == 0 {
+		return false
+	}
+
+	nmi := asserted(c.nmis)
+	edge := nmi && !c.nmiLast
+	c.nmiLast = nmi
+
+	switch {
+	case edge:
+		c.interrupt(NMIVector)
+	case !c.Interrupt && asserted(c.irqs):
+		c.interrupt(IRQVector)
+	default:
+		return false
+	}
+
+	// The interrupt sequence takes 7 cycles, this tick was the first.
+	c.opCycles = 6
+	return true
+}
+
+/*
+interrupt pushes the program counter and status, masks further IRQs and continues from the address in the vector.
+*/
+func (c *Core) interrupt(vector Address) {
+	if c.smc != nil {
+		c.smc.pc = c.PC
+	}
+	c.push(byte(c.PC >> 8))
+	c.push(byte(c.PC))
+	c.push(c.Status())
+	c.Interrupt = true
+	c.PC = AddressFromBytes(c.Bus.Read(vector+1), c.Bus.Read(vector))
+
+	for _, o := range c.observers {
+		o.Interrupted(c, vector)
+	}
+}
+
+/*
+interrupted returns where the processor was and the stack pointer it had before the interrupt just taken, read back
+from the return state it pushed without telling anyone of the reads.
+*/
+func (c *Core) interrupted() (Address, byte) {
+	stack := func(sp byte) byte {
+		return c.Bus.peek(0x0100 | Address(sp))
+	}
+	return AddressFromBytes(stack(c.SP+3), stack(c.SP+2)), c.SP + 3
+}
+
+/*
+push places a byte on the stack in page one.
+*/
+func (c *Core) push(d byte) {
+	c.Bus.Write(0x0100|Address(c.SP), d)
+	c.SP--
+}
+
+/*
+pull removes a byte from the stack in page one.
+*/
+func (c *Core) pull() byte {
+	c.SP++
+	return c.Bus.Read(0x0100 | Address(c.SP))
+}
+
+/*
+Bits of the status register which aren't flags. Bit 5 is unused and always reads as set. The B bit isn't held in the
+processor at all: it only exists in copies of the status pushed on the stack, set by PHP and BRK and clear for IRQ
+and NMI, so a handler can tell a BRK from a hardware interrupt.
+*/
+const (
+	statusBreak  byte = 0x10
+	statusUnused byte = 0x20
+)
+
+/*
+Status returns the flags packed into the layout of the status register, with bit 5 set and the B bit clear, as an
+IRQ or NMI pushes it.
+*/
+func (c *Core) Status() byte {
+	sr := statusUnused
+	for bit, set := range [8]bool{c.Carry, c.Zero, c.Interrupt, c.Decimal, false, false, c.Overflow, c.Negative} {
+		if set {
+			sr |= 1 << uint(bit)
+		}
+	}
+	return sr
+}
+
+/*
+SetStatus unpacks the layout of the status register into the flags, as PLP and RTI do. Bits 4 and 5 only exist when
+the register is pushed, so they are ignored.
+*/
+func (c *Core) SetStatus(sr byte) {
+	c.Carry = sr&0x01 != 0
+	c.Zero = sr&0x02 != 0
+	c.Interrupt = sr&0x04 != 0
+	c.Decimal = sr&0x08 != 0
+	c.Overflow = sr&0x40 != 0
+	c.Negative = sr&0x80 != 0
+}
+
+/*
+asserted reports if any of the given interrupt outputs are active.
+*/
+func asserted(lines []Interrupter) bool {
+	for _, l := range lines {
+		if l.Interrupting() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,167 @@
+package mos6502
+
+import "testing"
+
+/*
+line is an interrupt output which is held by hand.
+*/
+type line bool
+
+func (l *line) Interrupting() bool {
+	return bool(*l)
+}
+
+func TestServiceInterrupt(t *testing.T) {
+	var tests = map[string]struct {
+		irq      bool
+		nmi      bool
+		masked   bool
+		taken    bool
+		expected Address
+	}{
+		"none":        {taken: false, expected: 0x1234},
+		"irq":         {irq: true, taken: true, expected: 0x8000},
+		"irq masked":  {irq: true, masked: true, taken: false, expected: 0x1234},
+		"nmi":         {nmi: true, taken: true, expected: 0x9000},
+		"nmi masked":  {nmi: true, masked: true, taken: true, expected: 0x9000},
+		"nmi and irq": {irq: true, nmi: true, taken: true, expected: 0x9000},
+	}
+
+	for k, tt := range tests {
+		t.Run(k, func(t *testing.T) {
+			irq, nmi := line(tt.irq), line(tt.nmi)
+			c := Core{PC: 0x1234, SP: 0xFF, Interrupt: tt.masked, Carry: true}
+			c.Bus = Bus{data: map[Address]byte{
+				0xFFFA: 0x00, 0xFFFB: 0x90,
+				0xFFFE: 0x00, 0xFFFF: 0x80,
+			}}
+			c.ConnectIRQ(&irq)
+			c.ConnectNMI(&nmi)
+
+			expectBool(t, tt.taken, c.serviceInterrupt())
+			expectAddress(t, tt.expected, c.PC)
+			if !tt.taken {
+				return
+			}
+
+			expectBool(t, true, c.Interrupt)
+			expectByte(t, 0xFC, c.SP)
+			expectByte(t, 0x12, c.Bus.Read(0x01FF))
+			expectByte(t, 0x34, c.Bus.Read(0x01FE))
+			expectByte(t, 0x21|boolBit(tt.masked, 0x04), c.Bus.Read(0x01FD))
+		})
+	}
+}
+
+func TestNMIEdgeTriggered(t *testing.T) {
+	nmi := line(true)
+	c := Core{SP: 0xFF}
+	c.ConnectNMI(&nmi)
+
+	expectBool(t, true, c.serviceInterrupt())
+	expectBool(t, false, c.serviceInterrupt())
+
+	nmi = false
+	expectBool(t, false, c.serviceInterrupt())
+
+	nmi = true
+	expectBool(t, true, c.serviceInterrupt())
+}
+
+func TestTickWithVIA(t *testing.T) {
+	v := NewVIA()
+	v.Write(viaIER, 0x80|viaIntT1)
+	v.Write(viaT1CL, 0x01)
+	v.Write(viaT1CH, 0x00)
+
+	c := Core{PC: 0x0200, SP: 0xFF}
+	c.Bus.Map(0x6000, 0x600F, v)
+	c.Bus.Write(0xFFFE, 0x00)
+	c.Bus.Write(0xFFFF, 0x80)
+	c.Attach(v)
+	c.ConnectIRQ(v)
+
+	// The timer runs out on the second tick, which takes the interrupt.
+	c.opCycles = 1
+	c.Tick()
+	expectAddress(t, 0x0200, c.PC)
+	c.Tick()
+	expectAddress(t, 0x8000, c.PC)
+
+	// The interrupt handler clears the flag through the bus.
+	expectByte(t, 0xC0, c.Bus.Read(0x600D))
+	c.Bus.Read(0x6004)
+	expectBool(t, false, v.Interrupting())
+}
+
+/*
+boolBit returns bit when set is true.
+*/
+func boolBit(set bool, bit byte) byte {
+	if set {
+		return bit
+	}
+	return 0
+}
+
+func TestStatus(t *testing.T) {
+	var tests = map[string]struct {
+		core     Core
+		expected byte
+	}{
+		"clear": {core: Core{}, expected: 0x20},
+		"all": {
+			core:     Core{Negative: true, Overflow: true, Decimal: true, Interrupt: true, Zero: true, Carry: true},
+			expected: 0xEF,
+		},
+		"carry":      {core: Core{Carry: true}, expected: 0x21},
+		"break held": {core: Core{Break: true}, expected: 0x20},
+	}
+
+	for k, tt := range tests {
+		t.Run(k, func(t *testing.T) {
+			expectByte(t, tt.expected, tt.core.Status())
+
+			c := Core{}
+			c.SetStatus(tt.expected)
+			expectByte(t, tt.expected, c.Status())
+		})
+	}
+
+	t.Run("bits 4 and 5 ignored", func(t *testing.T) {
+		c := Core{}
+		c.SetStatus(0x30)
+		expectByte(t, 0x20, c.Status())
+		expectCore(t, &Core{}, &c)
+	})
+}
+
+/*
+Test that the copy of the status on the stack has the B bit set by PHP and BRK, and clear for IRQ and NMI, and that
+pulling it back ignores it.
+*/
+func TestStatusPushed(t *testing.T) {
+	var tests = map[string]struct {
+		push     func(c *Core)
+		pull     func(c *Core)
+		expected byte
+	}{
+		"PHP": {push: func(c *Core) { c.PHP() }, pull: func(c *Core) { c.PLP() }, expected: 0x31},
+		"BRK": {push: func(c *Core) { c.BRK() }, pull: func(c *Core) { c.RTI() }, expected: 0x31},
+		"IRQ": {push: func(c *Core) { c.interrupt(IRQVector) }, pull: func(c *Core) { c.RTI() }, expected: 0x21},
+		"NMI": {push: func(c *Core) { c.interrupt(NMIVector) }, pull: func(c *Core) { c.RTI() }, expected: 0x21},
+	}
+
+	for k, tt := range tests {
+		t.Run(k, func(t *testing.T) {
+			c := &Core{SP: 0xFF, Carry: true}
+			tt.push(c)
+			expectByte(t, tt.expected, c.Bus.Read(0x0100|Address(c.SP+1)))
+
+			c.Carry = false
+			c.Interrupt = false
+			tt.pull(c)
+			expectByte(t, 0x21, c.Status())
+		})
+	}
+}
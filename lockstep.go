@@ -0,0 +1,260 @@
+package mos6502
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+/*
+Runner runs a Core: the Core itself, or an engine running it such as a BlockCache or Profiler.
+*/
+type Runner interface {
+	Run(ctx context.Context, b Budget) StopReason
+}
+
+/*
+BusWrite is a value written to an address.
+*/
+type BusWrite struct {
+	Address Address
+	Value   byte
+}
+
+/*
+LockstepStep is what one instruction, or interrupt, did on each side of a Lockstep.
+*/
+type LockstepStep struct {
+	// Where the first Core was before the step, and the instruction there. The instruction is read straight from
+	// memory so the Core sees no extra reads, and is left out when any of it is in a page with devices.
+	PC      Address
+	Op      Operation
+	decoded bool
+
+	// The registers after the step, the cycles it took, the writes it made and why it stopped.
+	Registers [2]Registers
+	Cycles    [2]uint64
+	Writes    [2][]BusWrite
+	Stop      [2]StopReason
+}
+
+/*
+Divergence is where two Cores run in lockstep stopped agreeing.
+*/
+type Divergence struct {
+	// Instructions both Cores ran alike before the one where they differ.
+	Instruction uint64
+
+	// How the Cores differ, one line for each thing, first Core first.
+	Differences []string
+
+	// The steps leading up to the divergence, ending with the one that diverged.
+	Trace []LockstepStep
+}
+
+/*
+defaultWindow is how many steps a Lockstep keeps for the trace of a divergence unless told otherwise.
+*/
+const defaultWindow = 16
+
+/*
+Lockstep runs two Cores an instruction at a time, comparing their registers, cycle counts and memory writes after each,
+to find where two engines or two machines loaded alike stop agreeing. The Cores should start from identical state,
+with identical Bus images.
+
+Writes are seen through the guard on each Bus, alongside any self-modifying code detection already on.
+*/
+type Lockstep struct {
+	Cores [2]*Core
+
+	// What runs each Core. Nil runs the Core itself.
+	Runners [2]Runner
+
+	// Steps kept for the trace of a divergence. Zero keeps 16.
+	Window int
+
+	// Set when Run stops with StopDiverged.
+	Divergence *Divergence
+
+	writes [2][]BusWrite
+}
+
+/*
+NewLockstep returns a Lockstep running a and b with their own interpreters. Set Runners to run either with another
+engine.
+*/
+func NewLockstep(a, b *Core) *Lockstep {
+	return &Lockstep{Cores: [2]*Core{a, b}}
+}
+
+/*
+Run steps both Cores until they diverge, or until the first stops for any of the reasons Core.Run would. The budget
+and breakpoints of the first Core are checked between instructions. Writes are recorded through the slow path of each
+Bus, so the Cores run well below full speed.
+*/
+func (l *Lockstep) Run(ctx context.Context, b Budget) StopReason {
+	window := l.Window
+	if window <= 0 {
+		window = defaultWindow
+	}
+	l.Divergence = nil
+
+	// Any guard already on a Bus, such as a self-modifying code detector's, is still told of writes, and is put back
+	// with the pages it guarded afterwards.
+	var guards [2]func(a Address, d byte)
+	var guarded [2][0x100]bool
+	for i, c := range l.Cores {
+		i := i
+		bus := &c.Bus
+		guards[i], guarded[i] = bus.guard, bus.guarded
+		next := bus.guard
+		bus.guard = func(a Address, d byte) {
+			l.writes[i] = append(l.writes[i], BusWrite{Address: a, Value: d})
+			if next != nil {
+				next(a, d)
+			}
+		}
+		for p := 0; p < 0x100; p++ {
+			bus.protect(byte(p), true)
+		}
+	}
+	defer func() {
+		for i, c := range l.Cores {
+			for p := 0; p < 0x100; p++ {
+				c.Bus.protect(byte(p), guarded[i][p])
+			}
+			c.Bus.guard = guards[i]
+			if c.smc != nil {
+				c.smc.guardFetched(&c.Bus)
+			}
+		}
+	}()
+
+	a := l.Cores[0]
+	done := ctx.Done()
+	start := a.cycles
+	var trace []LockstepStep
+
+	for n := uint64(0); ; n++ {
+		if n%cancelEvery == 0 && done != nil {
+			select {
+			case <-done:
+				return StopCancelled
+			default:
+			}
+		}
+		if r, stop := a.check(b, n, start); stop {
+			return r
+		}
+
+		step := LockstepStep{PC: a.PC}
+		one := Budget{Instructions: 1, StopOnLoop: b.StopOnLoop}
+		if mem := a.Bus.mem; mem != nil && !a.Bus.io[a.PC>>8] {
+			step.Op = decodeAt(func(x Address) byte { return mem[x] }, a.PC)
+			step.decoded = !a.Bus.io[(a.PC+Address(step.Op.Size())-1)>>8]
+		}
+		for i, c := range l.Cores {
+			l.writes[i] = nil
+			before := c.cycles
+			if l.Runners[i] != nil {
+				step.Stop[i] = l.Runners[i].Run(ctx, one)
+			} else {
+				step.Stop[i] = c.Run(ctx, one)
+			}
+			step.Registers[i] = c.Registers()
+			step.Cycles[i] = c.cycles - before
+			step.Writes[i] = l.writes[i]
+		}
+
+		trace = append(trace, step)
+		if len(trace) > window {
+			trace = trace[1:]
+		}
+
+		if diffs := step.differences(); len(diffs) > 0 {
+			l.Divergence = &Divergence{Instruction: n, Differences: diffs, Trace: trace}
+			return StopDiverged
+		}
+		if step.Stop[0] != StopInstructions {
+			return step.Stop[0]
+		}
+	}
+}
+
+/*
+differences describes each way the two sides of a step disagree.
+*/
+func (s LockstepStep) differences() []string {
+	var diffs []string
+	differ := func(name string, a, b interface{}) {
+		if a != b {
+			diffs = append(diffs, fmt.Sprintf("%s: %v != %v", name, a, b))
+		}
+	}
+
+	differ("stop", s.Stop[0], s.Stop[1])
+	ra, rb := s.Registers[0], s.Registers[1]
+	differ("PC", fmt.Sprintf("$%04X", uint16(ra.PC)), fmt.Sprintf("$%04X", uint16(rb.PC)))
+	differ("AC", fmt.Sprintf("$%02X", ra.AC), fmt.Sprintf("$%02X", rb.AC))
+	differ("X", fmt.Sprintf("$%02X", ra.X), fmt.Sprintf("$%02X", rb.X))
+	differ("Y", fmt.Sprintf("$%02X", ra.Y), fmt.Sprintf("$%02X", rb.Y))
+	differ("SP", fmt.Sprintf("$%02X", ra.SP), fmt.Sprintf("$%02X", rb.SP))
+	differ("P", flagString(ra.P), flagString(rb.P))
+	differ("cycles", s.Cycles[0], s.Cycles[1])
+	differ("writes", writesString(s.Writes[0]), writesString(s.Writes[1]))
+	return diffs
+}
+
+/*
+flagString shows a packed status register as the letters of the flags set, with dashes for those clear.
+*/
+func flagString(p byte) string {
+	var b strings.Builder
+	for i, name := range "NV-BDIZC" {
+		if p&(0x80>>uint(i)) != 0 {
+			b.WriteRune(name)
+		} else {
+			b.WriteByte('-')
+		}
+	}
+	return b.String()
+}
+
+/*
+writesString lists writes as address=value pairs.
+*/
+func writesString(writes []BusWrite) string {
+	parts := make([]string, len(writes))
+	for i, w := range writes {
+		parts[i] = fmt.Sprintf("$%04X=$%02X", uint16(w.Address), w.Value)
+	}
+	return "[" + strings.Join(parts, " ") + "]"
+}
+
+/*
+String reports the divergence: what differs, then the trace leading up to it with the state of the first Core after
+each step, and of both after the last.
+*/
+func (d *Divergence) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "diverged after %d instructions:\n", d.Instruction)
+	for _, diff := range d.Differences {
+		fmt.Fprintf(&b, "  %s\n", diff)
+	}
+	fmt.Fprintln(&b, "trace:")
+	for i, s := range d.Trace {
+		asm := "?"
+		if s.decoded {
+			asm = s.Op.Disassemble(s.PC)
+		}
+		line := func(side int) {
+			fmt.Fprintf(&b, "  %04X  %-16s %s +%d %s\n", uint16(s.PC), asm, s.Registers[side], s.Cycles[side],
+				writesString(s.Writes[side]))
+		}
+		line(0)
+		if i == len(d.Trace)-1 {
+			line(1)
+		}
+	}
+	return b.String()
+}
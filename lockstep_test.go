@@ -0,0 +1,153 @@
+package mos6502
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestLockstep(t *testing.T) {
+	t.Run("interpreter and block cache", func(t *testing.T) {
+		l := NewLockstep(programCore(t, "sieve.bin"), programCore(t, "sieve.bin"))
+		l.Runners[1] = NewBlockCache(l.Cores[1])
+		expectLoop(t, l.Cores[0], l.Run(context.Background(), Budget{StopOnLoop: true}))
+		if l.Divergence != nil {
+			t.Fatalf("Expected no divergence but got %s", l.Divergence)
+		}
+		expectSameCore(t, l.Cores[0], l.Cores[1])
+	})
+
+	t.Run("budget", func(t *testing.T) {
+		l := NewLockstep(nopCore(), nopCore())
+		expectString(t, StopInstructions.String(), l.Run(context.Background(), Budget{Instructions: 5}).String())
+		expectAddress(t, 0x0205, l.Cores[1].PC)
+	})
+}
+
+func TestLockstepDivergence(t *testing.T) {
+	code := []byte{
+		0xA2, 0x00, // 0200 LDX #$00
+		0xE8,       // 0202 INX
+		0xA5, 0x10, // 0203 LDA $10
+		0x9D, 0x00, 0x03, // 0205 STA $0300,X
+		0xBD, 0xFF, 0x03, // 0208 LDA $03FF,X
+		0x4C, 0x0B, 0x02, // 020B JMP $020B
+	}
+
+	var tests = map[string]struct {
+		setup       func(c *Core)
+		instruction uint64
+		pc          Address
+		differences []string
+	}{
+		"register": {
+			setup:       func(c *Core) { c.Bus.Write(0x10, 0x80) },
+			instruction: 2,
+			pc:          0x0203,
+			differences: []string{"AC: $00 != $80", "P: ------Z- != N-------"},
+		},
+		"write": {
+			setup:       func(c *Core) { c.Bus.Write(0x0206, 0x10) },
+			instruction: 3,
+			pc:          0x0205,
+			differences: []string{"writes: [$0301=$00] != [$0311=$00]"},
+		},
+		"cycles": {
+			setup:       func(c *Core) { c.Bus.Write(0x0209, 0x00) },
+			instruction: 4,
+			pc:          0x0208,
+			differences: []string{"cycles: 5 != 4"},
+		},
+	}
+
+	for k, tt := range tests {
+		t.Run(k, func(t *testing.T) {
+			a, b := profileCore(map[Address][]byte{0x0200: code}), profileCore(map[Address][]byte{0x0200: code})
+			tt.setup(b)
+			l := NewLockstep(a, b)
+			l.Window = 2
+			expectString(t, StopDiverged.String(), l.Run(context.Background(), Budget{}).String())
+			d := l.Divergence
+			if d == nil {
+				t.Fatal("Expected a divergence.")
+			}
+			expectUint64(t, tt.instruction, d.Instruction)
+			expectString(t, strings.Join(tt.differences, "\n"), strings.Join(d.Differences, "\n"))
+			expectUint64(t, 2, uint64(len(d.Trace)))
+			expectAddress(t, tt.pc, d.Trace[1].PC)
+		})
+	}
+}
+
+func TestDivergenceString(t *testing.T) {
+	d := &Divergence{
+		Instruction: 7,
+		Differences: []string{"AC: $00 != $80"},
+		Trace: []LockstepStep{{
+			PC:        0x0203,
+			Op:        Operation{Code: 0xA5, Byte1: 0x10},
+			decoded:   true,
+			Registers: [2]Registers{{PC: 0x0205, P: 0x22}, {PC: 0x0205, AC: 0x80, P: 0xA0}},
+			Cycles:    [2]uint64{3, 3},
+			Writes:    [2][]BusWrite{nil, {{Address: 0x0300, Value: 0x01}}},
+		}},
+	}
+
+	expectString(t, `diverged after 7 instructions:
+  AC: $00 != $80
+trace:
+  0203  LDA $10          PC=0205 AC=00 X=00 Y=00 SP=00 P=22 +3 []
+  0203  LDA $10          PC=0205 AC=80 X=00 Y=00 SP=00 P=A0 +3 [$0300=$01]
+`, d.String())
+}
+
+/*
+Test that self-modifying code detection on either Core carries on through a lockstep run and after it.
+*/
+func TestLockstepSelfModification(t *testing.T) {
+	code := map[Address][]byte{
+		0x0200: {
+			0xA9, 0x06, // 0200 LDA #$06
+			0x8D, 0x00, 0x02, // 0202 STA $0200
+			0x8D, 0x03, 0x02, // 0205 STA $0203
+			0x4C, 0x08, 0x02, // 0208 JMP $0208
+		},
+	}
+	l := NewLockstep(profileCore(code), profileCore(code))
+	var found [2][]SelfModification
+	for i, c := range l.Cores {
+		i := i
+		c.DetectSelfModification(func(m SelfModification) {
+			found[i] = append(found[i], m)
+		})
+	}
+
+	expectString(t, StopInstructions.String(), l.Run(context.Background(), Budget{Instructions: 2}).String())
+	for i := range l.Cores {
+		expectUint64(t, 1, uint64(len(found[i])))
+	}
+
+	c := l.Cores[0]
+	expectLoop(t, c, c.Run(context.Background(), Budget{Instructions: 10, StopOnLoop: true}))
+	expected := []SelfModification{
+		{PC: 0x0202, Address: 0x0200, Old: 0xA9, New: 0x06, Opcode: true},
+		{PC: 0x0205, Address: 0x0203, Old: 0x00, New: 0x06},
+	}
+	if len(found[0]) != len(expected) || found[0][0] != expected[0] || found[0][1] != expected[1] {
+		t.Errorf("Expected %v but got %v.", expected, found[0])
+	}
+}
+
+/*
+Test that the trace a Lockstep keeps doesn't add reads of its own to the first Core's.
+*/
+func TestLockstepReads(t *testing.T) {
+	alone, stepped := observedCore(), observedCore()
+	expected, actual := &recorder{}, &recorder{}
+	alone.Observe(expected)
+	stepped.Observe(actual)
+
+	alone.Run(context.Background(), Budget{Instructions: 3})
+	NewLockstep(stepped, observedCore()).Run(context.Background(), Budget{Instructions: 3})
+	expectString(t, strings.Join(expected.events, "\n"), strings.Join(actual.events, "\n"))
+}
@@ -0,0 +1,273 @@
+/*
+Package mos6502test helps unit test 6502 routines from Go. A Program holds an assembled image; each test case loads
+it into a fresh Core, sets up registers and memory, calls a subroutine until it returns, and checks the registers,
+flags and memory it leaves behind, reporting every difference found.
+
+	prog := mos6502test.Program{Origin: 0x0200, Image: image}
+	prog.Test(t, 0x0200, map[string]mos6502test.Case{
+		"carry out": {
+			In:   mos6502test.Registers{AC: mos6502test.Byte(0xFF), Flags: "c"},
+			Out:  mos6502test.Registers{AC: mos6502test.Byte(0x00), Flags: "ZC"},
+			Want: mos6502test.Memory{0x0300: {0x00, 0x01}},
+		},
+	})
+*/
+package mos6502test
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/jakew/mos6502"
+)
+
+/*
+DefaultLimit is how many cycles a call may run for when a Case doesn't give a limit.
+*/
+const DefaultLimit = 1000000
+
+/*
+Byte returns a pointer to a byte, for the registers of a Case.
+*/
+func Byte(b byte) *byte {
+	return &b
+}
+
+/*
+Registers are values for the registers to be set to before a call, or expected after it. Nil registers are left as
+they are, or not checked.
+
+Flags lists flags by letter, from NVDIZC: upper case for flags set and lower case for flags clear. Flags not listed
+are left as they are, or not checked.
+*/
+type Registers struct {
+	AC *byte
+	X  *byte
+	Y  *byte
+
+	Flags string
+}
+
+/*
+Memory is runs of bytes, each placed at or expected from its address onwards.
+*/
+type Memory map[mos6502.Address][]byte
+
+/*
+Case is a test of a call to a routine.
+*/
+type Case struct {
+	// Registers and memory set up before the call.
+	In     Registers
+	Memory Memory
+
+	// Registers and memory expected after the call.
+	Out  Registers
+	Want Memory
+
+	// The exact number of cycles the call should take, including the final RTS, if not zero.
+	Cycles uint64
+
+	// Most cycles the call may take before it is failed. Zero allows DefaultLimit.
+	Limit uint64
+}
+
+/*
+Program is an assembled image, loaded at its origin into every Core made from it.
+*/
+type Program struct {
+	Origin mos6502.Address
+	Image  []byte
+}
+
+/*
+Load reads a program image from a file, failing the test if it can't.
+*/
+func Load(t testing.TB, origin mos6502.Address, path string) Program {
+	t.Helper()
+	image, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Couldn't load %s: %v", path, err)
+	}
+	return Program{Origin: origin, Image: image}
+}
+
+/*
+Core returns a fresh Core with the program loaded and the stack pointer at the top of the stack.
+*/
+func (p Program) Core() *mos6502.Core {
+	c := &mos6502.Core{SP: 0xFF}
+	for i, b := range p.Image {
+		c.Bus.Write(p.Origin+mos6502.Address(i), b)
+	}
+	return c
+}
+
+/*
+Test runs each case as a subtest, calling the routine at entry in a fresh Core.
+*/
+func (p Program) Test(t *testing.T, entry mos6502.Address, cases map[string]Case) {
+	t.Helper()
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			p.Call(t, entry, tc)
+		})
+	}
+}
+
+/*
+Call runs a case in a fresh Core, calling the routine at entry with Core.CallContext and checking what it leaves behind.
+A routine which gets stuck on an instruction jumping to itself fails straight away rather than using up the limit. The
+Core is returned for any further checks.
+*/
+func (p Program) Call(t testing.TB, entry mos6502.Address, tc Case) *mos6502.Core {
+	t.Helper()
+	c := p.Core()
+	for a, bytes := range tc.Memory {
+		for i, b := range bytes {
+			c.Bus.Write(a+mos6502.Address(i), b)
+		}
+	}
+	setRegisters(t, c, tc.In)
+
+	limit := tc.Limit
+	if limit == 0 {
+		limit = DefaultLimit
+	}
+	_, cycles, err := c.CallContext(context.Background(), mos6502.Budget{Cycles: limit, StopOnLoop: true}, entry)
+	if err != nil {
+		t.Fatalf("Calling $%04X: %v", uint16(entry), err)
+	}
+
+	checkRegisters(t, c, tc.Out)
+	checkMemory(t, c, tc.Want)
+	if tc.Cycles != 0 && cycles != tc.Cycles {
+		t.Errorf("cycles: expected %d, got %d", tc.Cycles, cycles)
+	}
+	return c
+}
+
+/*
+flags returns the Core's flags by letter.
+*/
+func flags(c *mos6502.Core) map[rune]*bool {
+	return map[rune]*bool{
+		'N': &c.Negative,
+		'V': &c.Overflow,
+		'D': &c.Decimal,
+		'I': &c.Interrupt,
+		'Z': &c.Zero,
+		'C': &c.Carry,
+	}
+}
+
+/*
+setRegisters sets the registers given, failing the test for a flag letter it doesn't know.
+*/
+func setRegisters(t testing.TB, c *mos6502.Core, r Registers) {
+	t.Helper()
+	for _, set := range []struct {
+		value *byte
+		reg   *byte
+	}{{r.AC, &c.AC}, {r.X, &c.X}, {r.Y, &c.Y}} {
+		if set.value != nil {
+			*set.reg = *set.value
+		}
+	}
+
+	f := flags(c)
+	for _, l := range r.Flags {
+		flag, ok := f[unicode.ToUpper(l)]
+		if !ok {
+			t.Fatalf("Unknown flag %q in %q.", l, r.Flags)
+		}
+		*flag = l == unicode.ToUpper(l)
+	}
+}
+
+/*
+checkRegisters reports each register and flag which isn't as expected.
+*/
+func checkRegisters(t testing.TB, c *mos6502.Core, r Registers) {
+	t.Helper()
+	for _, check := range []struct {
+		name     string
+		expected *byte
+		actual   byte
+	}{{"AC", r.AC, c.AC}, {"X", r.X, c.X}, {"Y", r.Y, c.Y}} {
+		if check.expected != nil && *check.expected != check.actual {
+			t.Errorf("%s: expected $%02X, got $%02X", check.name, *check.expected, check.actual)
+		}
+	}
+
+	f := flags(c)
+	for _, l := range r.Flags {
+		flag, ok := f[unicode.ToUpper(l)]
+		if !ok {
+			t.Fatalf("Unknown flag %q in %q.", l, r.Flags)
+		}
+		if set := l == unicode.ToUpper(l); *flag != set {
+			t.Errorf("flag %c: expected %s, got %s (flags %s)", unicode.ToUpper(l), setOrClear(set), setOrClear(*flag),
+				flagString(c))
+		}
+	}
+}
+
+/*
+checkMemory reports each run of memory which isn't as expected, marking the bytes which differ.
+*/
+func checkMemory(t testing.TB, c *mos6502.Core, want Memory) {
+	t.Helper()
+	for a, expected := range want {
+		actual := make([]byte, len(expected))
+		differ := false
+		for i := range expected {
+			actual[i] = c.Bus.Read(a + mos6502.Address(i))
+			differ = differ || actual[i] != expected[i]
+		}
+		if !differ {
+			continue
+		}
+
+		var marks strings.Builder
+		for i := range expected {
+			if actual[i] != expected[i] {
+				marks.WriteString(" ^^")
+			} else {
+				marks.WriteString("   ")
+			}
+		}
+		t.Errorf("memory at $%04X:\n  expected % X\n       got % X\n           %s", uint16(a), expected, actual,
+			strings.TrimRight(marks.String()[1:], " "))
+	}
+}
+
+/*
+flagString shows the Core's flags as NV--DIZC, with dashes for those clear.
+*/
+func flagString(c *mos6502.Core) string {
+	var b strings.Builder
+	f := flags(c)
+	for _, l := range "NV--DIZC" {
+		if flag, ok := f[l]; ok && *flag {
+			b.WriteRune(l)
+		} else {
+			b.WriteByte('-')
+		}
+	}
+	return b.String()
+}
+
+/*
+setOrClear describes a flag.
+*/
+func setOrClear(set bool) string {
+	if set {
+		return "set"
+	}
+	return "clear"
+}
@@ -0,0 +1,149 @@
+package mos6502test
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jakew/mos6502"
+)
+
+/*
+add16 adds the 16 bit numbers at $10 and $12 into $14, returning the carry.
+*/
+var add16 = Program{Origin: 0x0200, Image: []byte{
+	0x18,       // 0200 CLC
+	0xA5, 0x10, // 0201 LDA $10
+	0x65, 0x12, // 0203 ADC $12
+	0x85, 0x14, // 0205 STA $14
+	0xA5, 0x11, // 0207 LDA $11
+	0x65, 0x13, // 0209 ADC $13
+	0x85, 0x15, // 020B STA $15
+	0x60, // 020D RTS
+}}
+
+/*
+recorder is a testing.TB which keeps the failures reported to it.
+*/
+type recorder struct {
+	testing.TB
+	mu       sync.Mutex
+	failures []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Fatalf(format string, args ...interface{}) {
+	r.Errorf(format, args...)
+	runtime.Goexit()
+}
+
+/*
+failures runs a case against a recorder, returning what it reported.
+*/
+func failures(t *testing.T, p Program, entry mos6502.Address, tc Case) []string {
+	r := &recorder{TB: t}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.Call(r, entry, tc)
+	}()
+	<-done
+	return r.failures
+}
+
+func TestProgram(t *testing.T) {
+	add16.Test(t, 0x0200, map[string]Case{
+		"no carry": {
+			Memory: Memory{0x10: {0x34, 0x12, 0x11, 0x11}},
+			Out:    Registers{AC: Byte(0x23), Flags: "c"},
+			Want:   Memory{0x14: {0x45, 0x23}},
+			Cycles: 2 + 6*3 + 6,
+		},
+		"carry out": {
+			In:     Registers{Flags: "C"},
+			Memory: Memory{0x10: {0xFF, 0xFF, 0x01, 0x00}},
+			Out:    Registers{AC: Byte(0x00), Flags: "ZCn"},
+			Want:   Memory{0x14: {0x00, 0x00}},
+		},
+	})
+}
+
+func TestCallFailures(t *testing.T) {
+	var tests = map[string]struct {
+		program  Program
+		tc       Case
+		expected []string
+	}{
+		"registers": {
+			program: add16,
+			tc:      Case{Out: Registers{AC: Byte(0x01), X: Byte(0x00), Flags: "zC"}},
+			expected: []string{
+				"AC: expected $01, got $00",
+				"flag Z: expected clear, got set (flags ------Z-)",
+				"flag C: expected set, got clear (flags ------Z-)",
+			},
+		},
+		"memory": {
+			program: add16,
+			tc:      Case{Memory: Memory{0x10: {0x01, 0x00, 0x02}}, Want: Memory{0x14: {0x03, 0x01}}},
+			expected: []string{"memory at $0014:\n" +
+				"  expected 03 01\n" +
+				"       got 03 00\n" +
+				"              ^^"},
+		},
+		"cycles": {
+			program:  add16,
+			tc:       Case{Cycles: 10},
+			expected: []string{"cycles: expected 10, got 26"},
+		},
+		"limit": {
+			program:  Program{Origin: 0x0200, Image: []byte{0x4C, 0x03, 0x02, 0x4C, 0x00, 0x02}},
+			tc:       Case{Limit: 100},
+			expected: []string{"Calling $0200: mos6502: call stopped with cycle budget exhausted at $0200"},
+		},
+		"loop": {
+			program:  Program{Origin: 0x0200, Image: []byte{0x4C, 0x00, 0x02}},
+			expected: []string{"Calling $0200: mos6502: call stopped with loop at $0200"},
+		},
+		"unknown flag": {
+			program:  add16,
+			tc:       Case{In: Registers{Flags: "Q"}},
+			expected: []string{`Unknown flag 'Q' in "Q".`},
+		},
+	}
+
+	for k, tt := range tests {
+		t.Run(k, func(t *testing.T) {
+			got := failures(t, tt.program, 0x0200, tt.tc)
+			if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+				t.Errorf("Expected failures:\n%s\nbut got:\n%s", strings.Join(tt.expected, "\n"), strings.Join(got, "\n"))
+			}
+		})
+	}
+}
+
+func TestRecursion(t *testing.T) {
+	// Counts X down to zero, calling itself each time, so it reaches the return address with the stack still deep
+	// only if something is wrong.
+	p := Program{Origin: 0x0200, Image: []byte{
+		0xE0, 0x00, // 0200 CPX #$00
+		0xF0, 0x04, // 0202 BEQ $0208
+		0xCA,             // 0204 DEX
+		0x20, 0x00, 0x02, // 0205 JSR $0200
+		0xC8, // 0208 INY
+		0x60, // 0209 RTS
+	}}
+	c := p.Call(t, 0x0200, Case{In: Registers{X: Byte(5), Y: Byte(0)}, Out: Registers{X: Byte(0), Y: Byte(6)}})
+	if c.SP != 0xFF {
+		t.Errorf("Expected the stack back at FF but got %02X.", c.SP)
+	}
+}
@@ -0,0 +1,77 @@
+package mos6502
+
+/*
+Observer is told what a Core does as it runs, so tracers and other tools can follow it without changing how it runs.
+Embed NopObserver to implement only the events wanted.
+*/
+type Observer interface {
+	// An instruction was fetched from pc and is about to run.
+	Fetched(c *Core, pc Address, op Operation)
+
+	// The instruction fetched from pc has run, taking the given cycles.
+	Retired(c *Core, pc Address, op Operation, cycles int)
+
+	// A byte was read from or written to the Bus, including the fetches of instructions and the stack.
+	Read(a Address, d byte)
+	Written(a Address, d byte)
+
+	// An IRQ or NMI was taken, and is about to run from the address in its vector.
+	Interrupted(c *Core, vector Address)
+
+	// The processor was reset.
+	Reset(c *Core)
+}
+
+/*
+NopObserver ignores every event.
+*/
+type NopObserver struct{}
+
+func (NopObserver) Fetched(c *Core, pc Address, op Operation)             {}
+func (NopObserver) Retired(c *Core, pc Address, op Operation, cycles int) {}
+func (NopObserver) Read(a Address, d byte)                                {}
+func (NopObserver) Written(a Address, d byte)                             {}
+func (NopObserver) Interrupted(c *Core, vector Address)                   {}
+func (NopObserver) Reset(c *Core)                                         {}
+
+/*
+Observe attaches an observer. Observers are told of events in the order they were attached. Without any the Core
+doesn't look for them beyond checking there are none, and its Bus keeps to its fast path; with any, every access goes
+through the slow path and a BlockCache leaves all the running to the interpreter.
+*/
+func (c *Core) Observe(o Observer) {
+	c.observers = append(c.observers, o)
+	if len(c.observers) == 1 {
+		c.Bus.trace(c.busEvent)
+	}
+}
+
+/*
+Unobserve detaches an observer attached with Observe. Observers are told apart with ==, so must be comparable, as
+pointers are.
+*/
+func (c *Core) Unobserve(o Observer) {
+	for i, obs := range c.observers {
+		if obs == o {
+			c.observers = append(c.observers[:i:i], c.observers[i+1:]...)
+			break
+		}
+	}
+	if len(c.observers) == 0 {
+		c.observers = nil
+		c.Bus.trace(nil)
+	}
+}
+
+/*
+busEvent tells the observers of a read or write.
+*/
+func (c *Core) busEvent(a Address, d byte, write bool) {
+	for _, o := range c.observers {
+		if write {
+			o.Written(a, d)
+		} else {
+			o.Read(a, d)
+		}
+	}
+}
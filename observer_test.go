@@ -0,0 +1,214 @@
+package mos6502
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+/*
+recorder notes every event it is told of.
+*/
+type recorder struct {
+	events []string
+}
+
+func (r *recorder) Fetched(c *Core, pc Address, op Operation) {
+	r.events = append(r.events, fmt.Sprintf("fetch %04X %s", uint16(pc), op.Disassemble(pc)))
+}
+
+func (r *recorder) Retired(c *Core, pc Address, op Operation, cycles int) {
+	r.events = append(r.events, fmt.Sprintf("retire %04X +%d", uint16(pc), cycles))
+}
+
+func (r *recorder) Read(a Address, d byte) {
+	r.events = append(r.events, fmt.Sprintf("read %04X=%02X", uint16(a), d))
+}
+
+func (r *recorder) Written(a Address, d byte) {
+	r.events = append(r.events, fmt.Sprintf("write %04X=%02X", uint16(a), d))
+}
+
+func (r *recorder) Interrupted(c *Core, vector Address) {
+	r.events = append(r.events, fmt.Sprintf("interrupt %04X to %04X", uint16(vector), uint16(c.PC)))
+}
+
+func (r *recorder) Reset(c *Core) {
+	r.events = append(r.events, fmt.Sprintf("reset to %04X", uint16(c.PC)))
+}
+
+/*
+observedCore returns a Core about to store a byte and loop, with an IRQ handler at $0300.
+*/
+func observedCore() *Core {
+	return profileCore(map[Address][]byte{
+		0x0200: {
+			0xA9, 0x01, // 0200 LDA #$01
+			0x85, 0x10, // 0202 STA $10
+			0x4C, 0x04, 0x02, // 0204 JMP $0204
+		},
+		0x0300: {0x40},       // 0300 RTI
+		0xFFFC: {0x00, 0x02}, // RESET
+		0xFFFE: {0x00, 0x03}, // IRQ
+	})
+}
+
+func TestObserverEvents(t *testing.T) {
+	c := observedCore()
+	r := &recorder{}
+	c.Observe(r)
+
+	c.Step()
+	c.Step()
+	irq := line(true)
+	c.ConnectIRQ(&irq)
+	c.Step()
+	c.Reset()
+
+	expectString(t, strings.Join([]string{
+		"read 0200=A9",
+		"read 0201=01",
+		"fetch 0200 LDA #$01",
+		"retire 0200 +2",
+		"read 0202=85",
+		"read 0203=10",
+		"fetch 0202 STA $10",
+		"write 0010=01",
+		"retire 0202 +3",
+		"write 01FF=02",
+		"write 01FE=04",
+		"write 01FD=20",
+		"read FFFF=03",
+		"read FFFE=00",
+		"interrupt FFFE to 0300",
+		"read FFFD=02",
+		"read FFFC=00",
+		"reset to 0200",
+	}, "\n"), strings.Join(r.events, "\n"))
+}
+
+/*
+Test that running reads each instruction once, whatever runs it.
+*/
+func TestObserverRun(t *testing.T) {
+	var tests = map[string]struct {
+		run    func(t *testing.T, c *Core)
+		before []string
+	}{
+		"run": {run: func(t *testing.T, c *Core) { c.Run(context.Background(), Budget{StopOnLoop: true}) }},
+		"block cache": {run: func(t *testing.T, c *Core) {
+			NewBlockCache(c).Run(context.Background(), Budget{StopOnLoop: true})
+		}},
+		"call": {
+			run: func(t *testing.T, c *Core) {
+				_, _, err := c.CallContext(context.Background(), Budget{StopOnLoop: true}, 0x0200)
+				expectBool(t, true, err != nil)
+			},
+			before: []string{"write 01FF=FF", "write 01FE=FF"},
+		},
+		"profiler": {run: func(t *testing.T, c *Core) {
+			NewProfiler(c)
+			c.Run(context.Background(), Budget{StopOnLoop: true})
+		}},
+		"coverage": {run: func(t *testing.T, c *Core) {
+			NewCoverage(c)
+			c.Run(context.Background(), Budget{StopOnLoop: true})
+		}},
+		"stack tracker": {run: func(t *testing.T, c *Core) {
+			NewStackTracker(c)
+			c.Run(context.Background(), Budget{StopOnLoop: true})
+		}},
+	}
+
+	for k, tt := range tests {
+		t.Run(k, func(t *testing.T) {
+			c := observedCore()
+			r := &recorder{}
+			c.Observe(r)
+			tt.run(t, c)
+
+			expectString(t, strings.Join(append(tt.before,
+				"read 0200=A9",
+				"read 0201=01",
+				"fetch 0200 LDA #$01",
+				"retire 0200 +2",
+				"read 0202=85",
+				"read 0203=10",
+				"fetch 0202 STA $10",
+				"write 0010=01",
+				"retire 0202 +3",
+				"read 0204=4C",
+				"read 0205=04",
+				"read 0206=02",
+				"fetch 0204 JMP $0204",
+				"retire 0204 +3",
+			), "\n"), strings.Join(r.events, "\n"))
+		})
+	}
+}
+
+func TestObserverDetached(t *testing.T) {
+	c := observedCore()
+	fastRead, fastWrite := c.Bus.fastRead, c.Bus.fastWrite
+
+	a, b := &recorder{}, &recorder{}
+	c.Observe(a)
+	c.Observe(b)
+	expectBool(t, false, c.Bus.fastRead[0x02])
+
+	c.Step()
+	c.Unobserve(a)
+	c.Step()
+	expectUint64(t, 4, uint64(len(a.events)))
+	expectUint64(t, 9, uint64(len(b.events)))
+
+	c.Unobserve(b)
+	c.Step()
+	expectUint64(t, 9, uint64(len(b.events)))
+	expectBool(t, true, c.Bus.fastRead == fastRead)
+	expectBool(t, true, c.Bus.fastWrite == fastWrite)
+
+	// The tools built on observers let go when closed.
+	NewProfiler(c).Close()
+	NewCoverage(c).Close()
+	NewStackTracker(c).Close()
+	expectBool(t, true, c.Bus.fastRead == fastRead)
+	expectUint64(t, 0, uint64(len(c.observers)))
+}
+
+/*
+retirements counts the instructions it sees retire.
+*/
+type retirements struct {
+	NopObserver
+	retired int
+}
+
+func (n *retirements) Retired(c *Core, pc Address, op Operation, cycles int) {
+	n.retired++
+}
+
+func TestObserverRunners(t *testing.T) {
+	var tests = map[string]func(c *Core) Runner{
+		"interpreter":   func(c *Core) Runner { return c },
+		"block cache":   func(c *Core) Runner { return NewBlockCache(c) },
+		"profiled":      func(c *Core) Runner { NewProfiler(c); return c },
+		"covered":       func(c *Core) Runner { NewCoverage(c); return c },
+		"stack tracked": func(c *Core) Runner { NewStackTracker(c); return c },
+	}
+
+	for k, runner := range tests {
+		t.Run(k, func(t *testing.T) {
+			c := printCore()
+			c.SetTrap(0xFFD2, func(c *Core) TrapAction { return TrapReturn })
+			n := &retirements{}
+			c.Observe(n)
+
+			expectLoop(t, c, runner(c).Run(context.Background(), Budget{Instructions: 1000, StopOnLoop: true}))
+
+			// The loop runs once for each character, then loads and branches out to the JMP; the trap isn't an instruction.
+			expectUint64(t, 1+5*5+2+1, uint64(n.retired))
+		})
+	}
+}
@@ -0,0 +1,435 @@
+package mos6502
+
+/*
+opcode describes one of the 256 instructions: its mnemonic, how it addresses its operand and how many cycles it takes.
+*/
+type opcode struct {
+	name   string
+	mode   AddressType
+	size   int8
+	cycles uint8
+
+	// Reads which take a cycle more when indexing crosses a page.
+	page bool
+
+	// Opcodes left out of the datasheet, which behave as the NMOS parts do.
+	undocumented bool
+}
+
+/*
+opcodes is the full instruction set, documented or not, indexed by opcode.
+*/
+var opcodes = [256]opcode{
+	0x00: {name: "BRK", mode: impl, cycles: 7},
+	0x01: {name: "ORA", mode: xInd, cycles: 6},
+	0x02: {name: "JAM", mode: impl, cycles: 2, undocumented: true},
+	0x03: {name: "SLO", mode: xInd, cycles: 8, undocumented: true},
+	0x04: {name: "NOP", mode: zpg, cycles: 3, undocumented: true},
+	0x05: {name: "ORA", mode: zpg, cycles: 3},
+	0x06: {name: "ASL", mode: zpg, cycles: 5},
+	0x07: {name: "SLO", mode: zpg, cycles: 5, undocumented: true},
+	0x08: {name: "PHP", mode: impl, cycles: 3},
+	0x09: {name: "ORA", mode: imm, cycles: 2},
+	0x0A: {name: "ASL", mode: a, cycles: 2},
+	0x0B: {name: "ANC", mode: imm, cycles: 2, undocumented: true},
+	0x0C: {name: "NOP", mode: abs, cycles: 4, undocumented: true},
+	0x0D: {name: "ORA", mode: abs, cycles: 4},
+	0x0E: {name: "ASL", mode: abs, cycles: 6},
+	0x0F: {name: "SLO", mode: abs, cycles: 6, undocumented: true},
+	0x10: {name: "BPL", mode: rel, cycles: 2},
+	0x11: {name: "ORA", mode: indY, cycles: 5, page: true},
+	0x12: {name: "JAM", mode: impl, cycles: 2, undocumented: true},
+	0x13: {name: "SLO", mode: indY, cycles: 8, undocumented: true},
+	0x14: {name: "NOP", mode: zpgX, cycles: 4, undocumented: true},
+	0x15: {name: "ORA", mode: zpgX, cycles: 4},
+	0x16: {name: "ASL", mode: zpgX, cycles: 6},
+	0x17: {name: "SLO", mode: zpgX, cycles: 6, undocumented: true},
+	0x18: {name: "CLC", mode: impl, cycles: 2},
+	0x19: {name: "ORA", mode: absY, cycles: 4, page: true},
+	0x1A: {name: "NOP", mode: impl, cycles: 2, undocumented: true},
+	0x1B: {name: "SLO", mode: absY, cycles: 7, undocumented: true},
+	0x1C: {name: "NOP", mode: absX, cycles: 4, page: true, undocumented: true},
+	0x1D: {name: "ORA", mode: absX, cycles: 4, page: true},
+	0x1E: {name: "ASL", mode: absX, cycles: 7},
+	0x1F: {name: "SLO", mode: absX, cycles: 7, undocumented: true},
+	0x20: {name: "JSR", mode: abs, cycles: 6},
+	0x21: {name: "AND", mode: xInd, cycles: 6},
+	0x22: {name: "JAM", mode: impl, cycles: 2, undocumented: true},
+	0x23: {name: "RLA", mode: xInd, cycles: 8, undocumented: true},
+	0x24: {name: "BIT", mode: zpg, cycles: 3},
+	0x25: {name: "AND", mode: zpg, cycles: 3},
+	0x26: {name: "ROL", mode: zpg, cycles: 5},
+	0x27: {name: "RLA", mode: zpg, cycles: 5, undocumented: true},
+	0x28: {name: "PLP", mode: impl, cycles: 4},
+	0x29: {name: "AND", mode: imm, cycles: 2},
+	0x2A: {name: "ROL", mode: a, cycles: 2},
+	0x2B: {name: "ANC", mode: imm, cycles: 2, undocumented: true},
+	0x2C: {name: "BIT", mode: abs, cycles: 4},
+	0x2D: {name: "AND", mode: abs, cycles: 4},
+	0x2E: {name: "ROL", mode: abs, cycles: 6},
+	0x2F: {name: "RLA", mode: abs, cycles: 6, undocumented: true},
+	0x30: {name: "BMI", mode: rel, cycles: 2},
+	0x31: {name: "AND", mode: indY, cycles: 5, page: true},
+	0x32: {name: "JAM", mode: impl, cycles: 2, undocumented: true},
+	0x33: {name: "RLA", mode: indY, cycles: 8, undocumented: true},
+	0x34: {name: "NOP", mode: zpgX, cycles: 4, undocumented: true},
+	0x35: {name: "AND", mode: zpgX, cycles: 4},
+	0x36: {name: "ROL", mode: zpgX, cycles: 6},
+	0x37: {name: "RLA", mode: zpgX, cycles: 6, undocumented: true},
+	0x38: {name: "SEC", mode: impl, cycles: 2},
+	0x39: {name: "AND", mode: absY, cycles: 4, page: true},
+	0x3A: {name: "NOP", mode: impl, cycles: 2, undocumented: true},
+	0x3B: {name: "RLA", mode: absY, cycles: 7, undocumented: true},
+	0x3C: {name: "NOP", mode: absX, cycles: 4, page: true, undocumented: true},
+	0x3D: {name: "AND", mode: absX, cycles: 4, page: true},
+	0x3E: {name: "ROL", mode: absX, cycles: 7},
+	0x3F: {name: "RLA", mode: absX, cycles: 7, undocumented: true},
+	0x40: {name: "RTI", mode: impl, cycles: 6},
+	0x41: {name: "EOR", mode: xInd, cycles: 6},
+	0x42: {name: "JAM", mode: impl, cycles: 2, undocumented: true},
+	0x43: {name: "SRE", mode: xInd, cycles: 8, undocumented: true},
+	0x44: {name: "NOP", mode: zpg, cycles: 3, undocumented: true},
+	0x45: {name: "EOR", mode: zpg, cycles: 3},
+	0x46: {name: "LSR", mode: zpg, cycles: 5},
+	0x47: {name: "SRE", mode: zpg, cycles: 5, undocumented: true},
+	0x48: {name: "PHA", mode: impl, cycles: 3},
+	0x49: {name: "EOR", mode: imm, cycles: 2},
+	0x4A: {name: "LSR", mode: a, cycles: 2},
+	0x4B: {name: "ALR", mode: imm, cycles: 2, undocumented: true},
+	0x4C: {name: "JMP", mode: abs, cycles: 3},
+	0x4D: {name: "EOR", mode: abs, cycles: 4},
+	0x4E: {name: "LSR", mode: abs, cycles: 6},
+	0x4F: {name: "SRE", mode: abs, cycles: 6, undocumented: true},
+	0x50: {name: "BVC", mode: rel, cycles: 2},
+	0x51: {name: "EOR", mode: indY, cycles: 5, page: true},
+	0x52: {name: "JAM", mode: impl, cycles: 2, undocumented: true},
+	0x53: {name: "SRE", mode: indY, cycles: 8, undocumented: true},
+	0x54: {name: "NOP", mode: zpgX, cycles: 4, undocumented: true},
+	0x55: {name: "EOR", mode: zpgX, cycles: 4},
+	0x56: {name: "LSR", mode: zpgX, cycles: 6},
+	0x57: {name: "SRE", mode: zpgX, cycles: 6, undocumented: true},
+	0x58: {name: "CLI", mode: impl, cycles: 2},
+	0x59: {name: "EOR", mode: absY, cycles: 4, page: true},
+	0x5A: {name: "NOP", mode: impl, cycles: 2, undocumented: true},
+	0x5B: {name: "SRE", mode: absY, cycles: 7, undocumented: true},
+	0x5C: {name: "NOP", mode: absX, cycles: 4, page: true, undocumented: true},
+	0x5D: {name: "EOR", mode: absX, cycles: 4, page: true},
+	0x5E: {name: "LSR", mode: absX, cycles: 7},
+	0x5F: {name: "SRE", mode: absX, cycles: 7, undocumented: true},
+	0x60: {name: "RTS", mode: impl, cycles: 6},
+	0x61: {name: "ADC", mode: xInd, cycles: 6},
+	0x62: {name: "JAM", mode: impl, cycles: 2, undocumented: true},
+	0x63: {name: "RRA", mode: xInd, cycles: 8, undocumented: true},
+	0x64: {name: "NOP", mode: zpg, cycles: 3, undocumented: true},
+	0x65: {name: "ADC", mode: zpg, cycles: 3},
+	0x66: {name: "ROR", mode: zpg, cycles: 5},
+	0x67: {name: "RRA", mode: zpg, cycles: 5, undocumented: true},
+	0x68: {name: "PLA", mode: impl, cycles: 4},
+	0x69: {name: "ADC", mode: imm, cycles: 2},
+	0x6A: {name: "ROR", mode: a, cycles: 2},
+	0x6B: {name: "ARR", mode: imm, cycles: 2, undocumented: true},
+	0x6C: {name: "JMP", mode: ind, cycles: 5},
+	0x6D: {name: "ADC", mode: abs, cycles: 4},
+	0x6E: {name: "ROR", mode: abs, cycles: 6},
+	0x6F: {name: "RRA", mode: abs, cycles: 6, undocumented: true},
+	0x70: {name: "BVS", mode: rel, cycles: 2},
+	0x71: {name: "ADC", mode: indY, cycles: 5, page: true},
+	0x72: {name: "JAM", mode: impl, cycles: 2, undocumented: true},
+	0x73: {name: "RRA", mode: indY, cycles: 8, undocumented: true},
+	0x74: {name: "NOP", mode: zpgX, cycles: 4, undocumented: true},
+	0x75: {name: "ADC", mode: zpgX, cycles: 4},
+	0x76: {name: "ROR", mode: zpgX, cycles: 6},
+	0x77: {name: "RRA", mode: zpgX, cycles: 6, undocumented: true},
+	0x78: {name: "SEI", mode: impl, cycles: 2},
+	0x79: {name: "ADC", mode: absY, cycles: 4, page: true},
+	0x7A: {name: "NOP", mode: impl, cycles: 2, undocumented: true},
+	0x7B: {name: "RRA", mode: absY, cycles: 7, undocumented: true},
+	0x7C: {name: "NOP", mode: absX, cycles: 4, page: true, undocumented: true},
+	0x7D: {name: "ADC", mode: absX, cycles: 4, page: true},
+	0x7E: {name: "ROR", mode: absX, cycles: 7},
+	0x7F: {name: "RRA", mode: absX, cycles: 7, undocumented: true},
+	0x80: {name: "NOP", mode: imm, cycles: 2, undocumented: true},
+	0x81: {name: "STA", mode: xInd, cycles: 6},
+	0x82: {name: "NOP", mode: imm, cycles: 2, undocumented: true},
+	0x83: {name: "SAX", mode: xInd, cycles: 6, undocumented: true},
+	0x84: {name: "STY", mode: zpg, cycles: 3},
+	0x85: {name: "STA", mode: zpg, cycles: 3},
+	0x86: {name: "STX", mode: zpg, cycles: 3},
+	0x87: {name: "SAX", mode: zpg, cycles: 3, undocumented: true},
+	0x88: {name: "DEY", mode: impl, cycles: 2},
+	0x89: {name: "NOP", mode: imm, cycles: 2, undocumented: true},
+	0x8A: {name: "TXA", mode: impl, cycles: 2},
+	0x8B: {name: "ANE", mode: imm, cycles: 2, undocumented: true},
+	0x8C: {name: "STY", mode: abs, cycles: 4},
+	0x8D: {name: "STA", mode: abs, cycles: 4},
+	0x8E: {name: "STX", mode: abs, cycles: 4},
+	0x8F: {name: "SAX", mode: abs, cycles: 4, undocumented: true},
+	0x90: {name: "BCC", mode: rel, cycles: 2},
+	0x91: {name: "STA", mode: indY, cycles: 6},
+	0x92: {name: "JAM", mode: impl, cycles: 2, undocumented: true},
+	0x93: {name: "SHA", mode: indY, cycles: 6, undocumented: true},
+	0x94: {name: "STY", mode: zpgX, cycles: 4},
+	0x95: {name: "STA", mode: zpgX, cycles: 4},
+	0x96: {name: "STX", mode: zpgY, cycles: 4},
+	0x97: {name: "SAX", mode: zpgY, cycles: 4, undocumented: true},
+	0x98: {name: "TYA", mode: impl, cycles: 2},
+	0x99: {name: "STA", mode: absY, cycles: 5},
+	0x9A: {name: "TXS", mode: impl, cycles: 2},
+	0x9B: {name: "TAS", mode: absY, cycles: 5, undocumented: true},
+	0x9C: {name: "SHY", mode: absX, cycles: 5, undocumented: true},
+	0x9D: {name: "STA", mode: absX, cycles: 5},
+	0x9E: {name: "SHX", mode: absY, cycles: 5, undocumented: true},
+	0x9F: {name: "SHA", mode: absY, cycles: 5, undocumented: true},
+	0xA0: {name: "LDY", mode: imm, cycles: 2},
+	0xA1: {name: "LDA", mode: xInd, cycles: 6},
+	0xA2: {name: "LDX", mode: imm, cycles: 2},
+	0xA3: {name: "LAX", mode: xInd, cycles: 6, undocumented: true},
+	0xA4: {name: "LDY", mode: zpg, cycles: 3},
+	0xA5: {name: "LDA", mode: zpg, cycles: 3},
+	0xA6: {name: "LDX", mode: zpg, cycles: 3},
+	0xA7: {name: "LAX", mode: zpg, cycles: 3, undocumented: true},
+	0xA8: {name: "TAY", mode: impl, cycles: 2},
+	0xA9: {name: "LDA", mode: imm, cycles: 2},
+	0xAA: {name: "TAX", mode: impl, cycles: 2},
+	0xAB: {name: "LXA", mode: imm, cycles: 2, undocumented: true},
+	0xAC: {name: "LDY", mode: abs, cycles: 4},
+	0xAD: {name: "LDA", mode: abs, cycles: 4},
+	0xAE: {name: "LDX", mode: abs, cycles: 4},
+	0xAF: {name: "LAX", mode: abs, cycles: 4, undocumented: true},
+	0xB0: {name: "BCS", mode: rel, cycles: 2},
+	0xB1: {name: "LDA", mode: indY, cycles: 5, page: true},
+	0xB2: {name: "JAM", mode: impl, cycles: 2, undocumented: true},
+	0xB3: {name: "LAX", mode: indY, cycles: 5, page: true, undocumented: true},
+	0xB4: {name: "LDY", mode: zpgX, cycles: 4},
+	0xB5: {name: "LDA", mode: zpgX, cycles: 4},
+	0xB6: {name: "LDX", mode: zpgY, cycles: 4},
+	0xB7: {name: "LAX", mode: zpgY, cycles: 4, undocumented: true},
+	0xB8: {name: "CLV", mode: impl, cycles: 2},
+	0xB9: {name: "LDA", mode: absY, cycles: 4, page: true},
+	0xBA: {name: "TSX", mode: impl, cycles: 2},
+	0xBB: {name: "LAS", mode: absY, cycles: 4, page: true, undocumented: true},
+	0xBC: {name: "LDY", mode: absX, cycles: 4, page: true},
+	0xBD: {name: "LDA", mode: absX, cycles: 4, page: true},
+	0xBE: {name: "LDX", mode: absY, cycles: 4, page: true},
+	0xBF: {name: "LAX", mode: absY, cycles: 4, page: true, undocumented: true},
+	0xC0: {name: "CPY", mode: imm, cycles: 2},
+	0xC1: {name: "CMP", mode: xInd, cycles: 6},
+	0xC2: {name: "NOP", mode: imm, cycles: 2, undocumented: true},
+	0xC3: {name: "DCP", mode: xInd, cycles: 8, undocumented: true},
+	0xC4: {name: "CPY", mode: zpg, cycles: 3},
+	0xC5: {name: "CMP", mode: zpg, cycles: 3},
+	0xC6: {name: "DEC", mode: zpg, cycles: 5},
+	0xC7: {name: "DCP", mode: zpg, cycles: 5, undocumented: true},
+	0xC8: {name: "INY", mode: impl, cycles: 2},
+	0xC9: {name: "CMP", mode: imm, cycles: 2},
+	0xCA: {name: "DEX", mode: impl, cycles: 2},
+	0xCB: {name: "SBX", mode: imm, cycles: 2, undocumented: true},
+	0xCC: {name: "CPY", mode: abs, cycles: 4},
+	0xCD: {name: "CMP", mode: abs, cycles: 4},
+	0xCE: {name: "DEC", mode: abs, cycles: 6},
+	0xCF: {name: "DCP", mode: abs, cycles: 6, undocumented: true},
+	0xD0: {name: "BNE", mode: rel, cycles: 2},
+	0xD1: {name: "CMP", mode: indY, cycles: 5, page: true},
+	0xD2: {name: "JAM", mode: impl, cycles: 2, undocumented: true},
+	0xD3: {name: "DCP", mode: indY, cycles: 8, undocumented: true},
+	0xD4: {name: "NOP", mode: zpgX, cycles: 4, undocumented: true},
+	0xD5: {name: "CMP", mode: zpgX, cycles: 4},
+	0xD6: {name: "DEC", mode: zpgX, cycles: 6},
+	0xD7: {name: "DCP", mode: zpgX, cycles: 6, undocumented: true},
+	0xD8: {name: "CLD", mode: impl, cycles: 2},
+	0xD9: {name: "CMP", mode: absY, cycles: 4, page: true},
+	0xDA: {name: "NOP", mode: impl, cycles: 2, undocumented: true},
+	0xDB: {name: "DCP", mode: absY, cycles: 7, undocumented: true},
+	0xDC: {name: "NOP", mode: absX, cycles: 4, page: true, undocumented: true},
+	0xDD: {name: "CMP", mode: absX, cycles: 4, page: true},
+	0xDE: {name: "DEC", mode: absX, cycles: 7},
+	0xDF: {name: "DCP", mode: absX, cycles: 7, undocumented: true},
+	0xE0: {name: "CPX", mode: imm, cycles: 2},
+	0xE1: {name: "SBC", mode: xInd, cycles: 6},
+	0xE2: {name: "NOP", mode: imm, cycles: 2, undocumented: true},
+	0xE3: {name: "ISC", mode: xInd, cycles: 8, undocumented: true},
+	0xE4: {name: "CPX", mode: zpg, cycles: 3},
+	0xE5: {name: "SBC", mode: zpg, cycles: 3},
+	0xE6: {name: "INC", mode: zpg, cycles: 5},
+	0xE7: {name: "ISC", mode: zpg, cycles: 5, undocumented: true},
+	0xE8: {name: "INX", mode: impl, cycles: 2},
+	0xE9: {name: "SBC", mode: imm, cycles: 2},
+	0xEA: {name: "NOP", mode: impl, cycles: 2},
+	0xEB: {name: "SBC", mode: imm, cycles: 2, undocumented: true},
+	0xEC: {name: "CPX", mode: abs, cycles: 4},
+	0xED: {name: "SBC", mode: abs, cycles: 4},
+	0xEE: {name: "INC", mode: abs, cycles: 6},
+	0xEF: {name: "ISC", mode: abs, cycles: 6, undocumented: true},
+	0xF0: {name: "BEQ", mode: rel, cycles: 2},
+	0xF1: {name: "SBC", mode: indY, cycles: 5, page: true},
+	0xF2: {name: "JAM", mode: impl, cycles: 2, undocumented: true},
+	0xF3: {name: "ISC", mode: indY, cycles: 8, undocumented: true},
+	0xF4: {name: "NOP", mode: zpgX, cycles: 4, undocumented: true},
+	0xF5: {name: "SBC", mode: zpgX, cycles: 4},
+	0xF6: {name: "INC", mode: zpgX, cycles: 6},
+	0xF7: {name: "ISC", mode: zpgX, cycles: 6, undocumented: true},
+	0xF8: {name: "SED", mode: impl, cycles: 2},
+	0xF9: {name: "SBC", mode: absY, cycles: 4, page: true},
+	0xFA: {name: "NOP", mode: impl, cycles: 2, undocumented: true},
+	0xFB: {name: "ISC", mode: absY, cycles: 7, undocumented: true},
+	0xFC: {name: "NOP", mode: absX, cycles: 4, page: true, undocumented: true},
+	0xFD: {name: "SBC", mode: absX, cycles: 4, page: true},
+	0xFE: {name: "INC", mode: absX, cycles: 7},
+	0xFF: {name: "ISC", mode: absX, cycles: 7, undocumented: true},
+}
+
+/*
+handler executes a fetched instruction, returning the cycles it took beyond the base count for its opcode.
+*/
+type handler func(c *Core, op Operation) uint8
+
+/*
+dispatch holds the handler for every opcode, so executing an instruction is a single lookup.
+*/
+var dispatch [256]handler
+
+func init() {
+	for code, o := range opcodes {
+		opcodes[code].size = o.mode.Size()
+		dispatch[code] = newHandler(o)
+	}
+}
+
+/*
+The semantics of each instruction, grouped by how it uses its operand.
+*/
+var (
+	// Instructions which only read their operand.
+	reads = map[string]func(c *Core, v byte){
+		"ADC": (*Core).ADC, "AND": (*Core).AND, "BIT": (*Core).BIT, "CMP": (*Core).CMP, "CPX": (*Core).CPX,
+		"CPY": (*Core).CPY, "EOR": (*Core).EOR, "LDA": (*Core).LDA, "LDX": (*Core).LDX, "LDY": (*Core).LDY,
+		"ORA": (*Core).ORA, "SBC": (*Core).SBC, "NOP": func(c *Core, v byte) {},
+		"ALR": (*Core).alr, "ANC": (*Core).anc, "ANE": (*Core).ane, "ARR": (*Core).arr, "LAS": (*Core).las,
+		"LAX": (*Core).lax, "LXA": (*Core).lxa, "SBX": (*Core).sbx,
+	}
+
+	// Instructions which store a value worked out from the registers.
+	writes = map[string]func(c *Core) byte{
+		"STA": func(c *Core) byte { return c.AC },
+		"STX": func(c *Core) byte { return c.X },
+		"STY": func(c *Core) byte { return c.Y },
+		"SAX": func(c *Core) byte { return c.AC & c.X },
+	}
+
+	// Instructions which read their operand, then write back a new value.
+	modifies = map[string]func(c *Core, v byte) byte{
+		"ASL": (*Core).ASL, "DEC": (*Core).DEC, "INC": (*Core).INC, "LSR": (*Core).LSR, "ROL": (*Core).ROL,
+		"ROR": (*Core).ROR,
+		"DCP": (*Core).dcp, "ISC": (*Core).isc, "RLA": (*Core).rla, "RRA": (*Core).rra, "SLO": (*Core).slo,
+		"SRE": (*Core).sre,
+	}
+
+	// Branches, which return if they were taken and if doing so crossed a page.
+	branches = map[string]func(c *Core, offset byte) (bool, bool){
+		"BCC": (*Core).BCC, "BCS": (*Core).BCS, "BEQ": (*Core).BEQ, "BMI": (*Core).BMI, "BNE": (*Core).BNE,
+		"BPL": (*Core).BPL, "BVC": (*Core).BVC, "BVS": (*Core).BVS,
+	}
+
+	// Instructions which use the address of their operand rather than its value.
+	addressed = map[string]func(c *Core, a Address){
+		"JMP": (*Core).JMP, "JSR": (*Core).JSR,
+		"SHA": (*Core).sha, "SHX": (*Core).shx, "SHY": (*Core).shy, "TAS": (*Core).tas,
+	}
+
+	// Instructions without an operand.
+	implied = map[string]func(c *Core){
+		"BRK": (*Core).BRK, "CLC": (*Core).CLC, "CLD": (*Core).CLD, "CLI": (*Core).CLI, "CLV": (*Core).CLV,
+		"DEX": (*Core).DEX, "DEY": (*Core).DEY, "INX": (*Core).INX, "INY": (*Core).INY, "NOP": (*Core).NOP,
+		"PHA": (*Core).PHA, "PHP": (*Core).PHP, "PLA": (*Core).PLA, "PLP": (*Core).PLP, "RTI": (*Core).RTI,
+		"RTS": (*Core).RTS, "SEC": (*Core).SEC, "SED": (*Core).SED, "SEI": (*Core).SEI, "TAX": (*Core).TAX,
+		"TAY": (*Core).TAY, "TSX": (*Core).TSX, "TXA": (*Core).TXA, "TXS": (*Core).TXS, "TYA": (*Core).TYA,
+		"JAM": (*Core).NOP,
+	}
+)
+
+/*
+newHandler joins an opcode's addressing to the semantics of its instruction.
+*/
+func newHandler(o opcode) handler {
+	mode := o.mode
+	switch mode {
+	case impl:
+		if f, ok := implied[o.name]; ok {
+			return func(c *Core, op Operation) uint8 {
+				f(c)
+				return 0
+			}
+		}
+		panic("mos6502: no semantics for " + o.name)
+	case rel:
+		if f, ok := branches[o.name]; ok {
+			return func(c *Core, op Operation) uint8 {
+				taken, crossed := f(c, op.Byte1)
+				return cycleIf(taken) + cycleIf(crossed)
+			}
+		}
+		panic("mos6502: no semantics for " + o.name)
+	case a:
+		if f, ok := modifies[o.name]; ok {
+			return func(c *Core, op Operation) uint8 {
+				c.AC = f(c, c.AC)
+				return 0
+			}
+		}
+		panic("mos6502: no semantics for " + o.name)
+	}
+
+	if f, ok := reads[o.name]; ok {
+		if mode == imm {
+			return func(c *Core, op Operation) uint8 {
+				f(c, op.Byte1)
+				return 0
+			}
+		}
+		page := o.page
+		return func(c *Core, op Operation) uint8 {
+			a, crossed := c.effective(mode, op)
+			f(c, c.read(a))
+			return cycleIf(page && crossed)
+		}
+	}
+
+	if f, ok := writes[o.name]; ok {
+		return func(c *Core, op Operation) uint8 {
+			a, _ := c.effective(mode, op)
+			c.write(a, f(c))
+			return 0
+		}
+	}
+
+	if f, ok := modifies[o.name]; ok {
+		// The old value is written back while the new one is worked out, as the hardware does.
+		return func(c *Core, op Operation) uint8 {
+			a, _ := c.effective(mode, op)
+			v := c.read(a)
+			c.write(a, v)
+			c.write(a, f(c, v))
+			return 0
+		}
+	}
+
+	if f, ok := addressed[o.name]; ok {
+		return func(c *Core, op Operation) uint8 {
+			a, _ := c.effective(mode, op)
+			f(c, a)
+			return 0
+		}
+	}
+
+	panic("mos6502: no semantics for " + o.name)
+}
+
+/*
+cycleIf returns one cycle if the condition holds.
+*/
+func cycleIf(b bool) uint8 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,76 @@
+package mos6502
+
+import (
+	"encoding/csv"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+/*
+datasheetModes names the addressing modes as Operations.csv writes them.
+*/
+var datasheetModes = map[string]AddressType{
+	"Accumulator": a,
+	"Absolute":    abs,
+	"Absolute,X":  absX,
+	"Absolute,Y":  absY,
+	"Implied":     impl,
+	"Immediate":   imm,
+	"Indirect":    ind,
+	"Indirect,X":  xInd,
+	"Indirect,Y":  indY,
+	"Relative":    rel,
+	"Zeropage":    zpg,
+	"Zeropage,X":  zpgX,
+	"Zeropage,Y":  zpgY,
+}
+
+/*
+Test that the opcode table agrees with the datasheet figures in Operations.csv.
+*/
+func TestOpcodesMatchDatasheet(t *testing.T) {
+	f, err := os.Open("Operations.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	documented := map[byte]bool{}
+	for _, row := range rows {
+		code, err := strconv.ParseUint(row[0], 16, 8)
+		if err != nil {
+			t.Fatal(err)
+		}
+		size, _ := strconv.Atoi(row[2])
+		cycles, _ := strconv.Atoi(row[3])
+
+		o := opcodes[code]
+		documented[byte(code)] = true
+		t.Run(row[0], func(t *testing.T) {
+			expectString(t, strings.TrimSpace(row[5]), o.name)
+			expectUint8(t, uint8(datasheetModes[strings.TrimSpace(row[1])]), uint8(o.mode))
+			expectInt8(t, int8(size), Operation{Code: byte(code)}.Size())
+			expectUint8(t, uint8(cycles), o.cycles)
+			expectBool(t, strings.TrimSpace(row[4]) == "Yes", o.page)
+		})
+	}
+
+	for code, o := range opcodes {
+		expectBool(t, !documented[byte(code)], o.undocumented)
+	}
+}
+
+func TestDispatchComplete(t *testing.T) {
+	for code, h := range dispatch {
+		if h == nil {
+			t.Errorf("Expected a handler for %02X.", code)
+		}
+	}
+}
@@ -2,7 +2,6 @@ package mos6502
 
 import "fmt"
 
-
 /*
 Operation is the instruction to the Core converted into a struct.
 */
@@ -44,7 +43,16 @@ func (o Operation) Full() Address {
 }
 
 /*
-Addressing returns the addressing type of the operation.
+Jams returns true for the undocumented opcodes which lock up the processor until it is reset.
+*/
+func (o Operation) Jams() bool {
+	return (o.Code&0x0F) == 0x02 && o.Code < 0x80 ||
+		o.Code == 0x92 || o.Code == 0xB2 || o.Code == 0xD2 || o.Code == 0xF2
+}
+
+/*
+Addressing returns the addressing type of the operation. Undocumented opcodes which fit none of the patterns take it
+from the opcode table.
 */
 func (o Operation) Addressing() AddressType {
 	switch {
@@ -81,7 +89,7 @@ func (o Operation) Addressing() AddressType {
 	case (o.Code & 0x1C) == 0x14:
 		return zpgX
 	default:
-		panic("Invalid address used. No address type found.")
+		return opcodes[o.Code].mode
 	}
 }
 
@@ -94,9 +102,6 @@ func (o Operation) cyclesByPattern(c int8, p bool, b bool) (int8, bool, bool) {
 		if (o.Code & 0x1C) == 0x1C {
 			return c + 3, p, b
 		}
-		if o.Code == 0x91 {
-			panic("This is right.")
-		}
 		return c + 2, p, b
 	} else if (o.Code & 0x1C) == 0x1C {
 		return c, true, b
@@ -121,8 +126,6 @@ func (o Operation) cycleOverrides(c int8, p bool, b bool) (int8, bool, bool) {
 		return 3, false, false
 	case 0x99:
 		return 5, false, false
-	case 0xCE:
-		return 3, false, false
 	case 0x00:
 		return c + 5, false, false
 	default:
@@ -135,14 +138,24 @@ func (o Operation) Cycles() (int8, bool, bool) {
 	return o.cycleOverrides(o.cyclesByPattern(o.Addressing().Cycles()))
 }
 
-// TODO: Refactor this.
+/*
+Size returns the number of bytes the operation takes up, including the opcode.
+*/
 func (o Operation) Size() int8 {
-	switch o.Code {
-	case 0xB6, 0x61, 0x65, 0x69, 0x75, 0x90:
-		return 2
-	case 0x6C, 0x6D, 0x71, 0x79, 0x7D:
-		return 3
-	default:
-		return 1
+	return opcodes[o.Code].size
+}
+
+/*
+decodeAt reads the instruction at an address with read, a byte at a time in the order the processor reads them.
+*/
+func decodeAt(read func(Address) byte, a Address) Operation {
+	op := Operation{Code: read(a)}
+	switch op.Size() {
+	case 2:
+		op.Byte1 = read(a + 1)
+	case 3:
+		op.Byte2 = read(a + 1)
+		op.Byte1 = read(a + 2)
 	}
+	return op
 }
@@ -204,7 +204,7 @@ func TestOperationCycles(t *testing.T) {
 		"DEX impl":  {Operation{Code: 0xCA}, 2, false, false},
 		"CPY abs":   {Operation{Code: 0xCC}, 4, false, false},
 		"CMP abs":   {Operation{Code: 0xCD}, 4, false, false},
-		"DEC abs":   {Operation{Code: 0xCE}, 3, false, false},
+		"DEC abs":   {Operation{Code: 0xCE}, 6, false, false},
 		"BNE rel":   {Operation{Code: 0xD0}, 2, false, true},
 		"CMP ind,Y": {Operation{Code: 0xD1}, 5, true, false},
 		"CMP zpg,X": {Operation{Code: 0xD5}, 4, false, false},
@@ -245,3 +245,67 @@ func TestOperationCycles(t *testing.T) {
 		})
 	}
 }
+
+/*
+Test that the cycles worked out for each documented opcode agree with the opcode table the Core runs from.
+*/
+func TestOperationCyclesMatchOpcodes(t *testing.T) {
+	for code := 0; code < 0x100; code++ {
+		o := opcodes[code]
+		if o.name == "" || o.undocumented {
+			continue
+		}
+		c, p, b := Operation{Code: byte(code)}.Cycles()
+		if c != int8(o.cycles) || p != o.page || b != (o.mode == rel) {
+			t.Errorf("Expected %s (%02X) to take %d cycles, paged %t, branch %t but got %d, %t, %t.", o.name, code,
+				o.cycles, o.page, o.mode == rel, c, p, b)
+		}
+	}
+}
+
+func TestJams(t *testing.T) {
+	jams := map[byte]bool{
+		0x02: true, 0x12: true, 0x22: true, 0x32: true, 0x42: true, 0x52: true,
+		0x62: true, 0x72: true, 0x92: true, 0xB2: true, 0xD2: true, 0xF2: true,
+	}
+
+	for code := 0; code < 0x100; code++ {
+		op := Operation{Code: byte(code)}
+		if op.Jams() != jams[op.Code] {
+			t.Errorf("Expected Jams to be \"%t\" for %02X.", jams[op.Code], op.Code)
+		}
+	}
+}
+
+/*
+Test that every operation, documented or not, decodes without panicking, and that the decoding agrees with the opcode
+table for documented ones.
+*/
+func FuzzOperation(f *testing.F) {
+	for _, code := range []byte{0x00, 0x02, 0x6C, 0x91, 0x97, 0xBE, 0xEA, 0xFF} {
+		f.Add(code, byte(0x12), byte(0x34))
+	}
+
+	f.Fuzz(func(t *testing.T, code byte, byte1 byte, byte2 byte) {
+		op := Operation{Code: code, Byte1: byte1, Byte2: byte2}
+		mode := op.Addressing()
+		if mode < a || mode > zpgY {
+			t.Fatalf("Expected an addressing type for %02X but got %d.", code, mode)
+		}
+
+		c, _, _ := op.Cycles()
+		if c < 2 || c > 8 {
+			t.Errorf("Expected 2 to 8 cycles for %02X but got %d.", code, c)
+		}
+
+		size := op.Size()
+		if size < 1 || size > 3 {
+			t.Errorf("Expected 1 to 3 bytes for %02X but got %d.", code, size)
+		}
+
+		if o := opcodes[code]; !o.undocumented {
+			expectUint8(t, uint8(o.mode), uint8(mode))
+			expectInt8(t, mode.Size(), size)
+		}
+	})
+}
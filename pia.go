@@ -0,0 +1,232 @@
+package mos6502
+
+/*
+PIA control register bits. The same layout is used for both sides of the chip.
+*/
+const (
+	piaC1Enable byte = 0x01
+	piaC1Rising byte = 0x02
+	piaDataReg  byte = 0x04
+	piaC2Enable byte = 0x08
+	piaC2Rising byte = 0x10
+	piaC2Output byte = 0x20
+	piaIRQ2     byte = 0x40
+	piaIRQ1     byte = 0x80
+)
+
+/*
+PIA emulates the Motorola 6821 Peripheral Interface Adapter: two 8-bit ports, each with a pair of control lines. It
+is mapped onto the Bus as four registers, where bit 2 of each control register picks between the data direction
+register and the port itself.
+*/
+type PIA struct {
+	PortA Port
+	PortB Port
+
+	// Control line levels driven from outside the chip. CA2 and CB2 are only read while configured as inputs.
+	CA1 bool
+	CA2 bool
+	CB1 bool
+	CB2 bool
+
+	cra byte
+	crb byte
+
+	// Levels the chip drives on CA2 and CB2, and if they are strobed low for a single cycle.
+	ca2Out   bool
+	cb2Out   bool
+	ca2Pulse bool
+	cb2Pulse bool
+
+	ca1Last bool
+	ca2Last bool
+	cb1Last bool
+	cb2Last bool
+}
+
+/*
+NewPIA returns a PIA in its reset state, with the port and control line inputs pulled high.
+*/
+func NewPIA() *PIA {
+	p := &PIA{
+		PortA: Port{Input: 0xFF},
+		PortB: Port{Input: 0xFF},
+		CA1:   true,
+		CA2:   true,
+		CB1:   true,
+		CB2:   true,
+	}
+	p.Reset()
+	return p
+}
+
+/*
+Reset clears every register as the RES line does.
+*/
+func (p *PIA) Reset() {
+	p.PortA.setData(0)
+	p.PortA.setDDR(0)
+	p.PortB.setData(0)
+	p.PortB.setDDR(0)
+	p.cra, p.crb = 0, 0
+	p.ca2Out, p.cb2Out = true, true
+	p.ca2Pulse, p.cb2Pulse = false, false
+	p.ca1Last, p.ca2Last, p.cb1Last, p.cb2Last = p.CA1, p.CA2, p.CB1, p.CB2
+}
+
+/*
+Read returns the value of a register. Reading a port clears its interrupt flags, and on side A may strobe CA2.
+*/
+func (p *PIA) Read(a Address) byte {
+	switch a & 0x03 {
+	case 0:
+		if p.cra&piaDataReg == 0 {
+			return p.PortA.DDR
+		}
+		p.cra &^= piaIRQ1 | piaIRQ2
+		p.ca2Out, p.ca2Pulse = piaStrobe(p.cra, p.ca2Out, p.ca2Pulse)
+		return p.PortA.Pins()
+	case 1:
+		return p.cra
+	case 2:
+		if p.crb&piaDataReg == 0 {
+			return p.PortB.DDR
+		}
+		p.crb &^= piaIRQ1 | piaIRQ2
+		return (p.PortB.Data & p.PortB.DDR) | (p.PortB.Pins() &^ p.PortB.DDR)
+	default:
+		return p.crb
+	}
+}
+
+/*
+Write stores a value in a register. Writing port B may strobe CB2. The interrupt flags in the control registers can
+only be cleared by reading the port.
+*/
+func (p *PIA) Write(a Address, d byte) {
+	switch a & 0x03 {
+	case 0:
+		if p.cra&piaDataReg == 0 {
+			p.PortA.setDDR(d)
+			return
+		}
+		p.PortA.setData(d)
+	case 1:
+		p.cra = (p.cra & (piaIRQ1 | piaIRQ2)) | (d &^ (piaIRQ1 | piaIRQ2))
+		p.ca2Out = piaManual(p.cra, p.ca2Out)
+	case 2:
+		if p.crb&piaDataReg == 0 {
+			p.PortB.setDDR(d)
+			return
+		}
+		p.PortB.setData(d)
+		p.cb2Out, p.cb2Pulse = piaStrobe(p.crb, p.cb2Out, p.cb2Pulse)
+	default:
+		p.crb = (p.crb & (piaIRQ1 | piaIRQ2)) | (d &^ (piaIRQ1 | piaIRQ2))
+		p.cb2Out = piaManual(p.crb, p.cb2Out)
+	}
+}
+
+/*
+Tick advances the chip by one cycle of the system clock, ending strobes and looking for control line transitions.
+*/
+func (p *PIA) Tick() {
+	if p.ca2Pulse {
+		p.ca2Pulse, p.ca2Out = false, true
+	}
+	if p.cb2Pulse {
+		p.cb2Pulse, p.cb2Out = false, true
+	}
+
+	if p.CA1 != p.ca1Last {
+		p.ca1Last = p.CA1
+		p.cra, p.ca2Out = piaControlEdge(p.cra, p.CA1, p.ca2Out)
+	}
+	if p.CA2 != p.ca2Last {
+		p.ca2Last = p.CA2
+		p.cra = piaInputEdge(p.cra, p.CA2)
+	}
+	if p.CB1 != p.cb1Last {
+		p.cb1Last = p.CB1
+		p.crb, p.cb2Out = piaControlEdge(p.crb, p.CB1, p.cb2Out)
+	}
+	if p.CB2 != p.cb2Last {
+		p.cb2Last = p.CB2
+		p.crb = piaInputEdge(p.crb, p.CB2)
+	}
+}
+
+/*
+Interrupting reports if either of the IRQA or IRQB outputs are asserted. Most machines wire them together.
+*/
+func (p *PIA) Interrupting() bool {
+	return piaRequesting(p.cra) || piaRequesting(p.crb)
+}
+
+/*
+CA2Output returns the level the chip drives on CA2 when it is configured as an output.
+*/
+func (p *PIA) CA2Output() bool {
+	return p.ca2Out
+}
+
+/*
+CB2Output returns the level the chip drives on CB2 when it is configured as an output.
+*/
+func (p *PIA) CB2Output() bool {
+	return p.cb2Out
+}
+
+/*
+piaStrobe drops a C2 output after the port access that starts a handshake. Depending on bit 3 it stays low until the
+next C1 transition or for a single cycle.
+*/
+func piaStrobe(cr byte, out bool, pulse bool) (bool, bool) {
+	if cr&(piaC2Output|piaC2Rising) != piaC2Output {
+		return out, pulse
+	}
+	return false, cr&piaC2Enable != 0
+}
+
+/*
+piaManual returns the level of a C2 output that is set directly from bit 3 of the control register.
+*/
+func piaManual(cr byte, out bool) bool {
+	if cr&(piaC2Output|piaC2Rising) != piaC2Output|piaC2Rising {
+		return out
+	}
+	return cr&piaC2Enable != 0
+}
+
+/*
+piaControlEdge handles a transition on C1, flagging it if it is the active edge and ending a C2 handshake.
+*/
+func piaControlEdge(cr byte, level bool, out bool) (byte, bool) {
+	if level != (cr&piaC1Rising != 0) {
+		return cr, out
+	}
+	if cr&(piaC2Output|piaC2Rising|piaC2Enable) == piaC2Output {
+		out = true
+	}
+	return cr | piaIRQ1, out
+}
+
+/*
+piaInputEdge handles a transition on C2, flagging it if it is an input and this is the active edge.
+*/
+func piaInputEdge(cr byte, level bool) byte {
+	if cr&piaC2Output != 0 || level != (cr&piaC2Rising != 0) {
+		return cr
+	}
+	return cr | piaIRQ2
+}
+
+/*
+piaRequesting reports if a control register has a flagged interrupt that is enabled.
+*/
+func piaRequesting(cr byte) bool {
+	if cr&piaIRQ1 != 0 && cr&piaC1Enable != 0 {
+		return true
+	}
+	return cr&piaIRQ2 != 0 && cr&piaC2Enable != 0 && cr&piaC2Output == 0
+}
@@ -0,0 +1,101 @@
+package mos6502
+
+import "testing"
+
+func TestPIARegisterSelect(t *testing.T) {
+	p := NewPIA()
+	p.PortA.Input = 0x0F
+
+	// With bit 2 of the control register clear the port address reaches the DDR.
+	p.Write(0x00, 0xF0)
+	expectByte(t, 0xF0, p.PortA.DDR)
+
+	p.Write(0x01, piaDataReg)
+	p.Write(0x00, 0xA5)
+	expectByte(t, 0xAF, p.Read(0x00))
+	expectByte(t, 0xA0, p.PortA.Data&p.PortA.DDR)
+
+	p.Write(0x02, 0xFF)
+	p.Write(0x03, piaDataReg)
+	p.Write(0x02, 0x3C)
+	expectByte(t, 0x3C, p.Read(0x02))
+	expectByte(t, 0xFF, p.PortB.DDR)
+}
+
+func TestPIAControlLines(t *testing.T) {
+	var tests = map[string]struct {
+		cr      byte
+		ca1     bool
+		ca2     bool
+		irq1    bool
+		irq2    bool
+		request bool
+	}{
+		"C1 falling":          {cr: 0x04, ca1: false, ca2: true, irq1: true},
+		"C1 rising ignored":   {cr: 0x04 | piaC1Rising, ca1: false, ca2: true, irq1: false},
+		"C1 enabled":          {cr: 0x04 | piaC1Enable, ca1: false, ca2: true, irq1: true, request: true},
+		"C2 falling":          {cr: 0x04, ca1: true, ca2: false, irq2: true},
+		"C2 enabled":          {cr: 0x04 | piaC2Enable, ca1: true, ca2: false, irq2: true, request: true},
+		"C2 output ignored":   {cr: 0x04 | piaC2Output, ca1: true, ca2: false, irq2: false},
+		"both lines disabled": {cr: 0x04, ca1: false, ca2: false, irq1: true, irq2: true},
+	}
+
+	for k, tt := range tests {
+		t.Run(k, func(t *testing.T) {
+			p := NewPIA()
+			p.Write(0x01, tt.cr)
+			p.CA1, p.CA2 = tt.ca1, tt.ca2
+			p.Tick()
+
+			cr := p.Read(0x01)
+			expectBool(t, tt.irq1, cr&piaIRQ1 != 0)
+			expectBool(t, tt.irq2, cr&piaIRQ2 != 0)
+			expectBool(t, tt.request, p.Interrupting())
+
+			// Reading the port clears both flags.
+			p.Read(0x00)
+			expectByte(t, 0x00, p.Read(0x01)&(piaIRQ1|piaIRQ2))
+			expectBool(t, false, p.Interrupting())
+		})
+	}
+}
+
+func TestPIAFlagsReadOnly(t *testing.T) {
+	p := NewPIA()
+	p.Write(0x03, 0xFF)
+	expectByte(t, 0x3F, p.Read(0x03))
+}
+
+func TestPIAStrobes(t *testing.T) {
+	var tests = map[string]struct {
+		cr     byte
+		access func(p *PIA)
+		during bool
+		after  bool
+	}{
+		"read strobe, C1 restore": {cr: 0x24, access: func(p *PIA) { p.Read(0x00) }, during: false, after: true},
+		"read strobe, E restore":  {cr: 0x2C, access: func(p *PIA) { p.Read(0x00) }, during: false, after: true},
+		"manual low":              {cr: 0x34, access: func(p *PIA) { p.Read(0x00) }, during: false, after: false},
+		"manual high":             {cr: 0x3C, access: func(p *PIA) { p.Read(0x00) }, during: true, after: true},
+		"write strobe on B":       {cr: 0x24, access: func(p *PIA) { p.Write(0x02, 0x00) }, during: false, after: true},
+		"reading B does not drop": {cr: 0x24, access: func(p *PIA) { p.Read(0x02) }, during: true, after: true},
+		"writing A does not drop": {cr: 0x24, access: func(p *PIA) { p.Write(0x00, 0x00) }, during: true, after: true},
+	}
+
+	for k, tt := range tests {
+		t.Run(k, func(t *testing.T) {
+			p := NewPIA()
+			p.Write(0x01, tt.cr)
+			p.Write(0x03, tt.cr)
+			tt.access(p)
+
+			out := func() bool { return p.CA2Output() && p.CB2Output() }
+			expectBool(t, tt.during, out())
+
+			// Pulses end on their own, handshakes wait for C1.
+			p.CA1, p.CB1 = false, false
+			p.Tick()
+			expectBool(t, tt.after, out())
+		})
+	}
+}
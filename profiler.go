@@ -0,0 +1,484 @@
+package mos6502
+
+import (
+	"compress/gzip"
+	"io"
+	"sort"
+)
+
+/*
+Profiler observes a Core and counts the instructions and cycles spent at each address, split by the chain of
+subroutine calls that led there. Calls are followed through JSR and interrupts, and returns through RTS and RTI.
+Subroutines are named from Symbols where they have one.
+
+Code which unwinds the stack by hand is handled by ending any calls whose return address has been pulled off the
+stack, whenever a return, pull, TXS or trap raises the stack pointer.
+*/
+type Profiler struct {
+	Core    *Core
+	Symbols Symbols
+
+	// Where profiling began, and the calls made since.
+	root  *callNode
+	stack []frame
+
+	// Follows the Core for the Profiler.
+	observer *profilerObserver
+}
+
+/*
+profilerObserver follows a Core for a Profiler. It is kept apart from the Profiler, whose own Reset throws away its
+counts rather than following a reset of the processor.
+*/
+type profilerObserver struct {
+	NopObserver
+	p *Profiler
+
+	// The stack pointer before the instruction running, the cycle the last instruction or interrupt ran up to, and
+	// where it left the program counter. Cycles run beyond end by the time the next one starts were taken by a trap
+	// at next.
+	sp   byte
+	end  uint64
+	next Address
+}
+
+/*
+callNode is one chain of calls from the start of profiling, keeping the counts of the code run in it.
+*/
+type callNode struct {
+	// Where the subroutine starts, and the address of the instruction that called it.
+	entry Address
+	site  Address
+
+	parent   *callNode
+	children map[[2]Address]*callNode
+	counts   map[Address]*ProfileCount
+
+	// Times this chain of calls was made.
+	calls uint64
+}
+
+/*
+frame is a call in progress, with the stack pointer from before the call so its return can be spotted.
+*/
+type frame struct {
+	node *callNode
+	sp   byte
+}
+
+/*
+ProfileCount is the time spent at an address or in a subroutine.
+*/
+type ProfileCount struct {
+	Instructions uint64
+	Cycles       uint64
+}
+
+/*
+SubroutineProfile is the time spent in a subroutine. Self counts only its own code, Total includes the subroutines it
+called. Calls is the number of times it was entered.
+*/
+type SubroutineProfile struct {
+	Entry Address
+	Name  string
+	Calls uint64
+	Self  ProfileCount
+	Total ProfileCount
+}
+
+/*
+NewProfiler returns a Profiler observing c, with the code at the program counter as the root of the call chains. It
+profiles whatever runs c until Close is called.
+*/
+func NewProfiler(c *Core) *Profiler {
+	p := &Profiler{Core: c, root: newCallNode(c.PC, c.PC, nil)}
+	p.observer = &profilerObserver{p: p, end: c.cycles, next: c.PC}
+	c.Observe(p.observer)
+	return p
+}
+
+/*
+Close stops observing the Core, keeping the counts made so far.
+*/
+func (p *Profiler) Close() {
+	p.Core.Unobserve(p.observer)
+}
+
+func newCallNode(entry, site Address, parent *callNode) *callNode {
+	return &callNode{
+		entry:    entry,
+		site:     site,
+		parent:   parent,
+		children: map[[2]Address]*callNode{},
+		counts:   map[Address]*ProfileCount{},
+	}
+}
+
+/*
+Fetched ends the calls returned from by a trap since the last instruction, counting the trap as an instruction.
+*/
+func (o *profilerObserver) Fetched(c *Core, pc Address, op Operation) {
+	o.trapped(c, c.SP)
+	o.sp = c.SP
+}
+
+/*
+Retired counts an instruction towards the current call, then enters the subroutine it called or ends the calls it
+returned from.
+*/
+func (o *profilerObserver) Retired(c *Core, pc Address, op Operation, cycles int) {
+	o.p.count(pc, uint64(cycles), 1)
+	o.end, o.next = c.cycles-1+uint64(cycles), c.PC
+
+	switch op.Code {
+	case 0x00, 0x20: // BRK, JSR
+		o.p.call(pc, o.sp)
+	default:
+		o.p.unwind(c.SP)
+	}
+}
+
+/*
+Interrupted enters the handler as a call from the instruction the interrupt was taken before, counting the interrupt
+sequence against the handler but not as an instruction.
+*/
+func (o *profilerObserver) Interrupted(c *Core, vector Address) {
+	site, sp := c.interrupted()
+	o.trapped(c, sp)
+	o.p.call(site, sp)
+	o.p.count(c.PC, 7, 0)
+	o.end, o.next = c.cycles-1+7, c.PC
+}
+
+/*
+Reset leaves the reset sequence out of the profile.
+*/
+func (o *profilerObserver) Reset(c *Core) {
+	o.end, o.next = c.cycles+uint64(c.opCycles), c.PC
+}
+
+/*
+trapped counts any cycles run by a trap since the last instruction or interrupt, as an instruction at the address it
+was taken at, then ends the calls the trap returned from, leaving the stack pointer at sp.
+*/
+func (o *profilerObserver) trapped(c *Core, sp byte) {
+	if start := c.cycles - 1; start > o.end {
+		o.p.count(o.next, start-o.end, 1)
+		o.end = start
+	}
+	o.p.unwind(sp)
+}
+
+/*
+current returns the call being run.
+*/
+func (p *Profiler) current() *callNode {
+	if len(p.stack) == 0 {
+		return p.root
+	}
+	return p.stack[len(p.stack)-1].node
+}
+
+/*
+count adds time spent at an address to the current call.
+*/
+func (p *Profiler) count(a Address, cycles uint64, instructions uint64) {
+	n := p.current()
+	pc, ok := n.counts[a]
+	if !ok {
+		pc = &ProfileCount{}
+		n.counts[a] = pc
+	}
+	pc.Instructions += instructions
+	pc.Cycles += cycles
+}
+
+/*
+call enters the subroutine at the program counter, called from site with the stack pointer at sp.
+*/
+func (p *Profiler) call(site Address, sp byte) {
+	parent := p.current()
+	key := [2]Address{site, p.Core.PC}
+	n, ok := parent.children[key]
+	if !ok {
+		n = newCallNode(p.Core.PC, site, parent)
+		parent.children[key] = n
+	}
+	n.calls++
+	p.stack = append(p.stack, frame{node: n, sp: sp})
+}
+
+/*
+unwind ends the calls whose return state is no longer on the stack, with the stack pointer at sp.
+*/
+func (p *Profiler) unwind(sp byte) {
+	for len(p.stack) > 0 && p.stack[len(p.stack)-1].sp <= sp {
+		p.stack = p.stack[:len(p.stack)-1]
+	}
+}
+
+/*
+Reset throws away the counts, starting again with the code at the program counter as the root.
+*/
+func (p *Profiler) Reset() {
+	p.root = newCallNode(p.Core.PC, p.Core.PC, nil)
+	p.stack = nil
+	p.observer.end, p.observer.next = p.Core.cycles, p.Core.PC
+}
+
+/*
+walk calls f for each call node, along with the distinct entry addresses of the calls leading to it, itself included.
+*/
+func (p *Profiler) walk(f func(n *callNode, entries []Address)) {
+	var visit func(n *callNode, entries []Address)
+	visit = func(n *callNode, entries []Address) {
+		seen := false
+		for _, e := range entries {
+			seen = seen || e == n.entry
+		}
+		if !seen {
+			entries = append(entries, n.entry)
+		}
+
+		f(n, entries)
+		for _, child := range n.children {
+			visit(child, entries)
+		}
+	}
+	visit(p.root, nil)
+}
+
+/*
+Addresses returns the time spent at each address, whichever calls it was run in.
+*/
+func (p *Profiler) Addresses() map[Address]ProfileCount {
+	flat := map[Address]ProfileCount{}
+	p.walk(func(n *callNode, _ []Address) {
+		for a, pc := range n.counts {
+			f := flat[a]
+			f.Instructions += pc.Instructions
+			f.Cycles += pc.Cycles
+			flat[a] = f
+		}
+	})
+	return flat
+}
+
+/*
+Subroutines returns the time spent in each subroutine, most cycles first. Recursive calls are only counted once
+towards Total.
+*/
+func (p *Profiler) Subroutines() []SubroutineProfile {
+	subs := map[Address]*SubroutineProfile{}
+	get := func(a Address) *SubroutineProfile {
+		s, ok := subs[a]
+		if !ok {
+			s = &SubroutineProfile{Entry: a, Name: symbolName(p.Symbols, a)}
+			subs[a] = s
+		}
+		return s
+	}
+
+	p.walk(func(n *callNode, entries []Address) {
+		get(n.entry).Calls += n.calls
+		for _, pc := range n.counts {
+			s := get(n.entry)
+			s.Self.Instructions += pc.Instructions
+			s.Self.Cycles += pc.Cycles
+			for _, e := range entries {
+				s := get(e)
+				s.Total.Instructions += pc.Instructions
+				s.Total.Cycles += pc.Cycles
+			}
+		}
+	})
+
+	list := make([]SubroutineProfile, 0, len(subs))
+	for _, s := range subs {
+		list = append(list, *s)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Total.Cycles != list[j].Total.Cycles {
+			return list[i].Total.Cycles > list[j].Total.Cycles
+		}
+		return list[i].Entry < list[j].Entry
+	})
+	return list
+}
+
+/*
+WriteProfile writes the counts as a gzipped pprof profile, for "go tool pprof". Each subroutine is a function, and
+each address a location in it whose line number is the address, so "-lines" breaks the time down by instruction.
+*/
+func (p *Profiler) WriteProfile(w io.Writer) error {
+	var b pprofBuilder
+	b.strings = map[string]int64{}
+	b.functions = map[Address]uint64{}
+	b.locations = map[[2]Address]uint64{}
+	b.str("")
+
+	var prof protobuf
+	for _, t := range [][2]string{{"instructions", "count"}, {"cycles", "count"}} {
+		var vt protobuf
+		vt.int(1, b.str(t[0]))
+		vt.int(2, b.str(t[1]))
+		prof.bytes(1, vt.buf)
+	}
+
+	p.walk(func(n *callNode, _ []Address) {
+		addrs := make([]Address, 0, len(n.counts))
+		for a := range n.counts {
+			addrs = append(addrs, a)
+		}
+		sort.Slice(addrs, func(i, j int) bool { return addrs[i] < addrs[j] })
+
+		for _, a := range addrs {
+			stack := []uint64{b.location(p.Symbols, n.entry, a)}
+			for up := n; up.parent != nil; up = up.parent {
+				stack = append(stack, b.location(p.Symbols, up.parent.entry, up.site))
+			}
+
+			var s protobuf
+			s.packed(1, stack...)
+			s.packed(2, n.counts[a].Instructions, n.counts[a].Cycles)
+			prof.bytes(2, s.buf)
+		}
+	})
+
+	var m protobuf
+	m.uint(1, 1)
+	m.uint(3, 0x10000)
+	m.int(5, b.str("6502"))
+	m.uint(7, 1)
+	m.uint(9, 1)
+	prof.bytes(3, m.buf)
+
+	var period protobuf
+	period.int(1, b.str("cycles"))
+	period.int(2, b.str("count"))
+	prof.bytes(11, period.buf)
+	prof.uint(12, 1)
+
+	// The string table goes last, once everything that needs a string has added it.
+	prof.buf = append(prof.buf, b.locs.buf...)
+	prof.buf = append(prof.buf, b.funcs.buf...)
+	for _, s := range b.table {
+		prof.bytes(6, []byte(s))
+	}
+
+	z := gzip.NewWriter(w)
+	if _, err := z.Write(prof.buf); err != nil {
+		return err
+	}
+	return z.Close()
+}
+
+/*
+pprofBuilder collects the string table, functions and locations of a pprof profile as they are first used.
+*/
+type pprofBuilder struct {
+	table   []string
+	strings map[string]int64
+
+	functions map[Address]uint64
+	funcs     protobuf
+
+	locations map[[2]Address]uint64
+	locs      protobuf
+}
+
+func (b *pprofBuilder) str(s string) int64 {
+	if i, ok := b.strings[s]; ok {
+		return i
+	}
+	b.strings[s] = int64(len(b.table))
+	b.table = append(b.table, s)
+	return int64(len(b.table) - 1)
+}
+
+/*
+function returns the ID of the function for the subroutine at entry.
+*/
+func (b *pprofBuilder) function(symbols Symbols, entry Address) uint64 {
+	if id, ok := b.functions[entry]; ok {
+		return id
+	}
+	id := uint64(len(b.functions) + 1)
+	b.functions[entry] = id
+
+	var f protobuf
+	f.uint(1, id)
+	f.int(2, b.str(symbolName(symbols, entry)))
+	f.int(3, b.str(symbolName(symbols, entry)))
+	f.int(5, int64(entry))
+	b.funcs.bytes(5, f.buf)
+	return id
+}
+
+/*
+location returns the ID of the location for an address in the subroutine at entry.
+*/
+func (b *pprofBuilder) location(symbols Symbols, entry Address, a Address) uint64 {
+	key := [2]Address{entry, a}
+	if id, ok := b.locations[key]; ok {
+		return id
+	}
+	id := uint64(len(b.locations) + 1)
+	b.locations[key] = id
+
+	var line protobuf
+	line.uint(1, b.function(symbols, entry))
+	line.int(2, int64(a))
+
+	var l protobuf
+	l.uint(1, id)
+	l.uint(2, 1)
+	l.uint(3, uint64(a))
+	l.bytes(4, line.buf)
+	b.locs.bytes(4, l.buf)
+	return id
+}
+
+/*
+protobuf encodes the few kinds of protocol buffer field a pprof profile needs.
+*/
+type protobuf struct {
+	buf []byte
+}
+
+func (p *protobuf) varint(v uint64) {
+	for v >= 0x80 {
+		p.buf = append(p.buf, byte(v)|0x80)
+		v >>= 7
+	}
+	p.buf = append(p.buf, byte(v))
+}
+
+/*
+uint writes a varint field, leaving it out if it is zero as protocol buffers do.
+*/
+func (p *protobuf) uint(field int, v uint64) {
+	if v == 0 {
+		return
+	}
+	p.varint(uint64(field) << 3)
+	p.varint(v)
+}
+
+func (p *protobuf) int(field int, v int64) {
+	p.uint(field, uint64(v))
+}
+
+func (p *protobuf) bytes(field int, b []byte) {
+	p.varint(uint64(field)<<3 | 2)
+	p.varint(uint64(len(b)))
+	p.buf = append(p.buf, b...)
+}
+
+func (p *protobuf) packed(field int, vs ...uint64) {
+	var b protobuf
+	for _, v := range vs {
+		b.varint(v)
+	}
+	p.bytes(field, b.buf)
+}
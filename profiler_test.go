@@ -0,0 +1,242 @@
+package mos6502
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"testing"
+)
+
+/*
+profileCore returns a Core with code loaded at each of the given addresses, started at $0200.
+*/
+func profileCore(code map[Address][]byte) *Core {
+	c := &Core{PC: 0x0200, SP: 0xFF}
+	for start, b := range code {
+		for i, d := range b {
+			c.Bus.Write(start+Address(i), d)
+		}
+	}
+	return c
+}
+
+/*
+subroutine finds a subroutine in a profile, failing if it isn't there.
+*/
+func subroutine(t *testing.T, subs []SubroutineProfile, entry Address) SubroutineProfile {
+	for _, s := range subs {
+		if s.Entry == entry {
+			return s
+		}
+	}
+	t.Fatalf("Expected a subroutine at %04X.", entry)
+	return SubroutineProfile{}
+}
+
+func TestProfiler(t *testing.T) {
+	c := profileCore(map[Address][]byte{
+		0x0200: {
+			0xA2, 0x03, // LDX #$03
+			0x20, 0x00, 0x03, // JSR $0300
+			0xCA,       // DEX
+			0xD0, 0xFA, // BNE $0202
+			0x4C, 0x08, 0x02, // JMP $0208
+		},
+		0x0300: {
+			0xEA, // NOP
+			0x60, // RTS
+		},
+	})
+	p := NewProfiler(c)
+	p.Symbols = Labels{0x0200: "main", 0x0300: "delay"}
+	expectLoop(t, c, c.Run(context.Background(), Budget{StopOnLoop: true}))
+
+	subs := p.Subroutines()
+	if len(subs) != 2 {
+		t.Fatalf("Expected 2 subroutines but got %d.", len(subs))
+	}
+
+	main := subs[0]
+	expectString(t, "main", main.Name)
+	expectUint64(t, 0, main.Calls)
+	expectUint64(t, 11, main.Self.Instructions)
+	expectUint64(t, 37, main.Self.Cycles)
+	expectUint64(t, 17, main.Total.Instructions)
+	expectUint64(t, 61, main.Total.Cycles)
+
+	delay := subs[1]
+	expectString(t, "delay", delay.Name)
+	expectUint64(t, 3, delay.Calls)
+	expectUint64(t, 6, delay.Self.Instructions)
+	expectUint64(t, 24, delay.Self.Cycles)
+	expectUint64(t, 24, delay.Total.Cycles)
+
+	flat := p.Addresses()
+	expectUint64(t, 3, flat[0x0202].Instructions)
+	expectUint64(t, 18, flat[0x0202].Cycles)
+	expectUint64(t, 8, flat[0x0206].Cycles)
+	expectUint64(t, 3, flat[0x0301].Instructions)
+	expectUint64(t, c.Cycles(), main.Total.Cycles)
+}
+
+func TestProfilerUnwind(t *testing.T) {
+	c := profileCore(map[Address][]byte{
+		0x0200: {
+			0x20, 0x00, 0x03, // JSR $0300
+			0x20, 0x10, 0x03, // JSR $0310
+			0x4C, 0x06, 0x02, // JMP $0206
+		},
+		// Drops its return address and jumps back by hand.
+		0x0300: {
+			0x68,             // PLA
+			0x68,             // PLA
+			0x4C, 0x03, 0x02, // JMP $0203
+		},
+		0x0310: {
+			0x60, // RTS
+		},
+	})
+	p := NewProfiler(c)
+	expectLoop(t, c, c.Run(context.Background(), Budget{StopOnLoop: true}))
+
+	subs := p.Subroutines()
+	expectUint64(t, 1, subroutine(t, subs, 0x0300).Calls)
+	expectUint64(t, 8, subroutine(t, subs, 0x0300).Total.Cycles)
+
+	// Called from the root, not from inside the subroutine which was left.
+	expectUint64(t, 1, subroutine(t, subs, 0x0310).Calls)
+	expectUint64(t, 6, subroutine(t, subs, 0x0310).Total.Cycles)
+	expectUint64(t, 0, uint64(len(p.stack)))
+	expectUint64(t, 2, uint64(len(p.root.children)))
+}
+
+func TestProfilerInterrupt(t *testing.T) {
+	c := profileCore(map[Address][]byte{
+		0x0200:    {0xEA, 0xEA}, // NOP, NOP
+		0x0400:    {0xEA, 0x40}, // NOP, RTI
+		IRQVector: {0x00, 0x04},
+	})
+	var irq line = true
+	c.ConnectIRQ(&irq)
+	p := NewProfiler(c)
+
+	// The interrupt sequence is counted against the handler, but not as an instruction.
+	expectUint64(t, 7, uint64(c.Step()))
+	irq = false
+	c.Step()
+	c.Step()
+	c.Step()
+	expectAddress(t, 0x0201, c.PC)
+
+	handler := subroutine(t, p.Subroutines(), 0x0400)
+	expectUint64(t, 1, handler.Calls)
+	expectUint64(t, 2, handler.Self.Instructions)
+	expectUint64(t, 15, handler.Self.Cycles)
+
+	// The first address of the handler has both the interrupt sequence and its NOP.
+	expectUint64(t, 9, p.Addresses()[0x0400].Cycles)
+
+	root := subroutine(t, p.Subroutines(), 0x0200)
+	expectUint64(t, 1, root.Self.Instructions)
+	expectUint64(t, 17, root.Total.Cycles)
+}
+
+/*
+protoVarint reads a varint from the front of an encoded protocol buffer.
+*/
+func protoVarint(t *testing.T, b *[]byte) uint64 {
+	var v uint64
+	for shift := 0; ; shift += 7 {
+		if len(*b) == 0 {
+			t.Fatal("Expected more of the message.")
+		}
+		d := (*b)[0]
+		*b = (*b)[1:]
+		v |= uint64(d&0x7F) << shift
+		if d < 0x80 {
+			return v
+		}
+	}
+}
+
+/*
+protoFields splits an encoded protocol buffer message into its fields, giving the contents of the length delimited
+ones.
+*/
+func protoFields(t *testing.T, b []byte) (fields []int, contents [][]byte) {
+	for len(b) > 0 {
+		key := protoVarint(t, &b)
+		fields = append(fields, int(key>>3))
+		switch key & 7 {
+		case 0:
+			protoVarint(t, &b)
+			contents = append(contents, nil)
+		case 2:
+			n := protoVarint(t, &b)
+			contents = append(contents, b[:n])
+			b = b[n:]
+		default:
+			t.Fatalf("Unexpected wire type %d.", key&7)
+		}
+	}
+	return fields, contents
+}
+
+func TestWriteProfile(t *testing.T) {
+	c := profileCore(map[Address][]byte{
+		0x0200: {
+			0x20, 0x00, 0x03, // JSR $0300
+			0x4C, 0x03, 0x02, // JMP $0203
+		},
+		0x0300: {0x60}, // RTS
+	})
+	p := NewProfiler(c)
+	p.Symbols = Labels{0x0300: "sub"}
+	expectLoop(t, c, c.Run(context.Background(), Budget{StopOnLoop: true}))
+
+	var out bytes.Buffer
+	if err := p.WriteProfile(&out); err != nil {
+		t.Fatal(err)
+	}
+	z, err := gzip.NewReader(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := io.ReadAll(z)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := map[int]int{}
+	var table []string
+	var cycles uint64
+	fields, contents := protoFields(t, raw)
+	for i, f := range fields {
+		counts[f]++
+		switch f {
+		case 2:
+			_, sample := protoFields(t, contents[i])
+			values := sample[1]
+			protoVarint(t, &values)
+			cycles += protoVarint(t, &values)
+		case 6:
+			table = append(table, string(contents[i]))
+		}
+	}
+
+	// One sample for each address in each call chain, the JSR's location being shared as the call site.
+	expectUint64(t, 3, uint64(counts[2]))
+	expectUint64(t, 3, uint64(counts[4]))
+	expectUint64(t, 2, uint64(counts[5]))
+	expectUint64(t, c.Cycles(), cycles)
+	expectString(t, "", table[0])
+
+	names := map[string]bool{}
+	for _, s := range table {
+		names[s] = true
+	}
+	for _, name := range []string{"sub", "$0200", "cycles", "instructions"} {
+		expectBool(t, true, names[name])
+	}
+}
@@ -0,0 +1,37 @@
+package mos6502
+
+import "fmt"
+
+/*
+Registers is the state of the processor's registers, with the flags packed into P as Status packs them.
+*/
+type Registers struct {
+	PC Address
+	AC byte
+	X  byte
+	Y  byte
+	SP byte
+	P  byte
+}
+
+/*
+Registers returns the state of the registers.
+*/
+func (c *Core) Registers() Registers {
+	return Registers{PC: c.PC, AC: c.AC, X: c.X, Y: c.Y, SP: c.SP, P: c.Status()}
+}
+
+/*
+String lists the registers.
+*/
+func (r Registers) String() string {
+	return fmt.Sprintf("PC=%04X AC=%02X X=%02X Y=%02X SP=%02X P=%02X", uint16(r.PC), r.AC, r.X, r.Y, r.SP, r.P)
+}
+
+/*
+SetRegisters sets the registers. Bits 4 and 5 of P only exist when the status is pushed, so they are ignored.
+*/
+func (c *Core) SetRegisters(r Registers) {
+	c.PC, c.AC, c.X, c.Y, c.SP = r.PC, r.AC, r.X, r.Y, r.SP
+	c.SetStatus(r.P)
+}
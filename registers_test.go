@@ -0,0 +1,13 @@
+package mos6502
+
+import "testing"
+
+func TestRegisters(t *testing.T) {
+	c := &Core{}
+	c.SetRegisters(Registers{PC: 0x1234, AC: 0x01, X: 0x02, Y: 0x03, SP: 0xFD, P: 0xFF})
+	expectCore(t, &Core{PC: 0x1234, AC: 0x01, X: 0x02, Y: 0x03, SP: 0xFD, Negative: true, Overflow: true,
+		Decimal: true, Interrupt: true, Zero: true, Carry: true}, c)
+
+	r := c.Registers()
+	expectString(t, "PC=1234 AC=01 X=02 Y=03 SP=FD P=EF", r.String())
+}
@@ -0,0 +1,183 @@
+package mos6502
+
+/*
+RIOT interrupt flags, as read back from the chip.
+*/
+const (
+	riotIntPA7   byte = 0x40
+	riotIntTimer byte = 0x80
+)
+
+/*
+riotIntervals are the timer prescalers picked by the low address bits when the timer is written.
+*/
+var riotIntervals = [4]uint16{1, 8, 64, 1024}
+
+/*
+RIOTRAM is the 128 bytes of static RAM inside a RIOT. It is mapped onto the Bus separately from the I/O and timer
+registers, since the chip selects between the two with its RS line.
+*/
+type RIOTRAM [128]byte
+
+/*
+Read returns a byte of RAM. Only seven address lines are decoded.
+*/
+func (m *RIOTRAM) Read(a Address) byte {
+	return m[a&0x7F]
+}
+
+/*
+Write stores a byte of RAM. Only seven address lines are decoded.
+*/
+func (m *RIOTRAM) Write(a Address, d byte) {
+	m[a&0x7F] = d
+}
+
+/*
+RIOT emulates the MOS 6532 RAM-I/O-Timer: 128 bytes of RAM, two 8-bit ports, an interval timer and an edge detector
+on PA7. The chip itself is mapped onto the Bus for the I/O and timer registers, and RAM is mapped on its own.
+*/
+type RIOT struct {
+	RAM   RIOTRAM
+	PortA Port
+	PortB Port
+
+	// Timer count, the prescaler it runs at and the cycles until it next counts.
+	timer    byte
+	interval uint16
+	prescale uint16
+
+	timerFlag bool
+	timerIRQ  bool
+
+	// PA7 edge detection: the flag, if it interrupts, if it looks for a rising edge and the last level seen.
+	pa7Flag     bool
+	pa7IRQ      bool
+	pa7Positive bool
+	pa7Last     bool
+}
+
+/*
+NewRIOT returns a RIOT in its reset state, with the port inputs pulled high.
+*/
+func NewRIOT() *RIOT {
+	r := &RIOT{
+		PortA: Port{Input: 0xFF},
+		PortB: Port{Input: 0xFF},
+	}
+	r.Reset()
+	return r
+}
+
+/*
+Reset clears the ports and interrupt logic as the RES line does. The timer keeps running and RAM is untouched.
+*/
+func (r *RIOT) Reset() {
+	r.PortA.setData(0)
+	r.PortA.setDDR(0)
+	r.PortB.setData(0)
+	r.PortB.setDDR(0)
+	r.timerIRQ, r.pa7Flag, r.pa7IRQ, r.pa7Positive = false, false, false, false
+	r.pa7Last = r.PortA.Pins()&0x80 != 0
+	if r.interval == 0 {
+		r.interval, r.prescale = 1, 1
+	}
+}
+
+/*
+Read returns the value of a register. Reading the timer clears its interrupt flag, reading the flags clears the PA7
+flag.
+*/
+func (r *RIOT) Read(a Address) byte {
+	if a&0x04 == 0 {
+		switch a & 0x03 {
+		case 0:
+			return r.PortA.Pins()
+		case 1:
+			return r.PortA.DDR
+		case 2:
+			return (r.PortB.Data & r.PortB.DDR) | (r.PortB.Pins() &^ r.PortB.DDR)
+		default:
+			return r.PortB.DDR
+		}
+	}
+
+	if a&0x01 == 0 {
+		r.timerIRQ = a&0x08 != 0
+		r.timerFlag = false
+		return r.timer
+	}
+
+	var flags byte
+	if r.timerFlag {
+		flags |= riotIntTimer
+	}
+	if r.pa7Flag {
+		flags |= riotIntPA7
+	}
+	r.pa7Flag = false
+	return flags
+}
+
+/*
+Write stores a value in a register. With A2 set, A4 picks between starting the timer and setting up the PA7 edge
+detector, and the low address bits carry the options.
+*/
+func (r *RIOT) Write(a Address, d byte) {
+	if a&0x04 == 0 {
+		switch a & 0x03 {
+		case 0:
+			r.PortA.setData(d)
+		case 1:
+			r.PortA.setDDR(d)
+		case 2:
+			r.PortB.setData(d)
+		default:
+			r.PortB.setDDR(d)
+		}
+		return
+	}
+
+	if a&0x10 != 0 {
+		r.timer = d
+		r.interval = riotIntervals[a&0x03]
+		r.prescale = r.interval
+		r.timerIRQ = a&0x08 != 0
+		r.timerFlag = false
+		return
+	}
+
+	r.pa7Positive = a&0x01 != 0
+	r.pa7IRQ = a&0x02 != 0
+}
+
+/*
+Tick advances the chip by one cycle of the system clock. Once the timer passes zero it flags an interrupt and keeps
+counting down once per cycle until it is written again.
+*/
+func (r *RIOT) Tick() {
+	pa7 := r.PortA.Pins()&0x80 != 0
+	if pa7 != r.pa7Last && pa7 == r.pa7Positive {
+		r.pa7Flag = true
+	}
+	r.pa7Last = pa7
+
+	r.prescale--
+	if r.prescale > 0 {
+		return
+	}
+	r.prescale = r.interval
+
+	r.timer--
+	if r.timer == 0xFF {
+		r.timerFlag = true
+		r.interval, r.prescale = 1, 1
+	}
+}
+
+/*
+Interrupting reports if the IRQ output is asserted.
+*/
+func (r *RIOT) Interrupting() bool {
+	return (r.timerFlag && r.timerIRQ) || (r.pa7Flag && r.pa7IRQ)
+}
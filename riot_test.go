@@ -0,0 +1,113 @@
+package mos6502
+
+import "testing"
+
+func TestRIOTRAM(t *testing.T) {
+	r := NewRIOT()
+	b := Bus{}
+	b.Map(0x0080, 0x00FF, &r.RAM)
+	b.Map(0x0180, 0x01FF, &r.RAM)
+
+	b.Write(0x0080, 0x12)
+	b.Write(0x00FF, 0x34)
+
+	// The stack page mirrors the same RAM, as it does on the Atari 2600.
+	expectByte(t, 0x12, b.Read(0x0180))
+	expectByte(t, 0x34, b.Read(0x01FF))
+	expectByte(t, 0x34, r.RAM[0x7F])
+}
+
+func TestRIOTPorts(t *testing.T) {
+	r := NewRIOT()
+	r.PortB.Input = 0x0F
+
+	r.Write(0x03, 0xF0)
+	r.Write(0x02, 0xA5)
+	r.Write(0x01, 0xFF)
+	r.Write(0x00, 0x3C)
+
+	expectByte(t, 0xAF, r.Read(0x02))
+	expectByte(t, 0xF0, r.Read(0x03))
+	expectByte(t, 0x3C, r.Read(0x00))
+	expectByte(t, 0x3C, r.PortA.Pins())
+}
+
+func TestRIOTTimer(t *testing.T) {
+	var tests = map[string]struct {
+		register Address
+		value    byte
+		ticks    int
+		timer    byte
+		flagged  bool
+	}{
+		"1 cycle":          {register: 0x14, value: 0x03, ticks: 3, timer: 0x00, flagged: false},
+		"8 cycles":         {register: 0x15, value: 0x02, ticks: 16, timer: 0x00, flagged: false},
+		"8 cycles partial": {register: 0x15, value: 0x02, ticks: 15, timer: 0x01, flagged: false},
+		"8 cycles expired": {register: 0x15, value: 0x02, ticks: 24, timer: 0xFF, flagged: true},
+		"after expiry":     {register: 0x15, value: 0x02, ticks: 26, timer: 0xFD, flagged: true},
+		"64 cycles":        {register: 0x16, value: 0x01, ticks: 63, timer: 0x01, flagged: false},
+		"1024 cycles":      {register: 0x17, value: 0x01, ticks: 2048, timer: 0xFF, flagged: true},
+	}
+
+	for k, tt := range tests {
+		t.Run(k, func(t *testing.T) {
+			r := NewRIOT()
+			r.Write(tt.register, tt.value)
+			for i := 0; i < tt.ticks; i++ {
+				r.Tick()
+			}
+
+			expectBool(t, tt.flagged, r.Read(0x05)&riotIntTimer != 0)
+			expectByte(t, tt.timer, r.Read(0x04))
+			expectBool(t, false, r.Read(0x05)&riotIntTimer != 0)
+		})
+	}
+}
+
+func TestRIOTTimerInterrupt(t *testing.T) {
+	r := NewRIOT()
+
+	// A3 enables the interrupt when writing the timer.
+	r.Write(0x1C, 0x00)
+	r.Tick()
+	expectBool(t, true, r.Interrupting())
+
+	// Reading through an address without A3 clears the flag and disables it.
+	r.Read(0x04)
+	expectBool(t, false, r.Interrupting())
+	r.Write(0x14, 0x00)
+	r.Tick()
+	expectBool(t, false, r.Interrupting())
+}
+
+func TestRIOTEdgeDetect(t *testing.T) {
+	var tests = map[string]struct {
+		register Address
+		falling  bool
+		rising   bool
+		irq      bool
+	}{
+		"negative":           {register: 0x04, falling: true},
+		"positive":           {register: 0x05, rising: true},
+		"negative interrupt": {register: 0x06, falling: true, irq: true},
+		"positive interrupt": {register: 0x07, rising: true, irq: true},
+	}
+
+	for k, tt := range tests {
+		t.Run(k, func(t *testing.T) {
+			r := NewRIOT()
+			r.Write(tt.register, 0x00)
+
+			r.PortA.Input = 0x7F
+			r.Tick()
+			expectBool(t, tt.falling, r.pa7Flag)
+			expectBool(t, tt.falling && tt.irq, r.Interrupting())
+			r.Read(0x05)
+
+			r.PortA.Input = 0xFF
+			r.Tick()
+			expectBool(t, tt.rising, r.Read(0x05)&riotIntPA7 != 0)
+			expectBool(t, false, r.Interrupting())
+		})
+	}
+}
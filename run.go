@@ -0,0 +1,250 @@
+package mos6502
+
+import (
+	"context"
+	"fmt"
+)
+
+/*
+StopReason says why Run returned.
+*/
+type StopReason int
+
+const (
+	// The cycle budget was used up.
+	StopCycles StopReason = iota
+	// The instruction budget was used up.
+	StopInstructions
+	// The context was cancelled.
+	StopCancelled
+	// The program counter reached a breakpoint.
+	StopBreakpoint
+	// The processor fetched a JAM opcode and locked up.
+	StopJam
+	// An instruction jumped or branched to itself, the way test programs signal they are finished or have failed.
+	// Only checked for when the budget asks.
+	StopLoop
+	// Two Cores run in lockstep stopped agreeing.
+	StopDiverged
+	// The program counter reached a stop address.
+	StopAddress
+)
+
+func (r StopReason) String() string {
+	switch r {
+	case StopCycles:
+		return "cycle budget exhausted"
+	case StopInstructions:
+		return "instruction budget exhausted"
+	case StopCancelled:
+		return "cancelled"
+	case StopBreakpoint:
+		return "breakpoint"
+	case StopJam:
+		return "jam"
+	case StopLoop:
+		return "loop"
+	case StopDiverged:
+		return "diverged"
+	case StopAddress:
+		return "stop address"
+	default:
+		return fmt.Sprintf("StopReason(%d)", int(r))
+	}
+}
+
+/*
+Budget limits how long Run may go for. A zero limit means no limit.
+*/
+type Budget struct {
+	Cycles       uint64
+	Instructions uint64
+
+	// Stop as soon as an instruction jumps or branches to itself. Test programs do this when they are finished, but
+	// interrupt driven programs idle this way waiting for their next interrupt, so it is off unless asked for.
+	StopOnLoop bool
+}
+
+/*
+cancelEvery is how many instructions Run executes between checks of its context.
+*/
+const cancelEvery = 1024
+
+/*
+SetBreakpoint makes Run stop before executing the instruction at the address.
+*/
+func (c *Core) SetBreakpoint(a Address) {
+	if c.breakpoints == nil {
+		c.breakpoints = map[Address]bool{}
+	}
+	c.breakpoints[a] = true
+}
+
+/*
+ClearBreakpoint removes a breakpoint set with SetBreakpoint.
+*/
+func (c *Core) ClearBreakpoint(a Address) {
+	delete(c.breakpoints, a)
+}
+
+/*
+Step finishes the current instruction if a Tick left one part way through, then runs the next instruction, or takes a
+pending interrupt, to completion. Attached devices are ticked through every cycle. Returns the number of cycles run.
+*/
+func (c *Core) Step() int {
+	start := c.cycles
+	for c.opCycles > 0 {
+		c.Tick()
+	}
+
+	c.clock()
+	if !c.serviceInterrupt() {
+		c.step()
+	}
+	c.finish()
+
+	return int(c.cycles - start)
+}
+
+/*
+SetStopAddress makes Run stop when the program counter reaches the address, such as where a test program goes when it
+has passed. Unlike a breakpoint, a stop address at the program counter when Run is called stops it straight away.
+*/
+func (c *Core) SetStopAddress(a Address) {
+	if c.stops == nil {
+		c.stops = map[Address]bool{}
+	}
+	c.stops[a] = true
+}
+
+/*
+ClearStopAddress removes a stop address set with SetStopAddress.
+*/
+func (c *Core) ClearStopAddress(a Address) {
+	delete(c.stops, a)
+}
+
+/*
+check reports if Run should stop before the next instruction, after n instructions since it was called at the
+given cycle. The first instruction is never stopped for a breakpoint, so a stopped run can be resumed, but is for a
+stop address.
+*/
+func (c *Core) check(b Budget, n uint64, start uint64) (StopReason, bool) {
+	switch {
+	case b.Instructions > 0 && n >= b.Instructions:
+		return StopInstructions, true
+	case b.Cycles > 0 && c.cycles-start >= b.Cycles:
+		return StopCycles, true
+	case len(c.stops) > 0 && c.stops[c.PC]:
+		return StopAddress, true
+	case n > 0 && len(c.breakpoints) > 0 && c.breakpoints[c.PC]:
+		return StopBreakpoint, true
+	}
+	return 0, false
+}
+
+/*
+finish runs out the cycles left of the current instruction.
+*/
+func (c *Core) finish() {
+	if len(c.devices) == 0 {
+		c.cycles += uint64(c.opCycles)
+		c.opCycles = 0
+	}
+	for c.opCycles > 0 {
+		c.opCycles--
+		c.clock()
+	}
+}
+
+/*
+step fetches and executes the next instruction, or runs a trap standing in for it, leaving opCycles holding the cycles
+it has left to run.
+*/
+func (c *Core) step() {
+	if len(c.traps) > 0 && c.trap() {
+		return
+	}
+	c.execute()
+}
+
+/*
+execute fetches and executes the instruction at the program counter, without looking for a trap there.
+*/
+func (c *Core) execute() {
+	op := c.Fetch()
+
+	// A jammed processor never moves on, but it is left to burn cycles rather than hang its caller.
+	c.jammed = op.Jams()
+	if c.jammed {
+		return
+	}
+
+	pc := c.PC
+	if c.smc != nil {
+		c.smc.fetch(&c.Bus, pc, op)
+	}
+	for _, o := range c.observers {
+		o.Fetched(c, pc, op)
+	}
+
+	c.PC += Address(op.Size())
+	c.opCycles = opcodes[op.Code].cycles + dispatch[op.Code](c, op) - 1
+
+	for _, o := range c.observers {
+		o.Retired(c, pc, op, int(c.opCycles)+1)
+	}
+}
+
+/*
+Run executes instructions until the budget is used up, ctx is cancelled, a breakpoint, stop address or JAM opcode is
+reached, or, if the budget asks, an instruction loops by jumping to itself. Budgets are checked between instructions,
+so the cycle budget may be overrun by the length of the last instruction. A breakpoint at the program counter when Run
+is called is stepped over, so a stopped run can be resumed.
+*/
+func (c *Core) Run(ctx context.Context, b Budget) StopReason {
+	return c.runSteps(ctx, b, c.Step)
+}
+
+/*
+runSteps is Run for engines which watch each instruction, calling step in place of Core.Step.
+*/
+func (c *Core) runSteps(ctx context.Context, b Budget, step func() int) StopReason {
+	done := ctx.Done()
+	start := c.cycles
+
+	for n := uint64(0); ; n++ {
+		if n%cancelEvery == 0 && done != nil {
+			select {
+			case <-done:
+				return StopCancelled
+			default:
+			}
+		}
+		if r, stop := c.check(b, n, start); stop {
+			return r
+		}
+
+		pc := c.PC
+		step()
+		if r, stop := c.stuck(pc, b); stop {
+			return r
+		}
+	}
+}
+
+/*
+stuck reports if the processor is stuck at pc after running an instruction from there, either jammed or, if the budget
+asks, looping on an instruction which jumped to itself.
+*/
+func (c *Core) stuck(pc Address, b Budget) (StopReason, bool) {
+	switch {
+	case c.PC != pc:
+		return 0, false
+	case c.jammed:
+		return StopJam, true
+	case b.StopOnLoop:
+		return StopLoop, true
+	}
+	return 0, false
+}
@@ -0,0 +1,190 @@
+package mos6502
+
+import (
+	"context"
+	"testing"
+)
+
+/*
+nopCore returns a Core about to run a page of NOPs from $0200.
+*/
+func nopCore() *Core {
+	c := &Core{PC: 0x0200, SP: 0xFF}
+	for i := Address(0); i < 0x100; i++ {
+		c.Bus.Write(0x0200+i, 0xEA)
+	}
+	return c
+}
+
+func TestRun(t *testing.T) {
+	var tests = map[string]struct {
+		budget Budget
+		jam    Address
+		reason StopReason
+		pc     Address
+		cycles uint64
+	}{
+		"instructions":     {budget: Budget{Instructions: 3}, reason: StopInstructions, pc: 0x0203, cycles: 6},
+		"cycles":           {budget: Budget{Cycles: 6}, reason: StopCycles, pc: 0x0203, cycles: 6},
+		"cycles overrun":   {budget: Budget{Cycles: 5}, reason: StopCycles, pc: 0x0203, cycles: 6},
+		"first limit wins": {budget: Budget{Cycles: 100, Instructions: 2}, reason: StopInstructions, pc: 0x0202, cycles: 4},
+
+		// Fetching a JAM opcode takes a cycle before the processor locks up.
+		"jam":               {budget: Budget{Instructions: 10}, jam: 0x0204, reason: StopJam, pc: 0x0204, cycles: 9},
+		"jam at the start":  {budget: Budget{}, jam: 0x0200, reason: StopJam, pc: 0x0200, cycles: 1},
+		"unlimited to jam":  {budget: Budget{}, jam: 0x0280, reason: StopJam, pc: 0x0280, cycles: 0x101},
+		"zero is unlimited": {budget: Budget{Cycles: 0, Instructions: 0}, jam: 0x0201, reason: StopJam, pc: 0x0201, cycles: 3},
+	}
+
+	for k, tt := range tests {
+		t.Run(k, func(t *testing.T) {
+			c := nopCore()
+			if tt.jam != 0 {
+				c.Bus.Write(tt.jam, 0x02)
+			}
+
+			reason := c.Run(context.Background(), tt.budget)
+
+			expectString(t, tt.reason.String(), reason.String())
+			expectAddress(t, tt.pc, c.PC)
+			expectUint64(t, tt.cycles, c.Cycles())
+		})
+	}
+}
+
+func TestRunBreakpoint(t *testing.T) {
+	c := nopCore()
+	c.SetBreakpoint(0x0204)
+
+	expectString(t, StopBreakpoint.String(), c.Run(context.Background(), Budget{}).String())
+	expectAddress(t, 0x0204, c.PC)
+
+	// Resuming steps over the breakpoint.
+	expectString(t, StopInstructions.String(), c.Run(context.Background(), Budget{Instructions: 1}).String())
+	expectAddress(t, 0x0205, c.PC)
+
+	c.PC = 0x0200
+	c.ClearBreakpoint(0x0204)
+	expectString(t, StopInstructions.String(), c.Run(context.Background(), Budget{Instructions: 8}).String())
+	expectAddress(t, 0x0208, c.PC)
+}
+
+func TestRunStopAddress(t *testing.T) {
+	var tests = map[string]func(c *Core) Runner{
+		"interpreter": func(c *Core) Runner { return c },
+		"block cache": func(c *Core) Runner { return NewBlockCache(c) },
+	}
+
+	for k, runner := range tests {
+		t.Run(k, func(t *testing.T) {
+			c := nopCore()
+			c.SetStopAddress(0x0204)
+			r := runner(c)
+
+			expectString(t, StopAddress.String(), r.Run(context.Background(), Budget{}).String())
+			expectAddress(t, 0x0204, c.PC)
+
+			// Unlike a breakpoint, the stop address isn't stepped over.
+			expectString(t, StopAddress.String(), r.Run(context.Background(), Budget{}).String())
+			expectAddress(t, 0x0204, c.PC)
+
+			c.ClearStopAddress(0x0204)
+			expectString(t, StopInstructions.String(), r.Run(context.Background(), Budget{Instructions: 1}).String())
+			expectAddress(t, 0x0205, c.PC)
+		})
+	}
+}
+
+func TestRunCancelled(t *testing.T) {
+	c := nopCore()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	expectString(t, StopCancelled.String(), c.Run(ctx, Budget{}).String())
+	expectAddress(t, 0x0200, c.PC)
+}
+
+func TestStep(t *testing.T) {
+	c := nopCore()
+	var ticks counter
+	c.Attach(&ticks)
+
+	expectUint64(t, 2, uint64(c.Step()))
+	expectAddress(t, 0x0201, c.PC)
+	expectUint64(t, 2, uint64(ticks))
+
+	// An instruction left part way through by Tick is finished off first.
+	c.opCycles = 1
+	expectUint64(t, 3, uint64(c.Step()))
+	expectUint64(t, 5, c.Cycles())
+
+	// Interrupts are taken in place of an instruction.
+	irq := line(true)
+	c.ConnectIRQ(&irq)
+	c.Bus.Write(0xFFFE, 0x00)
+	c.Bus.Write(0xFFFF, 0x03)
+	expectUint64(t, 7, uint64(c.Step()))
+	expectAddress(t, 0x0300, c.PC)
+	expectUint64(t, 12, uint64(ticks))
+}
+
+func TestRunLoop(t *testing.T) {
+	c := nopCore()
+
+	// JMP $0203, jumping to itself.
+	c.Bus.Write(0x0203, 0x4C)
+	c.Bus.Write(0x0204, 0x03)
+	c.Bus.Write(0x0205, 0x02)
+
+	expectString(t, StopLoop.String(), c.Run(context.Background(), Budget{StopOnLoop: true}).String())
+	expectAddress(t, 0x0203, c.PC)
+	expectUint64(t, 9, c.Cycles())
+}
+
+/*
+Test that a program idling in a loop while it waits for interrupts runs until the budget is used up, unless asked to
+stop on loops.
+*/
+func TestRunIdleLoop(t *testing.T) {
+	var tests = map[string]func(c *Core) Runner{
+		"interpreter":   func(c *Core) Runner { return c },
+		"block cache":   func(c *Core) Runner { return NewBlockCache(c) },
+		"profiled":      func(c *Core) Runner { NewProfiler(c); return c },
+		"covered":       func(c *Core) Runner { NewCoverage(c); return c },
+		"stack tracked": func(c *Core) Runner { NewStackTracker(c); return c },
+	}
+
+	for k, runner := range tests {
+		t.Run(k, func(t *testing.T) {
+			c := &Core{PC: 0x0200, SP: 0xFF}
+			// CLI, JMP $0201
+			for i, d := range []byte{0x58, 0x4C, 0x01, 0x02} {
+				c.Bus.Write(0x0200+Address(i), d)
+			}
+			// LDA $9004, INC $10, RTI
+			for i, d := range []byte{0xAD, 0x04, 0x90, 0xE6, 0x10, 0x40} {
+				c.Bus.Write(0x0300+Address(i), d)
+			}
+			c.Bus.Write(IRQVector, 0x00)
+			c.Bus.Write(IRQVector+1, 0x03)
+
+			via := NewVIA()
+			via.Write(viaACR, 0x40)
+			via.Write(viaIER, 0x80|viaIntT1)
+			via.Write(viaT1CL, 100)
+			via.Write(viaT1CH, 0x00)
+			c.Bus.Map(0x9000, 0x900F, via)
+			c.Attach(via)
+			c.ConnectIRQ(via)
+			r := runner(c)
+
+			expectString(t, StopCycles.String(), r.Run(context.Background(), Budget{Cycles: 10000}).String())
+			expectBool(t, true, c.Cycles() >= 10000)
+			// The timer interrupts every 102 cycles, 97 times before the budget runs out.
+			expectByte(t, 97, c.Bus.Read(0x0010))
+
+			expectString(t, StopLoop.String(), r.Run(context.Background(), Budget{StopOnLoop: true}).String())
+			expectAddress(t, 0x0201, c.PC)
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package mos6502
+
+import "container/heap"
+
+/*
+Scheduler owns the master clock of a whole system. Each master cycle it runs any events that have come due, then
+ticks every device and the Core whose divider says it is their turn, so everything runs in lockstep. Devices added to
+a Scheduler should not also be attached to the Core, or they will be ticked twice.
+*/
+type Scheduler struct {
+	Core *Core
+
+	cycle  uint64
+	clocks []clock
+	events events
+	seq    uint64
+}
+
+/*
+clock is something ticked once every divider master cycles, and how many cycles are left until it is next ticked.
+*/
+type clock struct {
+	ticker  Ticker
+	divider uint64
+	wait    uint64
+}
+
+/*
+Event is a function scheduled to run on a given master cycle.
+*/
+type Event struct {
+	// Master cycle the event runs on.
+	At uint64
+
+	run   func()
+	seq   uint64
+	index int
+}
+
+/*
+NewScheduler returns a Scheduler which ticks c once every divider master cycles.
+*/
+func NewScheduler(c *Core, divider uint64) *Scheduler {
+	s := &Scheduler{Core: c}
+	s.Add(c, divider)
+	return s
+}
+
+/*
+Add clocks a device from the Scheduler, ticking it once every divider master cycles. Devices are ticked in the order
+they were added, after the Core.
+*/
+func (s *Scheduler) Add(t Ticker, divider uint64) {
+	if divider == 0 {
+		divider = 1
+	}
+	s.clocks = append(s.clocks, clock{ticker: t, divider: divider, wait: 0})
+}
+
+/*
+ConnectIRQ wires a device's interrupt output onto the Core's IRQ line, alongside any others.
+*/
+func (s *Scheduler) ConnectIRQ(i Interrupter) {
+	s.Core.ConnectIRQ(i)
+}
+
+/*
+ConnectNMI wires a device's interrupt output onto the Core's NMI line, alongside any others.
+*/
+func (s *Scheduler) ConnectNMI(i Interrupter) {
+	s.Core.ConnectNMI(i)
+}
+
+/*
+Cycle returns the number of master cycles that have run.
+*/
+func (s *Scheduler) Cycle() uint64 {
+	return s.cycle
+}
+
+/*
+Schedule runs f once delay master cycles from now, before anything is ticked on that cycle. Events due on the same
+cycle run in the order they were scheduled.
+*/
+func (s *Scheduler) Schedule(delay uint64, f func()) *Event {
+	e := &Event{At: s.cycle + delay, run: f, seq: s.seq}
+	s.seq++
+	heap.Push(&s.events, e)
+	return e
+}
+
+/*
+Cancel stops a scheduled event from running. Cancelling an event which has already run does nothing.
+*/
+func (s *Scheduler) Cancel(e *Event) {
+	if e.index < 0 || e.index >= len(s.events) || s.events[e.index] != e {
+		return
+	}
+	heap.Remove(&s.events, e.index)
+}
+
+/*
+Tick advances the master clock by one cycle.
+*/
+func (s *Scheduler) Tick() {
+	for len(s.events) > 0 && s.events[0].At <= s.cycle {
+		e := heap.Pop(&s.events).(*Event)
+		e.run()
+	}
+
+	for i := range s.clocks {
+		c := &s.clocks[i]
+		if c.wait > 0 {
+			c.wait--
+			continue
+		}
+		c.wait = c.divider - 1
+		c.ticker.Tick()
+	}
+
+	s.cycle++
+}
+
+/*
+Run advances the master clock by the given number of cycles.
+*/
+func (s *Scheduler) Run(cycles uint64) {
+	for end := s.cycle + cycles; s.cycle < end; {
+		s.Tick()
+	}
+}
+
+/*
+events is a queue of scheduled events, soonest first.
+*/
+type events []*Event
+
+func (q events) Len() int {
+	return len(q)
+}
+
+func (q events) Less(i, j int) bool {
+	if q[i].At != q[j].At {
+		return q[i].At < q[j].At
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q events) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *events) Push(x interface{}) {
+	e := x.(*Event)
+	e.index = len(*q)
+	*q = append(*q, e)
+}
+
+func (q *events) Pop() interface{} {
+	old := *q
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	e.index = -1
+	*q = old[:len(old)-1]
+	return e
+}
@@ -0,0 +1,84 @@
+package mos6502
+
+import "testing"
+
+/*
+counter is a device which counts its ticks.
+*/
+type counter int
+
+func (c *counter) Tick() {
+	*c++
+}
+
+func TestSchedulerDividers(t *testing.T) {
+	// The Core is kept busy so that it only counts down its cycles.
+	c := &Core{opCycles: 0xFF}
+	s := NewScheduler(c, 4)
+
+	var every, second, third counter
+	s.Add(&every, 1)
+	s.Add(&second, 2)
+	s.Add(&third, 3)
+
+	s.Run(12)
+
+	expectUint64(t, 12, s.Cycle())
+	expectUint8(t, 0xFF-3, c.opCycles)
+	expectUint64(t, 12, uint64(every))
+	expectUint64(t, 6, uint64(second))
+	expectUint64(t, 4, uint64(third))
+}
+
+func TestSchedulerEvents(t *testing.T) {
+	s := NewScheduler(&Core{opCycles: 0xFF}, 1)
+
+	var order []uint64
+	record := func() { order = append(order, s.Cycle()) }
+
+	s.Schedule(5, record)
+	s.Schedule(2, record)
+	cancelled := s.Schedule(3, record)
+	s.Schedule(2, func() {
+		record()
+		s.Schedule(0, record)
+		s.Schedule(4, record)
+	})
+	s.Cancel(cancelled)
+
+	s.Run(10)
+
+	expected := []uint64{2, 2, 2, 5, 6}
+	if len(order) != len(expected) {
+		t.Fatalf("Expected \"%v\" but got \"%v\".", expected, order)
+	}
+	for i := range expected {
+		expectUint64(t, expected[i], order[i])
+	}
+
+	// Cancelling an event that has run is harmless.
+	s.Cancel(cancelled)
+}
+
+func TestSchedulerInterrupts(t *testing.T) {
+	c := &Core{SP: 0xFF}
+	c.Bus.Write(0xFFFA, 0x00)
+	c.Bus.Write(0xFFFB, 0x90)
+	c.Bus.Write(0xFFFE, 0x00)
+	c.Bus.Write(0xFFFF, 0x80)
+	s := NewScheduler(c, 1)
+
+	quiet, irq, nmi := line(false), line(false), line(false)
+	s.ConnectIRQ(&quiet)
+	s.ConnectIRQ(&irq)
+	s.ConnectNMI(&nmi)
+
+	// Any device on the shared line can interrupt.
+	s.Schedule(0, func() { irq = true })
+	s.Tick()
+	expectAddress(t, 0x8000, c.PC)
+
+	s.Schedule(6, func() { nmi = true })
+	s.Run(7)
+	expectAddress(t, 0x9000, c.PC)
+}
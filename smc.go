@@ -0,0 +1,107 @@
+package mos6502
+
+/*
+SelfModification is a write which changed a byte the processor had fetched as part of an instruction.
+*/
+type SelfModification struct {
+	// Address of the instruction which made the write, or of the instruction interrupted for an interrupt's pushes.
+	PC Address
+
+	// Address written to, and the byte there before and after.
+	Address Address
+	Old     byte
+	New     byte
+
+	// If the byte was fetched as an opcode rather than as part of an operand. Bytes fetched as both count as opcodes.
+	Opcode bool
+}
+
+/*
+smcDetector remembers which bytes have been fetched as instructions, to tell when one is written to.
+*/
+type smcDetector struct {
+	report  func(SelfModification)
+	fetched [0x10000]CoverageFlags
+
+	// Address of the instruction running.
+	pc Address
+}
+
+/*
+DetectSelfModification has report called for every write which changes a byte previously fetched as the opcode or
+operand of an instruction, or stops detection if report is nil. Each call starts afresh, forgetting what was fetched
+before it. Writes which leave a byte as it was, such as the first write of a read-modify-write instruction, are not
+reported, and neither are writes to devices, which decide for themselves what a write does.
+
+Writes made straight to the Bus are reported too, with the address of the last instruction run. A BlockCache leaves
+all the running to the interpreter while detection is on, and pages holding fetched code are written through the Bus
+slow path.
+*/
+func (c *Core) DetectSelfModification(report func(SelfModification)) {
+	bus := &c.Bus
+	for p := 0; p < 0x100; p++ {
+		if bus.guarded[p] {
+			bus.protect(byte(p), false)
+		}
+	}
+	bus.guard = nil
+	c.smc = nil
+
+	if report != nil {
+		c.smc = &smcDetector{report: report}
+		bus.guard = c.smc.write(bus)
+	}
+}
+
+/*
+fetch records the bytes of the instruction at pc as fetched, guarding the pages they are on.
+*/
+func (s *smcDetector) fetch(b *Bus, pc Address, op Operation) {
+	s.pc = pc
+	for i := Address(0); i < Address(op.Size()); i++ {
+		a := pc + i
+		if i == 0 {
+			s.fetched[a] |= CoveredOpcode
+		} else {
+			s.fetched[a] |= CoveredOperand
+		}
+		if !b.guarded[a>>8] {
+			b.protect(byte(a>>8), true)
+		}
+	}
+}
+
+/*
+write returns the Bus guard, which reports a write of d to an address if it changes a fetched byte.
+*/
+func (s *smcDetector) write(b *Bus) func(a Address, d byte) {
+	return func(a Address, d byte) {
+		f := s.fetched[a]
+		if f == 0 {
+			return
+		}
+		if _, ok := b.mapped(a); ok {
+			return
+		}
+
+		old := b.data[a]
+		if b.mem != nil {
+			old = b.mem[a]
+		}
+		if old != d {
+			s.report(SelfModification{PC: s.pc, Address: a, Old: old, New: d, Opcode: f&CoveredOpcode != 0})
+		}
+	}
+}
+
+/*
+guardFetched guards every page holding a fetched byte, for when fetches were recorded while the pages were guarded for
+something else.
+*/
+func (s *smcDetector) guardFetched(b *Bus) {
+	for a := range s.fetched {
+		if s.fetched[a] != 0 && !b.guarded[a>>8] {
+			b.protect(byte(a>>8), true)
+		}
+	}
+}
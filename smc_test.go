@@ -0,0 +1,120 @@
+package mos6502
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDetectSelfModification(t *testing.T) {
+	var tests = map[string]struct {
+		code     []byte
+		expected []SelfModification
+	}{
+		"operand": {
+			code: []byte{
+				0xA9, 0x05, // 0200 LDA #$05
+				0x8D, 0x03, 0x02, // 0202 STA $0203
+				0x4C, 0x05, 0x02, // 0205 JMP $0205
+			},
+			expected: []SelfModification{{PC: 0x0202, Address: 0x0203, Old: 0x03, New: 0x05}},
+		},
+		"opcode": {
+			code: []byte{
+				0xA9, 0x4C, // 0200 LDA #$4C
+				0x8D, 0x00, 0x02, // 0202 STA $0200
+				0x4C, 0x05, 0x02, // 0205 JMP $0205
+			},
+			expected: []SelfModification{{PC: 0x0202, Address: 0x0200, Old: 0xA9, New: 0x4C, Opcode: true}},
+		},
+		"read-modify-write": {
+			code: []byte{
+				0xA2, 0x01, // 0200 LDX #$01
+				0xEE, 0x01, 0x02, // 0202 INC $0201
+				0x4C, 0x05, 0x02, // 0205 JMP $0205
+			},
+			expected: []SelfModification{{PC: 0x0202, Address: 0x0201, Old: 0x01, New: 0x02}},
+		},
+		"code not yet run": {
+			code: []byte{
+				0xA9, 0x05, // 0200 LDA #$05
+				0x8D, 0x08, 0x02, // 0202 STA $0208
+				0x4C, 0x05, 0x02, // 0205 JMP $0205
+				0xEA, // 0208 NOP, never run
+			},
+		},
+		"unchanged": {
+			code: []byte{
+				0xA9, 0x8D, // 0200 LDA #$8D
+				0x8D, 0x02, 0x02, // 0202 STA $0202
+				0x4C, 0x05, 0x02, // 0205 JMP $0205
+			},
+		},
+	}
+
+	var runners = map[string]func(c *Core) Runner{
+		"interpreter": func(c *Core) Runner { return c },
+		"block cache": func(c *Core) Runner { return NewBlockCache(c) },
+	}
+
+	for k, tt := range tests {
+		for r, runner := range runners {
+			t.Run(k+"/"+r, func(t *testing.T) {
+				c := profileCore(map[Address][]byte{0x0200: tt.code})
+				var found []SelfModification
+				c.DetectSelfModification(func(m SelfModification) {
+					found = append(found, m)
+				})
+				expectLoop(t, c, runner(c).Run(context.Background(), Budget{Instructions: 100, StopOnLoop: true}))
+
+				if len(found) != len(tt.expected) {
+					t.Fatalf("Expected %v but got %v.", tt.expected, found)
+				}
+				for i := range found {
+					if found[i] != tt.expected[i] {
+						t.Errorf("Expected %v but got %v.", tt.expected[i], found[i])
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestDetectSelfModificationStack(t *testing.T) {
+	c := profileCore(map[Address][]byte{
+		0x0200: {0x20, 0x00, 0x03}, // 0200 JSR $0300
+		0x0130: {0x60},             // 0130 RTS
+		0x0300: {0x60},             // 0300 RTS
+	})
+
+	var found []SelfModification
+	c.DetectSelfModification(func(m SelfModification) {
+		found = append(found, m)
+	})
+
+	// Run the code on the stack page, then call a subroutine with the stack pointer just above it.
+	c.PC = 0x0130
+	c.Step()
+	c.PC = 0x0200
+	c.SP = 0x31
+	c.Step()
+
+	expected := SelfModification{PC: 0x0200, Address: 0x0130, Old: 0x60, New: 0x02, Opcode: true}
+	if len(found) != 1 || found[0] != expected {
+		t.Errorf("Expected [%v] but got %v.", expected, found)
+	}
+}
+
+func TestDetectSelfModificationOff(t *testing.T) {
+	c := profileCore(map[Address][]byte{0x0200: {
+		0xA9, 0x05, // 0200 LDA #$05
+		0x8D, 0x01, 0x02, // 0202 STA $0201
+		0x4C, 0x05, 0x02, // 0205 JMP $0205
+	}})
+
+	found := 0
+	c.DetectSelfModification(func(m SelfModification) { found++ })
+	c.DetectSelfModification(nil)
+	expectLoop(t, c, c.Run(context.Background(), Budget{Instructions: 100, StopOnLoop: true}))
+	expectUint64(t, 0, uint64(found))
+	expectByte(t, 0x05, c.Bus.Read(0x0201))
+}
@@ -0,0 +1,306 @@
+package mos6502
+
+import "fmt"
+
+/*
+FrameKind is what put a frame on the stack.
+*/
+type FrameKind uint8
+
+/*
+The kinds of stack frame.
+*/
+const (
+	// A subroutine call by JSR, which pushes the return address less one.
+	FrameCall FrameKind = iota
+
+	// A BRK, or an IRQ or NMI taken, which push the return address and the status.
+	FrameBreak
+	FrameInterrupt
+)
+
+/*
+String names the kind of frame.
+*/
+func (k FrameKind) String() string {
+	switch k {
+	case FrameCall:
+		return "call"
+	case FrameBreak:
+		return "break"
+	case FrameInterrupt:
+		return "interrupt"
+	}
+	return fmt.Sprintf("FrameKind(%d)", uint8(k))
+}
+
+/*
+StackFrame is a call or interrupt in progress, as found on the stack.
+*/
+type StackFrame struct {
+	Kind FrameKind
+
+	// The first instruction of the subroutine or handler, and the JSR or BRK which entered it, or the instruction
+	// an interrupt was taken before.
+	Entry Address
+	Site  Address
+
+	// The stack pointer from before the frame was pushed.
+	SP byte
+
+	// Where the frame returns to as read from the stack now, and the status pushed with it by a BRK or interrupt.
+	// Intact is false if the stack no longer holds what was pushed.
+	Return Address
+	Status byte
+	Intact bool
+
+	// Bytes of stack the frame takes: its return state and anything pushed since, up to the next frame or the top
+	// of the stack.
+	Size int
+}
+
+/*
+StackProblemKind is something a program did wrong with the stack.
+*/
+type StackProblemKind uint8
+
+/*
+The problems the stack can have.
+*/
+const (
+	// A push which takes the stack pointer down past $00, so the next push overwrites $01FF at the top of the stack,
+	// or a pull which takes it up past $FF, reading from $0100 at the bottom. Both wrap the stack pointer round.
+	StackOverflow StackProblemKind = iota
+	StackUnderflow
+
+	// A return which doesn't match the frame it pulls: RTS from a BRK or interrupt, RTI from a subroutine, a return
+	// from part way into a frame, or to somewhere other than where the frame was pushed to go back to.
+	StackMismatch
+)
+
+/*
+String names the problem.
+*/
+func (k StackProblemKind) String() string {
+	switch k {
+	case StackOverflow:
+		return "overflow"
+	case StackUnderflow:
+		return "underflow"
+	case StackMismatch:
+		return "mismatched return"
+	}
+	return fmt.Sprintf("StackProblemKind(%d)", uint8(k))
+}
+
+/*
+StackProblem is a misuse of the stack, by the instruction at PC with the stack pointer at SP before it ran. For a
+mismatched return, Frame is the frame returned from and To is where the return went.
+*/
+type StackProblem struct {
+	Kind StackProblemKind
+	PC   Address
+	SP   byte
+
+	Frame StackFrame
+	To    Address
+}
+
+/*
+String describes the problem.
+*/
+func (p StackProblem) String() string {
+	s := fmt.Sprintf("stack %s at $%04X with SP=$%02X", p.Kind, uint16(p.PC), p.SP)
+	if p.Kind == StackMismatch {
+		s += fmt.Sprintf(": returned to $%04X from %s frame for $%04X made at $%04X", uint16(p.To), p.Frame.Kind,
+			uint16(p.Frame.Entry), uint16(p.Frame.Site))
+	}
+	return s
+}
+
+/*
+StackTracker observes a Core, keeping a shadow of the calls and interrupts on its stack as JSR, BRK and interrupts
+push them and RTS and RTI pull them, so the stack in page one can be read back as frames. Problems with the stack are
+recorded as they happen.
+
+Code which unwinds the stack by hand, by pulling or with TXS, ends any frames it pulls off. Returns made by traps are
+followed the same way.
+*/
+type StackTracker struct {
+	NopObserver
+	Core *Core
+
+	// Problems found, in the order they happened.
+	Problems []StackProblem
+
+	// The frames in progress, outermost first, and the return address each expects.
+	frames  []StackFrame
+	returns []Address
+
+	// The stack pointer and program counter the last instruction or interrupt left, which only a trap changes before
+	// the next.
+	sp   byte
+	next Address
+}
+
+/*
+NewStackTracker returns a StackTracker observing c, starting with no frames on the stack. It follows whatever runs c
+until Close is called.
+*/
+func NewStackTracker(c *Core) *StackTracker {
+	st := &StackTracker{Core: c, sp: c.SP, next: c.PC}
+	c.Observe(st)
+	return st
+}
+
+/*
+Close stops observing the Core, keeping the frames and problems found so far.
+*/
+func (st *StackTracker) Close() {
+	st.Core.Unobserve(st)
+}
+
+/*
+Fetched follows any return a trap made since the last instruction.
+*/
+func (st *StackTracker) Fetched(c *Core, pc Address, op Operation) {
+	st.trapped(c.SP, pc)
+}
+
+/*
+Retired follows what an instruction did to the stack.
+*/
+func (st *StackTracker) Retired(c *Core, pc Address, op Operation, cycles int) {
+	sp := st.sp
+	st.sp, st.next = c.SP, c.PC
+
+	switch op.Code {
+	case 0x00: // BRK
+		st.push(FrameBreak, pc, sp, pc+2, 3)
+	case 0x20: // JSR
+		st.push(FrameCall, pc, sp, pc+3, 2)
+	case 0x08, 0x48: // PHP, PHA
+		st.overflow(pc, sp, 1)
+	case 0x40: // RTI
+		st.pull(pc, sp, 3, true, c.PC)
+	case 0x60: // RTS
+		st.pull(pc, sp, 2, false, c.PC)
+	case 0x28, 0x68: // PLP, PLA
+		st.pull(pc, sp, 1, false, c.PC)
+	default:
+		st.unwind(c.SP)
+	}
+}
+
+/*
+Interrupted pushes a frame for the interrupt, taken before the instruction it returns to.
+*/
+func (st *StackTracker) Interrupted(c *Core, vector Address) {
+	site, sp := c.interrupted()
+	st.trapped(sp, site)
+	st.push(FrameInterrupt, site, sp, site, 3)
+	st.sp, st.next = c.SP, c.PC
+}
+
+/*
+trapped follows a trap which moved the stack pointer to sp and the program counter to pc since the last instruction
+or interrupt. One which raised the stack pointer by two is followed as RTS.
+*/
+func (st *StackTracker) trapped(sp byte, pc Address) {
+	switch {
+	case sp == st.sp:
+	case sp == st.sp+2:
+		st.pull(st.next, st.sp, 2, false, pc)
+	default:
+		st.unwind(sp)
+	}
+	st.sp, st.next = sp, pc
+}
+
+/*
+push records a frame pushed by the instruction at site, which took n bytes from a stack pointer of sp.
+*/
+func (st *StackTracker) push(kind FrameKind, site Address, sp byte, ret Address, n int) {
+	st.overflow(site, sp, n)
+	st.frames = append(st.frames, StackFrame{Kind: kind, Entry: st.Core.PC, Site: site, SP: sp})
+	st.returns = append(st.returns, ret)
+}
+
+/*
+overflow records an overflow if pushing n bytes from a stack pointer of sp wrapped the stack pointer round.
+*/
+func (st *StackTracker) overflow(pc Address, sp byte, n int) {
+	if int(sp)-n < 0 {
+		st.Problems = append(st.Problems, StackProblem{Kind: StackOverflow, PC: pc, SP: sp})
+	}
+}
+
+/*
+pull follows n bytes pulled by the instruction at pc from a stack pointer of sp, checking the frame returned from if
+it was a return to the address to.
+*/
+func (st *StackTracker) pull(pc Address, sp byte, n int, rti bool, to Address) {
+	if int(sp)+n > 0xFF {
+		st.Problems = append(st.Problems, StackProblem{Kind: StackUnderflow, PC: pc, SP: sp})
+		st.frames, st.returns = nil, nil
+		return
+	}
+
+	// The outermost frame pulled is the one returned from.
+	var from StackFrame
+	var ret Address
+	pulled := false
+	after := sp + byte(n)
+	for len(st.frames) > 0 && st.frames[len(st.frames)-1].SP <= after {
+		last := len(st.frames) - 1
+		from, ret, pulled = st.frames[last], st.returns[last], true
+		st.frames, st.returns = st.frames[:last], st.returns[:last]
+	}
+	if !pulled || n == 1 {
+		return
+	}
+
+	if from.SP != after || rti != (from.Kind != FrameCall) || to != ret {
+		st.Problems = append(st.Problems, StackProblem{Kind: StackMismatch, PC: pc, SP: sp, Frame: from, To: to})
+	}
+}
+
+/*
+unwind ends the frames whose return state is no longer on the stack, with the stack pointer at sp.
+*/
+func (st *StackTracker) unwind(sp byte) {
+	for len(st.frames) > 0 && st.frames[len(st.frames)-1].SP <= sp {
+		st.frames = st.frames[:len(st.frames)-1]
+		st.returns = st.returns[:len(st.returns)-1]
+	}
+}
+
+/*
+Frames returns the frames on the stack, outermost first, reading their return state back from page one.
+*/
+func (st *StackTracker) Frames() []StackFrame {
+	c := st.Core
+	stack := func(sp byte) byte {
+		return c.Bus.peek(0x0100 | Address(sp))
+	}
+
+	frames := make([]StackFrame, len(st.frames))
+	for i, f := range st.frames {
+		ret := AddressFromBytes(stack(f.SP), stack(f.SP-1))
+		if f.Kind == FrameCall {
+			ret++
+		} else {
+			f.Status = stack(f.SP - 2)
+		}
+		f.Return = ret
+		f.Intact = ret == st.returns[i]
+
+		inner := c.SP
+		if i+1 < len(st.frames) {
+			inner = st.frames[i+1].SP
+		}
+		f.Size = int(f.SP) - int(inner)
+		frames[i] = f
+	}
+	return frames
+}
@@ -0,0 +1,140 @@
+package mos6502
+
+import (
+	"context"
+	"testing"
+)
+
+func TestStackFrames(t *testing.T) {
+	c := profileCore(map[Address][]byte{
+		0x0200: {
+			0x20, 0x00, 0x03, // 0200 JSR $0300
+			0x4C, 0x03, 0x02, // 0203 JMP $0203
+		},
+		0x0300: {
+			0x48,       // 0300 PHA
+			0x00, 0xEA, // 0301 BRK
+			0x68, // 0303 PLA
+			0x60, // 0304 RTS
+		},
+		0x0400: {
+			0x20, 0x00, 0x05, // 0400 JSR $0500
+			0x40, // 0403 RTI
+		},
+		0x0500: {
+			0xEA, // 0500 NOP
+			0x60, // 0501 RTS
+		},
+		IRQVector: {0x00, 0x04},
+	})
+	c.SP = 0xFF
+	c.Carry = true
+	st := NewStackTracker(c)
+	c.SetBreakpoint(0x0500)
+	expectString(t, StopBreakpoint.String(), c.Run(context.Background(), Budget{}).String())
+
+	expected := []StackFrame{
+		{Kind: FrameCall, Entry: 0x0300, Site: 0x0200, SP: 0xFF, Return: 0x0203, Intact: true, Size: 3},
+		{Kind: FrameBreak, Entry: 0x0400, Site: 0x0301, SP: 0xFC, Return: 0x0303, Status: 0x31, Intact: true, Size: 3},
+		{Kind: FrameCall, Entry: 0x0500, Site: 0x0400, SP: 0xF9, Return: 0x0403, Intact: true, Size: 2},
+	}
+	frames := st.Frames()
+	if len(frames) != len(expected) {
+		t.Fatalf("Expected frames %v but got %v.", expected, frames)
+	}
+	for i := range expected {
+		if frames[i] != expected[i] {
+			t.Errorf("Expected frame %v but got %v.", expected[i], frames[i])
+		}
+	}
+
+	// A clobbered return address shows, and is a mismatch when it is returned through.
+	c.Bus.Write(0x01F8, 0x10)
+	expectBool(t, false, st.Frames()[2].Intact)
+	expectAddress(t, 0x0411, st.Frames()[2].Return)
+
+	c.ClearBreakpoint(0x0500)
+	c.Run(context.Background(), Budget{Instructions: 2})
+	if len(st.Problems) != 1 || st.Problems[0].Kind != StackMismatch {
+		t.Fatalf("Expected a mismatch but got %v.", st.Problems)
+	}
+	expectString(t, "stack mismatched return at $0501 with SP=$F7: returned to $0411 from call frame for $0500 made at "+
+		"$0400", st.Problems[0].String())
+	expectUint64(t, 2, uint64(len(st.Frames())))
+}
+
+func TestStackProblems(t *testing.T) {
+	var tests = map[string]struct {
+		code     []byte
+		sp       byte
+		steps    uint64
+		expected []StackProblem
+	}{
+		"overflow": {
+			code:     []byte{0x48, 0x48, 0x4C, 0x02, 0x02}, // PHA, PHA, JMP $0202
+			sp:       0x00,
+			expected: []StackProblem{{Kind: StackOverflow, PC: 0x0200, SP: 0x00}},
+		},
+		"call overflow": {
+			// Writes $0101 and $0100, leaving the stack pointer wrapped round to $FF.
+			code:     []byte{0x20, 0x03, 0x02, 0x4C, 0x03, 0x02}, // JSR $0203, JMP $0203
+			sp:       0x01,
+			expected: []StackProblem{{Kind: StackOverflow, PC: 0x0200, SP: 0x01}},
+		},
+		"call to the bottom": {
+			// Writes $0102 and $0101, leaving the stack pointer at $00.
+			code: []byte{0x20, 0x03, 0x02, 0x4C, 0x03, 0x02}, // JSR $0203, JMP $0203
+			sp:   0x02,
+		},
+		"pull to the top": {
+			// Reads $01FF, leaving the stack pointer at $FF.
+			code: []byte{0x68, 0x4C, 0x01, 0x02}, // PLA, JMP $0201
+			sp:   0xFE,
+		},
+		"underflow": {
+			code:     []byte{0x60}, // RTS
+			sp:       0xFF,
+			steps:    1,
+			expected: []StackProblem{{Kind: StackUnderflow, PC: 0x0200, SP: 0xFF}},
+		},
+		"rti from call": {
+			code:  []byte{0x20, 0x06, 0x02, 0x4C, 0x03, 0x02, 0x40}, // JSR $0206, JMP $0203, RTI
+			sp:    0xF0,
+			steps: 2,
+			expected: []StackProblem{{Kind: StackMismatch, PC: 0x0206, SP: 0xEE, To: 0x0002,
+				Frame: StackFrame{Kind: FrameCall, Entry: 0x0206, Site: 0x0200, SP: 0xF0}}},
+		},
+		"rts trick": {
+			// Pushes an address and returns to it, with no call to match.
+			code: []byte{0xA9, 0x02, 0x48, 0xA9, 0x06, 0x48, 0x60, 0x4C, 0x07, 0x02}, // LDA #2, PHA, LDA #6, PHA, RTS, JMP
+			sp:   0xFF,
+		},
+		"unwound by hand": {
+			// Drops the return address of a call and jumps back.
+			code: []byte{0x20, 0x06, 0x02, 0x4C, 0x03, 0x02, 0xA2, 0xFF, 0x9A, 0x4C, 0x03, 0x02}, // JSR, JMP, LDX, TXS, JMP
+			sp:   0xFF,
+		},
+	}
+
+	for k, tt := range tests {
+		t.Run(k, func(t *testing.T) {
+			c := profileCore(map[Address][]byte{0x0200: tt.code})
+			c.SP = tt.sp
+			st := NewStackTracker(c)
+			steps := tt.steps
+			if steps == 0 {
+				steps = 10
+			}
+			c.Run(context.Background(), Budget{Instructions: steps})
+
+			if len(st.Problems) != len(tt.expected) {
+				t.Fatalf("Expected problems %v but got %v.", tt.expected, st.Problems)
+			}
+			for i := range tt.expected {
+				if st.Problems[i] != tt.expected[i] {
+					t.Errorf("Expected %v but got %v.", tt.expected[i], st.Problems[i])
+				}
+			}
+		})
+	}
+}
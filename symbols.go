@@ -0,0 +1,36 @@
+package mos6502
+
+import "fmt"
+
+/*
+Symbols names addresses, so code can be shown in terms of its source. A *symbols.Table from the symbols package, loaded
+from VICE labels, ca65 debug info or an ld65 map file, is one.
+*/
+type Symbols interface {
+	Name(a uint16) (string, bool)
+}
+
+/*
+Labels is a fixed set of names for addresses.
+*/
+type Labels map[Address]string
+
+/*
+Name returns the label for an address.
+*/
+func (l Labels) Name(a uint16) (string, bool) {
+	name, ok := l[Address(a)]
+	return name, ok
+}
+
+/*
+symbolName returns the name of an address, or the address in hex if it has none.
+*/
+func symbolName(s Symbols, a Address) string {
+	if s != nil {
+		if name, ok := s.Name(uint16(a)); ok {
+			return name
+		}
+	}
+	return fmt.Sprintf("$%04X", uint16(a))
+}
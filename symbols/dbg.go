@@ -0,0 +1,204 @@
+package symbols
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+/*
+record is one line of a ca65 debug info file, such as `sym	id=0,name="start",val=0x200`, split into its kind and
+fields.
+*/
+type record struct {
+	kind   string
+	fields map[string]string
+}
+
+/*
+str returns a field as written, without quotes.
+*/
+func (r record) str(key string) string {
+	return r.fields[key]
+}
+
+/*
+num returns a numeric field, decimal or 0x hex, and if it was there.
+*/
+func (r record) num(key string) (int, bool) {
+	v, ok := r.fields[key]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(v, 0, 64)
+	return int(n), err == nil
+}
+
+/*
+ids returns a field holding a list of IDs joined with "+".
+*/
+func (r record) ids(key string) []int {
+	var ids []int
+	for _, s := range strings.Split(r.fields[key], "+") {
+		if n, err := strconv.Atoi(s); err == nil {
+			ids = append(ids, n)
+		}
+	}
+	return ids
+}
+
+/*
+ReadDebugInfo reads the debug info ld65 writes with --dbgfile, taking the labels with their scopes and segments, and
+the source lines of the code. Equates are left out, as most are constants rather than addresses. Cheap local labels
+keep their "@" name, in the scope of the label they follow.
+*/
+func ReadDebugInfo(r io.Reader) (*Table, error) {
+	records := map[string]map[int]record{}
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		text := strings.TrimSpace(s.Text())
+		if text == "" {
+			continue
+		}
+		rec, err := parseRecord(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		id, ok := rec.num("id")
+		if !ok {
+			continue
+		}
+		if records[rec.kind] == nil {
+			records[rec.kind] = map[int]record{}
+		}
+		records[rec.kind][id] = rec
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	t := &Table{}
+	segs := records["seg"]
+	for id := 0; id < len(segs); id++ {
+		seg, ok := segs[id]
+		if !ok {
+			continue
+		}
+		start, _ := seg.num("start")
+		size, _ := seg.num("size")
+		t.AddSegment(Segment{Name: seg.str("name"), Start: uint16(start), Size: size})
+	}
+
+	scopes := records["scope"]
+	var scopeName func(id int, depth int) string
+	scopeName = func(id int, depth int) string {
+		sc, ok := scopes[id]
+		if !ok || depth > len(scopes) {
+			return ""
+		}
+		parent, ok := sc.num("parent")
+		if !ok {
+			return sc.str("name")
+		}
+		if outer := scopeName(parent, depth+1); outer != "" {
+			return outer + "::" + sc.str("name")
+		}
+		return sc.str("name")
+	}
+
+	syms := records["sym"]
+	for id := 0; id < len(syms); id++ {
+		sym, ok := syms[id]
+		if !ok || sym.str("type") != "lab" {
+			continue
+		}
+		val, _ := sym.num("val")
+		size, _ := sym.num("size")
+
+		// Cheap locals belong to the scope of the label they follow.
+		scoped := sym
+		if parent, ok := sym.num("parent"); ok {
+			scoped = syms[parent]
+		}
+		scope, _ := scoped.num("scope")
+
+		var segment string
+		if seg, ok := sym.num("seg"); ok {
+			segment = segs[seg].str("name")
+		}
+		t.Add(Symbol{Name: sym.str("name"), Address: uint16(val), Size: size, Scope: scopeName(scope, 0), Segment: segment})
+	}
+
+	files, spans, lines := records["file"], records["span"], records["line"]
+	for id := 0; id < len(lines); id++ {
+		l, ok := lines[id]
+		if kind, _ := l.num("type"); !ok || kind != 0 {
+			continue
+		}
+		file, _ := l.num("file")
+		number, _ := l.num("line")
+
+		// Spans with a type hold data, which isn't a line of code.
+		for _, sp := range l.ids("span") {
+			span, ok := spans[sp]
+			if _, data := span.fields["type"]; !ok || data {
+				continue
+			}
+			seg, _ := span.num("seg")
+			base, _ := segs[seg].num("start")
+			start, _ := span.num("start")
+
+			a := uint16(base + start)
+			if _, ok := t.lines[a]; !ok {
+				t.AddLine(a, Line{File: files[file].str("name"), Line: number})
+			}
+		}
+	}
+	return t, nil
+}
+
+/*
+parseRecord splits a line of debug info into its kind and fields. Quoted values may hold commas, and backslash escapes
+the character after it.
+*/
+func parseRecord(text string) (record, error) {
+	kind, rest, _ := strings.Cut(text, "\t")
+	if i := strings.IndexAny(kind, " "); i >= 0 {
+		kind, rest = text[:i], text[i+1:]
+	}
+	rec := record{kind: kind, fields: map[string]string{}}
+
+	rest = strings.TrimSpace(rest)
+	for rest != "" {
+		key, after, ok := strings.Cut(rest, "=")
+		if !ok {
+			return record{}, fmt.Errorf("expected key=value in %q", rest)
+		}
+
+		var value strings.Builder
+		i := 0
+		if strings.HasPrefix(after, `"`) {
+			i = 1
+			for ; i < len(after) && after[i] != '"'; i++ {
+				if after[i] == '\\' && i+1 < len(after) {
+					i++
+				}
+				value.WriteByte(after[i])
+			}
+			if i == len(after) {
+				return record{}, fmt.Errorf("unterminated string in %q", rest)
+			}
+			i++
+		} else {
+			for ; i < len(after) && after[i] != ','; i++ {
+				value.WriteByte(after[i])
+			}
+		}
+
+		rec.fields[key] = value.String()
+		rest = strings.TrimPrefix(after[i:], ",")
+	}
+	return rec, nil
+}
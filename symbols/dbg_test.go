@@ -0,0 +1,115 @@
+package symbols
+
+import (
+	"strings"
+	"testing"
+)
+
+/*
+dbgExample is debug info for a program with a scoped subroutine, as written by ld65.
+*/
+const dbgExample = `version	major=2,minor=0
+info	csym=0,file=2,lib=0,line=6,mod=1,scope=2,seg=2,span=5,sym=5,type=1
+file	id=0,name="main.s",size=400,mtime=0x5C5A2B6D,mod=0
+file	id=1,name="macros, and more.inc",size=100,mtime=0x5C5A2B6D,mod=0
+line	id=0,file=0,line=3,span=0
+line	id=1,file=0,line=4,span=1
+line	id=2,file=0,line=9,span=2
+line	id=3,file=0,line=12,span=3
+line	id=4,file=1,line=2,type=2,span=2
+line	id=5,file=0,line=20,span=4
+mod	id=0,name="main.o",file=0
+seg	id=0,name="CODE",start=0x000200,size=0x0010,addrsize=absolute,type=ro,oname="game.bin",ooffs=0
+seg	id=1,name="ZEROPAGE",start=0x000080,size=0x0002,addrsize=zeropage,type=rw
+span	id=0,seg=0,start=0,size=2
+span	id=1,seg=0,start=2,size=3
+span	id=2,seg=0,start=5,size=1
+span	id=3,seg=0,start=6,size=1
+span	id=4,seg=0,start=7,size=3,type=0
+scope	id=0,name="",mod=0,size=16,span=0+1
+scope	id=1,name="delay",mod=0,type=scope,size=2,parent=0,sym=1,span=2+3
+sym	id=0,name="start",addrsize=absolute,scope=0,def=0,ref=2,val=0x200,seg=0,type=lab
+sym	id=1,name="delay",addrsize=absolute,size=2,scope=0,def=2,val=0x205,seg=0,type=lab
+sym	id=2,name="loop",addrsize=absolute,scope=1,def=2,val=0x205,seg=0,type=lab
+sym	id=3,name="@wait",addrsize=absolute,parent=2,def=3,val=0x206,seg=0,type=lab
+sym	id=4,name="COUNT",addrsize=zeropage,scope=0,def=1,val=0x3,type=equ
+sym	id=5,name="ptr",addrsize=zeropage,scope=0,def=4,val=0x80,seg=1,type=lab
+type	id=0,val="800920"
+`
+
+func TestReadDebugInfo(t *testing.T) {
+	tab, err := ReadDebugInfo(strings.NewReader(dbgExample))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tab.Len() != 5 {
+		t.Errorf("Expected 5 labels but got %d.", tab.Len())
+	}
+
+	var tests = map[string]struct {
+		address uint16
+		name    string
+		symbol  Symbol
+	}{
+		"global":      {address: 0x0200, symbol: Symbol{Name: "start", Address: 0x0200, Segment: "CODE"}},
+		"scoped":      {address: 0x0205, symbol: Symbol{Name: "delay", Address: 0x0205, Size: 2, Segment: "CODE"}},
+		"cheap local": {address: 0x0206, symbol: Symbol{Name: "@wait", Address: 0x0206, Scope: "delay", Segment: "CODE"}},
+		"zeropage":    {address: 0x0080, symbol: Symbol{Name: "ptr", Address: 0x0080, Segment: "ZEROPAGE"}},
+	}
+
+	for k, tt := range tests {
+		t.Run(k, func(t *testing.T) {
+			s, ok := tab.Lookup(tt.address)
+			if !ok || s != tt.symbol {
+				t.Errorf("Expected %+v but got %+v.", tt.symbol, s)
+			}
+		})
+	}
+
+	if a, ok := tab.Address("delay::loop"); !ok || a != 0x0205 {
+		t.Errorf("Expected delay::loop at 0205 but got %04X, %v.", a, ok)
+	}
+	if _, ok := tab.Address("COUNT"); ok {
+		t.Error("Expected equates to be left out.")
+	}
+
+	// Macro lines and data spans are left out.
+	lines := tab.Lines()
+	expected := map[uint16]Line{
+		0x0200: {File: "main.s", Line: 3},
+		0x0202: {File: "main.s", Line: 4},
+		0x0205: {File: "main.s", Line: 9},
+		0x0206: {File: "main.s", Line: 12},
+	}
+	if len(lines) != len(expected) {
+		t.Errorf("Expected %d lines but got %v.", len(expected), lines)
+	}
+	for a, l := range expected {
+		if lines[a] != l {
+			t.Errorf("Expected %v at %04X but got %v.", l, a, lines[a])
+		}
+	}
+
+	segs := tab.Segments()
+	if len(segs) != 2 || segs[0] != (Segment{Name: "ZEROPAGE", Start: 0x80, Size: 2}) {
+		t.Errorf("Expected the segments in order but got %v.", segs)
+	}
+}
+
+func TestParseRecord(t *testing.T) {
+	rec, err := parseRecord(`file	id=1,name="a, \"b\".s",size=100`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.kind != "file" || rec.str("name") != `a, "b".s` || rec.str("size") != "100" {
+		t.Errorf("Unexpected record %+v.", rec)
+	}
+
+	if _, err := parseRecord(`file	id=1,name="open`); err == nil {
+		t.Error("Expected an error for an unterminated string.")
+	}
+	if _, err := parseRecord(`file	id`); err == nil {
+		t.Error("Expected an error for a field without a value.")
+	}
+}
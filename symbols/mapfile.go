@@ -0,0 +1,65 @@
+package symbols
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+/*
+ReadMap reads a map file written by ld65 with -m, taking the segments from its segment list and the labels from its
+exports list. Equates are left out, as most are constants rather than addresses.
+*/
+func ReadMap(r io.Reader) (*Table, error) {
+	t := &Table{}
+	section := ""
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		text := s.Text()
+		fields := strings.Fields(text)
+		switch {
+		case len(fields) == 0, strings.HasPrefix(text, "---"):
+			continue
+		case strings.HasSuffix(text, ":") && !strings.HasPrefix(text, " "):
+			section = strings.TrimSuffix(text, ":")
+			continue
+		}
+
+		switch section {
+		case "Segment list":
+			if fields[0] == "Name" {
+				continue
+			}
+			if len(fields) < 4 {
+				return nil, fmt.Errorf("line %d: expected a segment's name, start, end and size", line)
+			}
+			start, err := parseHex(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			size, err := parseHex(fields[3])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			t.AddSegment(Segment{Name: fields[0], Start: start, Size: int(size)})
+
+		case "Exports list by name":
+			// Exports come two to a line, each a name, value and flags such as "RLA" for a referenced label.
+			if len(fields)%3 != 0 {
+				return nil, fmt.Errorf("line %d: expected names, values and flags", line)
+			}
+			for i := 0; i < len(fields); i += 3 {
+				a, err := parseHex(fields[i+1])
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %v", line, err)
+				}
+				if strings.Contains(fields[i+2], "L") {
+					seg, _ := t.SegmentAt(a)
+					t.Add(Symbol{Name: fields[i], Address: a, Segment: seg.Name})
+				}
+			}
+		}
+	}
+	return t, s.Err()
+}
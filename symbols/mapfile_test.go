@@ -0,0 +1,87 @@
+package symbols
+
+import (
+	"strings"
+	"testing"
+)
+
+/*
+mapExample is a map file as written by ld65.
+*/
+const mapExample = `Modules list:
+-------------
+main.o:
+    CODE              Offs=000000  Size=000010  Align=00001  Fill=0000
+    ZEROPAGE          Offs=000000  Size=000002  Align=00001  Fill=0000
+
+
+Segment list:
+-------------
+Name                   Start     End    Size  Align
+----------------------------------------------------
+ZEROPAGE              000080  000081  000002  00001
+CODE                  000200  00020F  000010  00001
+
+
+Exports list by name:
+---------------------
+COUNT                     000003 REA    delay                     000205 RLA    
+ptr                       000080 RLZ    start                     000200  LA    
+
+
+Exports list by value:
+----------------------
+COUNT                     000003 REA    ptr                       000080 RLZ    
+start                     000200  LA    delay                     000205 RLA    
+
+
+Imports list:
+-------------
+delay (main.o):
+    main.o                    main.s(10)
+`
+
+func TestReadMap(t *testing.T) {
+	tab, err := ReadMap(strings.NewReader(mapExample))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tab.Len() != 3 {
+		t.Errorf("Expected 3 labels but got %d.", tab.Len())
+	}
+	for a, expected := range map[uint16]Symbol{
+		0x0080: {Name: "ptr", Address: 0x0080, Segment: "ZEROPAGE"},
+		0x0200: {Name: "start", Address: 0x0200, Segment: "CODE"},
+		0x0205: {Name: "delay", Address: 0x0205, Segment: "CODE"},
+	} {
+		if s, _ := tab.Lookup(a); s != expected {
+			t.Errorf("Expected %+v but got %+v.", expected, s)
+		}
+	}
+	if _, ok := tab.Address("COUNT"); ok {
+		t.Error("Expected equates to be left out.")
+	}
+
+	segs := tab.Segments()
+	if len(segs) != 2 || segs[1] != (Segment{Name: "CODE", Start: 0x0200, Size: 0x10}) {
+		t.Errorf("Expected two segments but got %v.", segs)
+	}
+}
+
+func TestReadMapErrors(t *testing.T) {
+	var tests = map[string]string{
+		"short segment": "Segment list:\nCODE 000200\n",
+		"bad start":     "Segment list:\nCODE 0002G0 00020F 000010 00001\n",
+		"odd exports":   "Exports list by name:\nstart 000200\n",
+		"bad value":     "Exports list by name:\nstart 0002G0 RLA\n",
+	}
+
+	for k, tt := range tests {
+		t.Run(k, func(t *testing.T) {
+			if _, err := ReadMap(strings.NewReader(tt)); err == nil {
+				t.Error("Expected an error.")
+			}
+		})
+	}
+}
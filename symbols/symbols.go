@@ -0,0 +1,249 @@
+/*
+Package symbols loads the names assemblers and emulators give to addresses, from VICE label files, ca65 debug info and
+ld65 map files, into a table that can be searched by address or by name.
+*/
+package symbols
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+/*
+Symbol is a name for an address.
+*/
+type Symbol struct {
+	Name    string
+	Address uint16
+
+	// Bytes covered by the symbol, 0 if not known.
+	Size int
+
+	// Scope the symbol was defined in, nested scopes joined with "::", and the segment it lies in. Either may be
+	// empty.
+	Scope   string
+	Segment string
+}
+
+/*
+FullName returns the name of the symbol qualified by its scope.
+*/
+func (s Symbol) FullName() string {
+	if s.Scope == "" {
+		return s.Name
+	}
+	return s.Scope + "::" + s.Name
+}
+
+/*
+Segment is a range of memory the linker placed code or data in.
+*/
+type Segment struct {
+	Name  string
+	Start uint16
+	Size  int
+}
+
+/*
+Contains returns true if the address lies in the segment.
+*/
+func (s Segment) Contains(a uint16) bool {
+	return int(a) >= int(s.Start) && int(a) < int(s.Start)+s.Size
+}
+
+/*
+Line is a line of a source file.
+*/
+type Line struct {
+	File string
+	Line int
+}
+
+/*
+Table holds symbols and segments, and the source lines code was assembled from where they are known. The zero value
+is empty and ready to use, and a nil Table has no symbols.
+*/
+type Table struct {
+	symbols  []Symbol
+	segments []Segment
+	lines    map[uint16]Line
+
+	// Indexes into symbols.
+	byAddress map[uint16][]int
+	byName    map[string]int
+}
+
+/*
+Add puts a symbol in the table.
+*/
+func (t *Table) Add(s Symbol) {
+	if t.byAddress == nil {
+		t.byAddress = map[uint16][]int{}
+		t.byName = map[string]int{}
+	}
+
+	i := len(t.symbols)
+	t.symbols = append(t.symbols, s)
+	t.byAddress[s.Address] = append(t.byAddress[s.Address], i)
+	for _, name := range []string{s.Name, s.FullName()} {
+		if _, ok := t.byName[name]; !ok {
+			t.byName[name] = i
+		}
+	}
+}
+
+/*
+AddSegment puts a segment in the table.
+*/
+func (t *Table) AddSegment(s Segment) {
+	t.segments = append(t.segments, s)
+}
+
+/*
+AddLine records the source line of the code at an address.
+*/
+func (t *Table) AddLine(a uint16, l Line) {
+	if t.lines == nil {
+		t.lines = map[uint16]Line{}
+	}
+	t.lines[a] = l
+}
+
+/*
+Merge adds everything in another table to this one.
+*/
+func (t *Table) Merge(o *Table) {
+	if o == nil {
+		return
+	}
+	for _, s := range o.symbols {
+		t.Add(s)
+	}
+	t.segments = append(t.segments, o.segments...)
+	for a, l := range o.lines {
+		t.AddLine(a, l)
+	}
+}
+
+/*
+Len returns the number of symbols in the table.
+*/
+func (t *Table) Len() int {
+	if t == nil {
+		return 0
+	}
+	return len(t.symbols)
+}
+
+/*
+Symbols returns every symbol in the table, in order of address.
+*/
+func (t *Table) Symbols() []Symbol {
+	if t == nil {
+		return nil
+	}
+	list := append([]Symbol(nil), t.symbols...)
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Address < list[j].Address })
+	return list
+}
+
+/*
+Lookup returns the symbol for an address. Where there are several, global symbols are preferred to scoped ones, and
+then the first added.
+*/
+func (t *Table) Lookup(a uint16) (Symbol, bool) {
+	if t == nil || len(t.byAddress[a]) == 0 {
+		return Symbol{}, false
+	}
+	for _, i := range t.byAddress[a] {
+		if t.symbols[i].Scope == "" {
+			return t.symbols[i], true
+		}
+	}
+	return t.symbols[t.byAddress[a][0]], true
+}
+
+/*
+Name returns the full name of the symbol for an address.
+*/
+func (t *Table) Name(a uint16) (string, bool) {
+	s, ok := t.Lookup(a)
+	return s.FullName(), ok
+}
+
+/*
+Address returns the address of a symbol, found by its name or full name.
+*/
+func (t *Table) Address(name string) (uint16, bool) {
+	if t == nil {
+		return 0, false
+	}
+	i, ok := t.byName[name]
+	if !ok {
+		return 0, false
+	}
+	return t.symbols[i].Address, true
+}
+
+/*
+Segments returns the segments in the table, in order of address.
+*/
+func (t *Table) Segments() []Segment {
+	if t == nil {
+		return nil
+	}
+	list := append([]Segment(nil), t.segments...)
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Start < list[j].Start })
+	return list
+}
+
+/*
+SegmentAt returns the segment an address lies in.
+*/
+func (t *Table) SegmentAt(a uint16) (Segment, bool) {
+	if t == nil {
+		return Segment{}, false
+	}
+	for _, s := range t.segments {
+		if s.Size > 0 && s.Contains(a) {
+			return s, true
+		}
+	}
+	return Segment{}, false
+}
+
+/*
+Lines returns the source line of each instruction, by address, where they are known.
+*/
+func (t *Table) Lines() map[uint16]Line {
+	lines := map[uint16]Line{}
+	if t != nil {
+		for a, l := range t.lines {
+			lines[a] = l
+		}
+	}
+	return lines
+}
+
+/*
+Load reads a symbol file, telling the format from its extension: ".dbg" for ca65 debug info, ".map" for an ld65 map
+file, and anything else for VICE labels.
+*/
+func Load(path string) (*Table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".dbg":
+		return ReadDebugInfo(f)
+	case ".map":
+		return ReadMap(f)
+	default:
+		return ReadVICE(f)
+	}
+}
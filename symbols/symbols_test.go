@@ -0,0 +1,121 @@
+package symbols
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+/*
+A Table has to fit the Symbols interface the emulator takes names through.
+*/
+var _ interface {
+	Name(a uint16) (string, bool)
+} = (*Table)(nil)
+
+func TestTable(t *testing.T) {
+	var tab Table
+	tab.Add(Symbol{Name: "loop", Address: 0x0210, Scope: "main"})
+	tab.Add(Symbol{Name: "start", Address: 0x0200, Size: 16})
+	tab.Add(Symbol{Name: "again", Address: 0x0210})
+	tab.AddSegment(Segment{Name: "CODE", Start: 0x0200, Size: 0x20})
+
+	var tests = map[string]struct {
+		address uint16
+		name    string
+		found   bool
+	}{
+		"global":         {address: 0x0200, name: "start", found: true},
+		"global first":   {address: 0x0210, name: "again", found: true},
+		"no symbol":      {address: 0x0201},
+		"past the table": {address: 0xFFFF},
+	}
+
+	for k, tt := range tests {
+		t.Run(k, func(t *testing.T) {
+			name, ok := tab.Name(tt.address)
+			if ok != tt.found || name != tt.name {
+				t.Errorf("Expected %q, %v but got %q, %v.", tt.name, tt.found, name, ok)
+			}
+		})
+	}
+
+	if a, ok := tab.Address("main::loop"); !ok || a != 0x0210 {
+		t.Errorf("Expected main::loop at 0210 but got %04X, %v.", a, ok)
+	}
+	if a, ok := tab.Address("loop"); !ok || a != 0x0210 {
+		t.Errorf("Expected loop at 0210 but got %04X, %v.", a, ok)
+	}
+	if _, ok := tab.Address("missing"); ok {
+		t.Error("Expected no address for a missing name.")
+	}
+
+	syms := tab.Symbols()
+	if len(syms) != 3 || syms[0].Name != "start" {
+		t.Errorf("Expected the symbols in order of address but got %v.", syms)
+	}
+	if seg, ok := tab.SegmentAt(0x021F); !ok || seg.Name != "CODE" {
+		t.Errorf("Expected 021F in CODE but got %v, %v.", seg, ok)
+	}
+	if _, ok := tab.SegmentAt(0x0220); ok {
+		t.Error("Expected 0220 to be past the end of CODE.")
+	}
+}
+
+func TestNilTable(t *testing.T) {
+	var tab *Table
+	if _, ok := tab.Name(0x0200); ok {
+		t.Error("Expected no names in a nil table.")
+	}
+	if tab.Len() != 0 || len(tab.Symbols()) != 0 || len(tab.Lines()) != 0 {
+		t.Error("Expected a nil table to be empty.")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	var a, b Table
+	a.Add(Symbol{Name: "start", Address: 0x0200})
+	b.Add(Symbol{Name: "irq", Address: 0x0300})
+	b.AddLine(0x0300, Line{File: "irq.s", Line: 4})
+	a.Merge(&b)
+	a.Merge(nil)
+
+	if name, _ := a.Name(0x0300); name != "irq" {
+		t.Errorf("Expected irq but got %q.", name)
+	}
+	if a.Lines()[0x0300].File != "irq.s" {
+		t.Error("Expected lines to be merged.")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	var tests = map[string]struct {
+		file     string
+		contents string
+	}{
+		"vice": {file: "game.lbl", contents: "al C:0200 .start\n"},
+		"dbg":  {file: "game.dbg", contents: dbgExample},
+		"map":  {file: "game.map", contents: mapExample},
+	}
+
+	for k, tt := range tests {
+		t.Run(k, func(t *testing.T) {
+			path := filepath.Join(dir, tt.file)
+			if err := os.WriteFile(path, []byte(tt.contents), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			tab, err := Load(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if name, _ := tab.Name(0x0200); name != "start" {
+				t.Errorf("Expected start but got %q.", name)
+			}
+		})
+	}
+
+	if _, err := Load(filepath.Join(dir, "missing.lbl")); err == nil {
+		t.Error("Expected an error for a missing file.")
+	}
+}
@@ -0,0 +1,46 @@
+package symbols
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+/*
+ReadVICE reads a label file in the format VICE loads and ld65 writes with -Ln, one "al C:0801 .start" line per label.
+The "C:" and leading dot are optional, and other lines are skipped.
+*/
+func ReadVICE(r io.Reader) (*Table, error) {
+	t := &Table{}
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 || fields[0] != "al" {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("line %d: expected an address and a name", line)
+		}
+
+		a, err := parseHex(strings.TrimPrefix(fields[1], "C:"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		t.Add(Symbol{Name: strings.TrimPrefix(fields[2], "."), Address: a})
+	}
+	return t, s.Err()
+}
+
+/*
+parseHex parses an address written in hex, which may have leading zeros beyond four digits but must fit in 16 bits.
+*/
+func parseHex(s string) (uint16, error) {
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "$"), "0x")
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil || v > 0xFFFF {
+		return 0, fmt.Errorf("bad address %q", s)
+	}
+	return uint16(v), nil
+}
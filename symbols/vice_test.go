@@ -0,0 +1,47 @@
+package symbols
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadVICE(t *testing.T) {
+	tab, err := ReadVICE(strings.NewReader(`al C:0801 .start
+al C:0810 .loop
+al 000801 .again
+break 0810
+
+al 2000 buffer
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tab.Len() != 4 {
+		t.Errorf("Expected 4 symbols but got %d.", tab.Len())
+	}
+	for a, expected := range map[uint16]string{0x0801: "start", 0x0810: "loop", 0x2000: "buffer"} {
+		if name, _ := tab.Name(a); name != expected {
+			t.Errorf("Expected %q at %04X but got %q.", expected, a, name)
+		}
+	}
+	if a, _ := tab.Address("again"); a != 0x0801 {
+		t.Errorf("Expected again at 0801 but got %04X.", a)
+	}
+}
+
+func TestReadVICEErrors(t *testing.T) {
+	var tests = map[string]string{
+		"no name":     "al C:0801",
+		"bad address": "al C:08G1 .start",
+		"too big":     "al C:10000 .start",
+	}
+
+	for k, tt := range tests {
+		t.Run(k, func(t *testing.T) {
+			if _, err := ReadVICE(strings.NewReader(tt)); err == nil {
+				t.Error("Expected an error.")
+			}
+		})
+	}
+}
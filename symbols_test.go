@@ -0,0 +1,22 @@
+package mos6502
+
+import "testing"
+
+func TestSymbolName(t *testing.T) {
+	var tests = map[string]struct {
+		symbols  Symbols
+		address  Address
+		expected string
+	}{
+		"label":    {symbols: Labels{0x0801: "start"}, address: 0x0801, expected: "start"},
+		"no label": {symbols: Labels{0x0801: "start"}, address: 0x0802, expected: "$0802"},
+		"none":     {address: 0xC000, expected: "$C000"},
+		"nil map":  {symbols: Labels(nil), address: 0x0000, expected: "$0000"},
+	}
+
+	for k, tt := range tests {
+		t.Run(k, func(t *testing.T) {
+			expectString(t, tt.expected, symbolName(tt.symbols, tt.address))
+		})
+	}
+}
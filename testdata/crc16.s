@@ -0,0 +1,46 @@
+; CRC-16/CCITT-FALSE (polynomial $1021, starting from $FFFF) of the 4KB at
+; $3000. Assembled at $0200. Ends in a JMP to itself with the CRC in $12
+; (low) and $13 (high).
+
+DATA    = $3000
+PAGES   = $10
+
+ptr     = $10
+crc     = $12
+bits    = $14
+
+        .org $0200
+
+start:  LDA #$FF
+        STA crc
+        STA crc+1
+        LDA #<DATA
+        STA ptr
+        LDA #>DATA
+        STA ptr+1
+        LDX #PAGES
+        LDY #$00
+
+byte:   LDA (ptr),Y
+        EOR crc+1
+        STA crc+1
+        LDA #$08
+        STA bits
+bit:    ASL crc
+        ROL crc+1
+        BCC noxor
+        LDA crc+1
+        EOR #$10
+        STA crc+1
+        LDA crc
+        EOR #$21
+        STA crc
+noxor:  DEC bits
+        BNE bit
+        INY
+        BNE byte
+        INC ptr+1
+        DEX
+        BNE byte
+
+done:   JMP done
@@ -0,0 +1,67 @@
+; Sieve of Eratosthenes over the numbers below 8192, counting the primes.
+; Assembled at $0200. Ends in a JMP to itself with the count in $00 (low)
+; and $01 (high).
+
+SIZE    = $2000
+FLAGS   = $2000
+
+count   = $00
+num     = $02
+ptr     = $04
+
+        .org $0200
+
+start:  LDA #$00
+        STA ptr
+        LDA #>FLAGS
+        STA ptr+1
+        LDY #$00
+        LDX #>SIZE
+        LDA #$01
+fill:   STA (ptr),Y
+        INY
+        BNE fill
+        INC ptr+1
+        DEX
+        BNE fill
+
+        LDA #$00
+        STA count
+        STA count+1
+        STA num+1
+        LDA #$02
+        STA num
+
+loop:   LDA num
+        STA ptr
+        LDA num+1
+        CLC
+        ADC #>FLAGS
+        STA ptr+1
+        LDA (ptr),Y
+        BEQ next
+        INC count
+        BNE mark
+        INC count+1
+
+mark:   CLC
+        LDA ptr
+        ADC num
+        STA ptr
+        LDA ptr+1
+        ADC num+1
+        STA ptr+1
+        CMP #>(FLAGS+SIZE)
+        BCS next
+        LDA #$00
+        STA (ptr),Y
+        JMP mark
+
+next:   INC num
+        BNE check
+        INC num+1
+check:  LDA num+1
+        CMP #>SIZE
+        BNE loop
+
+done:   JMP done
@@ -130,3 +130,13 @@ func expectCore(t *testing.T, expected *Core, actual *Core) {
 		t.Fail()
 	}
 }
+
+/*
+Given an expectation of a uint64 and the actual uint64, report back a failure if they are different, logging both.
+*/
+func expectUint64(t *testing.T, expected uint64, actual uint64) {
+	if expected != actual {
+		t.Logf("Expected \"%d\" but got \"%d\".", expected, actual)
+		t.Fail()
+	}
+}
@@ -0,0 +1,190 @@
+package mos6502
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+/*
+Clock rates of common machines, in Hz.
+*/
+const (
+	Clock1MHz = 1000000
+	ClockNTSC = 1789773
+	Clock2MHz = 2000000
+)
+
+/*
+DefaultSlice is the timeslice a Throttle uses unless told otherwise.
+*/
+const DefaultSlice = 10 * time.Millisecond
+
+/*
+maxThrottleLag is how far a Throttle may fall behind before it stops trying to catch up.
+*/
+const maxThrottleLag = 100 * time.Millisecond
+
+/*
+Throttle paces a Core to a target clock rate in real time. It runs the Core in timeslices, each worth Slice of wall
+clock time at the target rate, and sleeps off whatever is left of the slice. In turbo mode it runs flat out.
+*/
+type Throttle struct {
+	Core *Core
+
+	// Target clock rate in Hz, which must be positive and finite.
+	Hz float64
+
+	// Wall clock time covered by each run of the Core.
+	Slice time.Duration
+
+	mu    sync.Mutex
+	turbo bool
+	stats ThrottleStats
+
+	// Sources of time, swapped out in tests.
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration)
+}
+
+/*
+ThrottleStats describes how closely a Throttle has kept to its target.
+*/
+type ThrottleStats struct {
+	// Cycles run and wall clock time taken, including sleeps.
+	Cycles  uint64
+	Elapsed time.Duration
+
+	// Time spent sleeping between slices.
+	Slept time.Duration
+
+	// Slices which ran over their time, so the Throttle fell behind.
+	Late uint64
+}
+
+/*
+Hz returns the achieved clock rate.
+*/
+func (s ThrottleStats) Hz() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Cycles) / s.Elapsed.Seconds()
+}
+
+/*
+NewThrottle returns a Throttle which runs c at hz. It panics if hz isn't a positive, finite rate.
+*/
+func NewThrottle(c *Core, hz float64) *Throttle {
+	checkHz(hz)
+	return &Throttle{Core: c, Hz: hz, Slice: DefaultSlice, now: time.Now, sleep: sleepContext}
+}
+
+/*
+checkHz panics if hz can't be paced to, as a zero rate would make every slice take forever.
+*/
+func checkHz(hz float64) {
+	if !(hz > 0) || math.IsInf(hz, 1) {
+		panic("mos6502: throttle rate must be positive and finite")
+	}
+}
+
+/*
+SetTurbo turns turbo mode on or off. It may be called while the Throttle is running.
+*/
+func (t *Throttle) SetTurbo(on bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.turbo = on
+}
+
+/*
+Turbo returns true if the Throttle is running unthrottled.
+*/
+func (t *Throttle) Turbo() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.turbo
+}
+
+/*
+Stats returns the speed achieved so far. It may be called while the Throttle is running.
+*/
+func (t *Throttle) Stats() ThrottleStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.stats
+}
+
+/*
+Run paces the Core until it stops for any reason other than the end of a slice, and returns that reason. Time lost
+to a slow host is made up by skipping sleeps, but once the Throttle is more than a tenth of a second behind it gives
+up catching up and carries on from the present. Programs idling in a loop keep being paced, as Run never stops
+on loops. Like NewThrottle, Run panics if Hz isn't a positive, finite rate.
+*/
+func (t *Throttle) Run(ctx context.Context) StopReason {
+	checkHz(t.Hz)
+	slice := t.Slice
+	if slice <= 0 {
+		slice = DefaultSlice
+	}
+	cycles := uint64(t.Hz * slice.Seconds())
+	if cycles == 0 {
+		cycles = 1
+	}
+
+	start := t.now()
+	deadline := start
+	elapsed := t.Stats().Elapsed
+	for {
+		before := t.Core.Cycles()
+		reason := t.Core.Run(ctx, Budget{Cycles: cycles})
+		ran := t.Core.Cycles() - before
+
+		turbo := t.Turbo()
+		deadline = deadline.Add(time.Duration(float64(ran) / t.Hz * float64(time.Second)))
+		now := t.now()
+		var slept time.Duration
+		late := false
+		// Only a full slice is paced, a run cut short returns straight away.
+		switch {
+		case reason != StopCycles:
+		case turbo:
+			deadline = now
+		case now.Before(deadline):
+			slept = deadline.Sub(now)
+			t.sleep(ctx, slept)
+		case now.Sub(deadline) > maxThrottleLag:
+			late = true
+			deadline = now
+		case now.After(deadline):
+			late = true
+		}
+
+		t.mu.Lock()
+		t.stats.Cycles += ran
+		t.stats.Slept += slept
+		t.stats.Elapsed = elapsed + t.now().Sub(start)
+		if late {
+			t.stats.Late++
+		}
+		t.mu.Unlock()
+
+		if reason != StopCycles {
+			return reason
+		}
+	}
+}
+
+/*
+sleepContext sleeps for d, or until ctx is cancelled.
+*/
+func sleepContext(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,131 @@
+package mos6502
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+/*
+fakeClock stands in for wall clock time, only moving when slept through or ticked.
+*/
+type fakeClock struct {
+	now  time.Time
+	tick time.Duration
+}
+
+func (f *fakeClock) Now() time.Time {
+	return f.now
+}
+
+func (f *fakeClock) Sleep(ctx context.Context, d time.Duration) {
+	f.now = f.now.Add(d)
+}
+
+func (f *fakeClock) Tick() {
+	f.now = f.now.Add(f.tick)
+}
+
+func TestThrottle(t *testing.T) {
+	var tests = map[string]struct {
+		turbo   bool
+		tick    time.Duration
+		slept   time.Duration
+		elapsed time.Duration
+		late    uint64
+		hz      float64
+	}{
+		"paced":      {slept: 80 * time.Millisecond, elapsed: 80 * time.Millisecond, hz: 1000},
+		"turbo":      {turbo: true, hz: 0},
+		"slow host":  {tick: 2 * time.Millisecond, elapsed: 160 * time.Millisecond, late: 8, hz: 500},
+		"fast turbo": {turbo: true, tick: 500 * time.Microsecond, elapsed: 40 * time.Millisecond, hz: 2000},
+	}
+
+	for k, tt := range tests {
+		t.Run(k, func(t *testing.T) {
+			// 40 NOPs take 80 cycles, or 8 slices of 10ms at 1kHz.
+			c := nopCore()
+			c.SetStopAddress(0x0228)
+			clock := &fakeClock{now: time.Unix(0, 0), tick: tt.tick}
+			c.Attach(clock)
+
+			th := NewThrottle(c, 1000)
+			th.now, th.sleep = clock.Now, clock.Sleep
+			th.SetTurbo(tt.turbo)
+
+			expectString(t, StopAddress.String(), th.Run(context.Background()).String())
+
+			s := th.Stats()
+			expectUint64(t, 80, s.Cycles)
+			expectUint64(t, uint64(tt.slept), uint64(s.Slept))
+			expectUint64(t, uint64(tt.elapsed), uint64(s.Elapsed))
+			expectUint64(t, tt.late, s.Late)
+			expectUint64(t, uint64(tt.hz), uint64(s.Hz()))
+		})
+	}
+}
+
+func TestThrottleCancelled(t *testing.T) {
+	c := nopCore()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	th := NewThrottle(c, Clock1MHz)
+	expectString(t, StopCancelled.String(), th.Run(ctx).String())
+}
+
+func TestThrottleIdleLoop(t *testing.T) {
+	// JMP $0200, idling the way an interrupt driven program does.
+	c := nopCore()
+	c.Bus.Write(0x0200, 0x4C)
+	c.Bus.Write(0x0201, 0x00)
+	c.Bus.Write(0x0202, 0x02)
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	th := NewThrottle(c, 1000)
+	th.now = clock.Now
+	slices := 0
+	th.sleep = func(ctx context.Context, d time.Duration) {
+		clock.Sleep(ctx, d)
+		if slices++; slices == 3 {
+			cancel()
+		}
+	}
+
+	expectString(t, StopCancelled.String(), th.Run(ctx).String())
+	// Each 10ms slice at 1kHz is 10 cycles, overrun to 12 by the JMPs.
+	expectUint64(t, 36, th.Stats().Cycles)
+	expectUint64(t, uint64(36*time.Millisecond), uint64(th.Stats().Slept))
+}
+
+func TestThrottleRate(t *testing.T) {
+	var tests = map[string]float64{
+		"zero":     0,
+		"negative": -Clock1MHz,
+		"nan":      math.NaN(),
+		"infinite": math.Inf(1),
+	}
+
+	for k, hz := range tests {
+		t.Run(k, func(t *testing.T) {
+			expectBool(t, true, panics(func() { NewThrottle(nopCore(), hz) }))
+
+			th := NewThrottle(nopCore(), Clock1MHz)
+			th.Hz = hz
+			expectBool(t, true, panics(func() { th.Run(context.Background()) }))
+		})
+	}
+}
+
+/*
+panics reports if f panics.
+*/
+func panics(f func()) (panicked bool) {
+	defer func() {
+		panicked = recover() != nil
+	}()
+	f()
+	return false
+}
@@ -0,0 +1,60 @@
+package mos6502
+
+/*
+TrapAction is what the processor does once a trap's handler has run.
+*/
+type TrapAction uint8
+
+/*
+The ways to carry on from a trap.
+*/
+const (
+	// Run on from the program counter as the handler left it, starting with the instruction at the trapped address
+	// if the handler didn't move it.
+	TrapRun TrapAction = iota
+
+	// Return from the subroutine as RTS would, taking as long as RTS does.
+	TrapReturn
+)
+
+/*
+Trap is a Go handler for an address. It has the whole Core to work with, its registers and its Bus.
+*/
+type Trap func(c *Core) TrapAction
+
+/*
+SetTrap has f run whenever the processor is about to run the instruction at an address, so code there can be
+replaced or watched from Go. A trap replaces any set at the address before.
+*/
+func (c *Core) SetTrap(a Address, f Trap) {
+	if c.traps == nil {
+		c.traps = map[Address]Trap{}
+	}
+	c.traps[a] = f
+}
+
+/*
+ClearTrap removes a trap set with SetTrap.
+*/
+func (c *Core) ClearTrap(a Address) {
+	delete(c.traps, a)
+}
+
+/*
+trap runs the trap at the program counter, if there is one. Returns true if the trap stood in for the instruction,
+leaving opCycles holding the cycles it has left to run like step.
+*/
+func (c *Core) trap() bool {
+	f, ok := c.traps[c.PC]
+	if !ok {
+		return false
+	}
+
+	switch f(c) {
+	case TrapReturn:
+		c.RTS()
+		c.opCycles = opcodes[0x60].cycles - 1
+		return true
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package mos6502
+
+import (
+	"context"
+	"testing"
+)
+
+/*
+printCore returns a Core about to print a message through the kernal's CHROUT at $FFD2, which isn't there.
+*/
+func printCore() *Core {
+	return profileCore(map[Address][]byte{
+		0x0200: {
+			0xA2, 0x00, // 0200 LDX #$00
+			0xBD, 0x10, 0x02, // 0202 LDA $0210,X
+			0xF0, 0x06, // 0205 BEQ $020D
+			0x20, 0xD2, 0xFF, // 0207 JSR $FFD2
+			0xE8,       // 020A INX
+			0xD0, 0xF5, // 020B BNE $0202
+			0x4C, 0x0D, 0x02, // 020D JMP $020D
+		},
+		0x0210: []byte("HELLO\x00"),
+	})
+}
+
+func TestTrapReturn(t *testing.T) {
+	var tests = map[string]struct {
+		runner func(c *Core) Runner
+
+		// Attaches anything else to the Core, returning the checks to make after the run.
+		attach func(c *Core) func(t *testing.T)
+	}{
+		"interpreter": {},
+		"block cache": {runner: func(c *Core) Runner { return NewBlockCache(c) }},
+		"profiler": {attach: func(c *Core) func(t *testing.T) {
+			p := NewProfiler(c)
+			return func(t *testing.T) {
+				// The trap sits on a $00 byte, which mustn't be taken for a BRK.
+				subs := p.Subroutines()
+				expectUint64(t, 2, uint64(len(subs)))
+				chrout := subroutine(t, subs, 0xFFD2)
+				expectUint64(t, 5, chrout.Calls)
+				expectUint64(t, 5, chrout.Self.Instructions)
+				expectUint64(t, 5*6, chrout.Self.Cycles)
+				expectUint64(t, c.Cycles(), subroutine(t, subs, 0x0200).Total.Cycles)
+			}
+		}},
+		"coverage": {attach: func(c *Core) func(t *testing.T) {
+			cv := NewCoverage(c)
+			return func(t *testing.T) {
+				expectUint64(t, 0, cv.Executed(0xFFD2))
+				expectBool(t, false, cv.At(0xFFD2)&CoveredOpcode != 0)
+				expectUint64(t, 5, cv.Executed(0x0207))
+			}
+		}},
+		"stack tracker": {attach: func(c *Core) func(t *testing.T) {
+			st := NewStackTracker(c)
+			return func(t *testing.T) {
+				expectUint64(t, 0, uint64(len(st.Problems)))
+				expectUint64(t, 0, uint64(len(st.Frames())))
+			}
+		}},
+	}
+
+	for k, tt := range tests {
+		t.Run(k, func(t *testing.T) {
+			c := printCore()
+			var out []byte
+			c.SetTrap(0xFFD2, func(c *Core) TrapAction {
+				out = append(out, c.AC)
+				return TrapReturn
+			})
+
+			var r Runner = c
+			if tt.runner != nil {
+				r = tt.runner(c)
+			}
+			check := func(t *testing.T) {}
+			if tt.attach != nil {
+				check = tt.attach(c)
+			}
+			expectLoop(t, c, r.Run(context.Background(), Budget{Instructions: 1000, StopOnLoop: true}))
+			expectString(t, "HELLO", string(out))
+			expectByte(t, 0xFF, c.SP)
+
+			// Each character takes a JSR and the trap's RTS on top of the loop.
+			expectUint64(t, 2+5*(4+2+6+6+2+3)+(4+3)+3, c.Cycles())
+			check(t)
+		})
+	}
+}
+
+func TestTrapRun(t *testing.T) {
+	c := printCore()
+	c.Bus.Write(0xFFD2, 0x60) // RTS
+
+	hits := 0
+	c.SetTrap(0xFFD2, func(c *Core) TrapAction {
+		hits++
+		return TrapRun
+	})
+
+	// Skips the first character by moving the program counter on.
+	c.SetTrap(0x0202, func(c *Core) TrapAction {
+		if c.X == 0 {
+			c.X = 1
+		}
+		return TrapRun
+	})
+
+	expectLoop(t, c, c.Run(context.Background(), Budget{Instructions: 1000, StopOnLoop: true}))
+	expectUint64(t, 4, uint64(hits))
+	expectByte(t, 0xFF, c.SP)
+
+	c.ClearTrap(0xFFD2)
+	c.PC = 0x0200
+	expectLoop(t, c, c.Run(context.Background(), Budget{Instructions: 1000, StopOnLoop: true}))
+	expectUint64(t, 4, uint64(hits))
+}
+
+func TestTrapJump(t *testing.T) {
+	c := printCore()
+	c.SetTrap(0x0207, func(c *Core) TrapAction {
+		c.PC = 0x020D
+		return TrapRun
+	})
+
+	expectLoop(t, c, c.Run(context.Background(), Budget{Instructions: 1000, StopOnLoop: true}))
+	expectAddress(t, 0x020D, c.PC)
+	expectByte(t, 'H', c.AC)
+}
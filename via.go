@@ -0,0 +1,535 @@
+package mos6502
+
+/*
+VIA registers, as offsets from where the chip is mapped. Only four address lines are decoded, so the registers repeat
+every 16 bytes.
+*/
+const (
+	viaORB Address = iota
+	viaORA
+	viaDDRB
+	viaDDRA
+	viaT1CL
+	viaT1CH
+	viaT1LL
+	viaT1LH
+	viaT2CL
+	viaT2CH
+	viaSR
+	viaACR
+	viaPCR
+	viaIFR
+	viaIER
+	viaORANoHandshake
+)
+
+/*
+VIA interrupt sources, as laid out in the IFR and IER registers.
+*/
+const (
+	viaIntCA2 byte = 1 << iota
+	viaIntCA1
+	viaIntSR
+	viaIntCB2
+	viaIntCB1
+	viaIntT2
+	viaIntT1
+	viaIntIRQ
+)
+
+/*
+VIA emulates the MOS 6522 Versatile Interface Adapter: two 8-bit ports, two interval timers, a shift register and
+the CA/CB handshake lines. It is mapped onto the Bus as 16 registers, clocked by attaching it to a Core and its
+interrupt output is wired up with Core.ConnectIRQ.
+*/
+type VIA struct {
+	PortA Port
+	PortB Port
+
+	// Control line levels driven from outside the chip. CA2 and CB2 are only read while configured as inputs.
+	CA1 bool
+	CA2 bool
+	CB1 bool
+	CB2 bool
+
+	acr byte
+	pcr byte
+	ifr byte
+	ier byte
+
+	// Timer 1 counter and latch, if it may still raise an interrupt and if it reloads on the next tick.
+	t1       uint16
+	t1Latch  uint16
+	t1Armed  bool
+	t1Reload bool
+	pb7      bool
+
+	// Timer 2 counter and low latch, if it may still raise an interrupt and the last PB6 level for pulse counting.
+	t2      uint16
+	t2Latch byte
+	t2Armed bool
+	pb6Last bool
+
+	// Shift register, the bits left to shift and the dividers for timed shifting.
+	sr      byte
+	srCount uint8
+	srDiv   uint16
+	srPhase bool
+
+	// Port inputs latched on CA1 and CB1.
+	latchA byte
+	latchB byte
+
+	// Levels the chip drives on CA2 and CB2, and if they are pulsed low for a single cycle.
+	ca2Out   bool
+	cb2Out   bool
+	ca2Pulse bool
+	cb2Pulse bool
+
+	ca1Last bool
+	ca2Last bool
+	cb1Last bool
+	cb2Last bool
+}
+
+/*
+NewVIA returns a VIA in its reset state, with the port and control line inputs pulled high.
+*/
+func NewVIA() *VIA {
+	v := &VIA{
+		PortA: Port{Input: 0xFF},
+		PortB: Port{Input: 0xFF},
+		CA1:   true,
+		CA2:   true,
+		CB1:   true,
+		CB2:   true,
+	}
+	v.Reset()
+	return v
+}
+
+/*
+Reset clears the registers as the RES line does. The timers, their latches and the shift register are untouched.
+*/
+func (v *VIA) Reset() {
+	v.PortA.setData(0)
+	v.PortA.setDDR(0)
+	v.PortB.setData(0)
+	v.PortB.setDDR(0)
+	v.acr, v.pcr, v.ifr, v.ier = 0, 0, 0, 0
+	v.t1Armed, v.t2Armed = false, false
+	v.srCount = 0
+	v.ca2Out, v.cb2Out = true, true
+	v.ca2Pulse, v.cb2Pulse = false, false
+	v.ca1Last, v.ca2Last, v.cb1Last, v.cb2Last = v.CA1, v.CA2, v.CB1, v.CB2
+	v.pb6Last = v.PortB.Pins()&0x40 != 0
+	v.updatePB7()
+}
+
+/*
+Read returns the value of a register, with the side effects reading it has on the chip.
+*/
+func (v *VIA) Read(a Address) byte {
+	switch a & 0x0F {
+	case viaORB:
+		v.clearFlags(viaIntCB1 | v.cb2Clears())
+		in := v.PortB.Pins()
+		if v.acr&0x02 != 0 {
+			in = v.latchB
+		}
+		return (v.PortB.Data & v.PortB.DDR) | (in &^ v.PortB.DDR)
+	case viaORA:
+		v.clearFlags(viaIntCA1 | v.ca2Clears())
+		v.handshakeA()
+		return v.inputA()
+	case viaDDRB:
+		return v.PortB.DDR
+	case viaDDRA:
+		return v.PortA.DDR
+	case viaT1CL:
+		v.clearFlags(viaIntT1)
+		return byte(v.t1)
+	case viaT1CH:
+		return byte(v.t1 >> 8)
+	case viaT1LL:
+		return byte(v.t1Latch)
+	case viaT1LH:
+		return byte(v.t1Latch >> 8)
+	case viaT2CL:
+		v.clearFlags(viaIntT2)
+		return byte(v.t2)
+	case viaT2CH:
+		return byte(v.t2 >> 8)
+	case viaSR:
+		v.clearFlags(viaIntSR)
+		v.startShift()
+		return v.sr
+	case viaACR:
+		return v.acr
+	case viaPCR:
+		return v.pcr
+	case viaIFR:
+		if v.Interrupting() {
+			return v.ifr | viaIntIRQ
+		}
+		return v.ifr
+	case viaIER:
+		return v.ier | 0x80
+	default:
+		return v.inputA()
+	}
+}
+
+/*
+Write stores a value in a register, with the side effects writing it has on the chip.
+*/
+func (v *VIA) Write(a Address, d byte) {
+	switch a & 0x0F {
+	case viaORB:
+		v.clearFlags(viaIntCB1 | v.cb2Clears())
+		v.PortB.setData(d)
+		v.handshakeB()
+	case viaORA:
+		v.clearFlags(viaIntCA1 | v.ca2Clears())
+		v.PortA.setData(d)
+		v.handshakeA()
+	case viaDDRB:
+		v.PortB.setDDR(d)
+	case viaDDRA:
+		v.PortA.setDDR(d)
+	case viaT1CL, viaT1LL:
+		v.t1Latch = (v.t1Latch & 0xFF00) | uint16(d)
+	case viaT1CH:
+		v.t1Latch = (v.t1Latch & 0x00FF) | uint16(d)<<8
+		v.t1 = v.t1Latch
+		v.t1Armed = true
+		v.t1Reload = false
+		v.clearFlags(viaIntT1)
+		if v.acr&0x80 != 0 {
+			v.pb7 = false
+			v.updatePB7()
+		}
+	case viaT1LH:
+		v.t1Latch = (v.t1Latch & 0x00FF) | uint16(d)<<8
+		v.clearFlags(viaIntT1)
+	case viaT2CL:
+		v.t2Latch = d
+	case viaT2CH:
+		v.t2 = uint16(d)<<8 | uint16(v.t2Latch)
+		v.t2Armed = true
+		v.clearFlags(viaIntT2)
+	case viaSR:
+		v.sr = d
+		v.clearFlags(viaIntSR)
+		v.startShift()
+	case viaACR:
+		v.acr = d
+		v.updatePB7()
+	case viaPCR:
+		v.pcr = d
+		v.ca2Out = v.ca2Mode() != 6
+		v.cb2Out = v.cb2Mode() != 6
+	case viaIFR:
+		v.ifr &^= d & 0x7F
+	case viaIER:
+		if d&0x80 != 0 {
+			v.ier |= d & 0x7F
+		} else {
+			v.ier &^= d & 0x7F
+		}
+	default:
+		v.PortA.setData(d)
+	}
+}
+
+/*
+Tick advances the chip by one cycle of the system clock.
+*/
+func (v *VIA) Tick() {
+	if v.ca2Pulse {
+		v.ca2Pulse, v.ca2Out = false, true
+	}
+	if v.cb2Pulse {
+		v.cb2Pulse, v.cb2Out = false, true
+	}
+
+	v.edges()
+	v.tickT1()
+	v.tickT2()
+	v.tickShift()
+}
+
+/*
+Interrupting reports if the IRQ output is asserted, which it is while any enabled interrupt is flagged.
+*/
+func (v *VIA) Interrupting() bool {
+	return v.ifr&v.ier&0x7F != 0
+}
+
+/*
+CA2Output returns the level the chip drives on CA2 when it is configured as an output.
+*/
+func (v *VIA) CA2Output() bool {
+	return v.ca2Out
+}
+
+/*
+CB2Output returns the level the chip drives on CB2 when it is configured as an output or shifting out.
+*/
+func (v *VIA) CB2Output() bool {
+	return v.cb2Out
+}
+
+/*
+edges looks for transitions on the control lines since the last tick.
+*/
+func (v *VIA) edges() {
+	if v.CA1 != v.ca1Last {
+		v.ca1Last = v.CA1
+		if v.CA1 == (v.pcr&0x01 != 0) {
+			v.ifr |= viaIntCA1
+			if v.acr&0x01 != 0 {
+				v.latchA = v.PortA.Pins()
+			}
+			if v.ca2Mode() == 4 {
+				v.ca2Out = true
+			}
+		}
+	}
+
+	if v.CA2 != v.ca2Last {
+		v.ca2Last = v.CA2
+		if v.ca2Mode() < 4 && v.CA2 == (v.pcr&0x04 != 0) {
+			v.ifr |= viaIntCA2
+		}
+	}
+
+	if v.CB1 != v.cb1Last {
+		v.cb1Last = v.CB1
+		if v.CB1 == (v.pcr&0x10 != 0) {
+			v.ifr |= viaIntCB1
+			if v.acr&0x02 != 0 {
+				v.latchB = v.PortB.Pins()
+			}
+			if v.cb2Mode() == 4 {
+				v.cb2Out = true
+			}
+		}
+		if v.CB1 && (v.srMode() == 3 || v.srMode() == 7) {
+			v.shift()
+		}
+	}
+
+	if v.CB2 != v.cb2Last {
+		v.cb2Last = v.CB2
+		if v.cb2Mode() < 4 && v.CB2 == (v.pcr&0x40 != 0) {
+			v.ifr |= viaIntCB2
+		}
+	}
+}
+
+/*
+tickT1 counts down timer 1. In one-shot mode it interrupts once after being started, in free-running mode it reloads
+from the latch and interrupts on every time-out. Either way PB7 can follow it.
+*/
+func (v *VIA) tickT1() {
+	if v.t1Reload {
+		v.t1 = v.t1Latch
+		v.t1Reload = false
+		return
+	}
+
+	v.t1--
+	if v.t1 != 0xFFFF {
+		return
+	}
+
+	if v.acr&0x40 != 0 {
+		v.t1Reload = true
+		v.ifr |= viaIntT1
+		v.pb7 = !v.pb7
+		v.updatePB7()
+		return
+	}
+
+	if v.t1Armed {
+		v.t1Armed = false
+		v.ifr |= viaIntT1
+		v.pb7 = true
+		v.updatePB7()
+	}
+}
+
+/*
+tickT2 counts down timer 2, either every cycle or on each falling edge of PB6 when counting pulses.
+*/
+func (v *VIA) tickT2() {
+	if v.acr&0x20 == 0 {
+		v.t2--
+		if v.t2 == 0xFFFF && v.t2Armed {
+			v.t2Armed = false
+			v.ifr |= viaIntT2
+		}
+		return
+	}
+
+	pb6 := v.PortB.Pins()&0x40 != 0
+	falling := v.pb6Last && !pb6
+	v.pb6Last = pb6
+	if !falling {
+		return
+	}
+
+	v.t2--
+	if v.t2 == 0 && v.t2Armed {
+		v.t2Armed = false
+		v.ifr |= viaIntT2
+	}
+}
+
+/*
+tickShift clocks the shift register in the modes timed by the chip itself.
+*/
+func (v *VIA) tickShift() {
+	switch v.srMode() {
+	case 1, 4, 5:
+		if v.srDiv > 0 {
+			v.srDiv--
+			return
+		}
+		v.srDiv = uint16(v.t2Latch) + 1
+		v.shift()
+	case 2, 6:
+		v.srPhase = !v.srPhase
+		if !v.srPhase {
+			v.shift()
+		}
+	}
+}
+
+/*
+shift moves the shift register along one bit, in from CB2 or out onto it.
+*/
+func (v *VIA) shift() {
+	mode := v.srMode()
+	if v.srCount == 0 && mode != 4 {
+		return
+	}
+
+	if mode&0x04 != 0 {
+		out := v.sr&0x80 != 0
+		v.sr = v.sr<<1 | v.sr>>7
+		v.cb2Out = out
+	} else {
+		v.sr <<= 1
+		if v.CB2 {
+			v.sr |= 0x01
+		}
+	}
+
+	if mode == 4 {
+		return
+	}
+
+	v.srCount--
+	if v.srCount == 0 {
+		v.ifr |= viaIntSR
+	}
+}
+
+/*
+startShift begins shifting eight bits, following an access to the shift register.
+*/
+func (v *VIA) startShift() {
+	if v.srMode() == 0 {
+		return
+	}
+	v.srCount = 8
+	v.srDiv = uint16(v.t2Latch) + 1
+	v.srPhase = false
+}
+
+/*
+handshakeA drops CA2 after an access to port A when it is in handshake or pulse output mode.
+*/
+func (v *VIA) handshakeA() {
+	switch v.ca2Mode() {
+	case 4:
+		v.ca2Out = false
+	case 5:
+		v.ca2Out, v.ca2Pulse = false, true
+	}
+}
+
+/*
+handshakeB drops CB2 after a write to port B when it is in handshake or pulse output mode.
+*/
+func (v *VIA) handshakeB() {
+	switch v.cb2Mode() {
+	case 4:
+		v.cb2Out = false
+	case 5:
+		v.cb2Out, v.cb2Pulse = false, true
+	}
+}
+
+/*
+inputA returns what reading port A gives, the pins or the value latched on CA1.
+*/
+func (v *VIA) inputA() byte {
+	if v.acr&0x01 != 0 {
+		return v.latchA
+	}
+	return v.PortA.Pins()
+}
+
+/*
+ca2Clears returns the CA2 flag if accessing port A should clear it, which it does unless CA2 is an independent input.
+*/
+func (v *VIA) ca2Clears() byte {
+	if v.pcr&0x0A == 0x02 {
+		return 0
+	}
+	return viaIntCA2
+}
+
+/*
+cb2Clears returns the CB2 flag if accessing port B should clear it, which it does unless CB2 is an independent input.
+*/
+func (v *VIA) cb2Clears() byte {
+	if v.pcr&0xA0 == 0x20 {
+		return 0
+	}
+	return viaIntCB2
+}
+
+func (v *VIA) clearFlags(f byte) {
+	v.ifr &^= f
+}
+
+/*
+updatePB7 hands PB7 to timer 1 while the ACR enables its output.
+*/
+func (v *VIA) updatePB7() {
+	if v.acr&0x80 == 0 {
+		v.PortB.force(0, 0)
+		return
+	}
+	var level byte
+	if v.pb7 {
+		level = 0x80
+	}
+	v.PortB.force(0x80, level)
+}
+
+func (v *VIA) ca2Mode() byte {
+	return (v.pcr >> 1) & 0x07
+}
+
+func (v *VIA) cb2Mode() byte {
+	return (v.pcr >> 5) & 0x07
+}
+
+func (v *VIA) srMode() byte {
+	return (v.acr >> 2) & 0x07
+}
@@ -0,0 +1,186 @@
+package mos6502
+
+import "testing"
+
+/*
+tickVIA ticks the chip n times.
+*/
+func tickVIA(v *VIA, n int) {
+	for i := 0; i < n; i++ {
+		v.Tick()
+	}
+}
+
+func TestVIAPorts(t *testing.T) {
+	v := NewVIA()
+	var seen byte
+	v.PortA.OnWrite = func(pins byte) { seen = pins }
+	v.PortA.Input = 0x30
+
+	v.Write(viaDDRA, 0x0F)
+	v.Write(viaORA, 0xA5)
+
+	expectByte(t, 0x35, v.PortA.Pins())
+	expectByte(t, 0x35, seen)
+	expectByte(t, 0x35, v.Read(viaORA))
+	expectByte(t, 0x0F, v.Read(viaDDRA))
+
+	// The registers repeat every 16 bytes.
+	expectByte(t, 0x0F, v.Read(0x13))
+}
+
+func TestVIATimer1(t *testing.T) {
+	var tests = map[string]struct {
+		acr     byte
+		ticks   int
+		flagged bool
+		pb7     bool
+		counter byte
+	}{
+		"one-shot before time-out":      {acr: 0x00, ticks: 5, flagged: false, counter: 0x00},
+		"one-shot at time-out":          {acr: 0x00, ticks: 6, flagged: true, counter: 0xFF},
+		"one-shot PB7 low":              {acr: 0x80, ticks: 3, flagged: false, pb7: false, counter: 0x02},
+		"one-shot PB7 high again":       {acr: 0x80, ticks: 6, flagged: true, pb7: true, counter: 0xFF},
+		"free-running reloads":          {acr: 0x40, ticks: 8, flagged: true, counter: 0x04},
+		"free-running PB7 toggles":      {acr: 0xC0, ticks: 8, flagged: true, pb7: true, counter: 0x04},
+		"free-running PB7 toggles back": {acr: 0xC0, ticks: 13, flagged: true, pb7: false, counter: 0xFF},
+	}
+
+	for k, tt := range tests {
+		t.Run(k, func(t *testing.T) {
+			v := NewVIA()
+			v.Write(viaACR, tt.acr)
+			v.Write(viaT1CL, 0x05)
+			v.Write(viaT1CH, 0x00)
+
+			tickVIA(v, tt.ticks)
+
+			expectBool(t, tt.flagged, v.Read(viaIFR)&viaIntT1 != 0)
+			if tt.acr&0x80 != 0 {
+				expectBool(t, tt.pb7, v.PortB.Pins()&0x80 != 0)
+			}
+			expectByte(t, tt.counter, byte(v.t1))
+		})
+	}
+}
+
+func TestVIATimer1OneShotOnlyOnce(t *testing.T) {
+	v := NewVIA()
+	v.Write(viaT1CL, 0x02)
+	v.Write(viaT1CH, 0x00)
+
+	tickVIA(v, 3)
+	expectBool(t, true, v.Read(viaIFR)&viaIntT1 != 0)
+
+	// Reading the low counter clears the flag, and it stays clear as the counter wraps.
+	v.Read(viaT1CL)
+	tickVIA(v, 0x10000)
+	expectBool(t, false, v.Read(viaIFR)&viaIntT1 != 0)
+}
+
+func TestVIATimer2PulseCounting(t *testing.T) {
+	v := NewVIA()
+	v.Write(viaACR, 0x20)
+	v.Write(viaT2CL, 0x03)
+	v.Write(viaT2CH, 0x00)
+
+	// Ticking without pulses leaves the counter alone.
+	tickVIA(v, 100)
+	expectByte(t, 0x03, v.Read(viaT2CL))
+
+	for i := 0; i < 3; i++ {
+		expectBool(t, false, v.Read(viaIFR)&viaIntT2 != 0)
+		v.PortB.Input = 0xBF
+		v.Tick()
+		v.PortB.Input = 0xFF
+		v.Tick()
+	}
+	expectBool(t, true, v.Read(viaIFR)&viaIntT2 != 0)
+}
+
+func TestVIAInterrupts(t *testing.T) {
+	v := NewVIA()
+	expectByte(t, 0x80, v.Read(viaIER))
+
+	// A negative edge on CA1 flags it, but it only reaches IRQ once enabled.
+	v.CA1 = false
+	v.Tick()
+	expectByte(t, viaIntCA1, v.Read(viaIFR))
+	expectBool(t, false, v.Interrupting())
+
+	v.Write(viaIER, 0x80|viaIntCA1)
+	expectByte(t, 0x80|viaIntCA1, v.Read(viaIER))
+	expectByte(t, viaIntIRQ|viaIntCA1, v.Read(viaIFR))
+	expectBool(t, true, v.Interrupting())
+
+	// Writing a one to a flag clears it.
+	v.Write(viaIFR, viaIntCA1)
+	expectBool(t, false, v.Interrupting())
+
+	// Disabling is done with bit 7 clear.
+	v.Write(viaIER, viaIntCA1)
+	expectByte(t, 0x80, v.Read(viaIER))
+}
+
+func TestVIAHandshake(t *testing.T) {
+	var tests = map[string]struct {
+		pcr    byte
+		before bool
+		after  bool
+	}{
+		"handshake": {pcr: 0x08, before: false, after: true},
+		"pulse":     {pcr: 0x0A, before: false, after: true},
+		"low":       {pcr: 0x0C, before: false, after: false},
+		"high":      {pcr: 0x0E, before: true, after: true},
+	}
+
+	for k, tt := range tests {
+		t.Run(k, func(t *testing.T) {
+			v := NewVIA()
+			v.Write(viaPCR, tt.pcr)
+			v.Read(viaORA)
+			expectBool(t, tt.before, v.CA2Output())
+
+			// Handshake mode waits for CA1, pulse mode returns high on its own.
+			v.CA1 = false
+			v.Tick()
+			expectBool(t, tt.after, v.CA2Output())
+		})
+	}
+}
+
+func TestVIAShiftOut(t *testing.T) {
+	v := NewVIA()
+	v.Write(viaACR, 0x18)
+	v.Write(viaSR, 0xA5)
+
+	var out byte
+	for i := 0; i < 8; i++ {
+		expectBool(t, false, v.Read(viaIFR)&viaIntSR != 0)
+		tickVIA(v, 2)
+		out <<= 1
+		if v.CB2Output() {
+			out |= 0x01
+		}
+	}
+
+	expectByte(t, 0xA5, out)
+	expectBool(t, true, v.Read(viaIFR)&viaIntSR != 0)
+}
+
+func TestVIAShiftIn(t *testing.T) {
+	v := NewVIA()
+	v.Write(viaACR, 0x0C)
+	v.Read(viaSR)
+
+	for _, bit := range []bool{true, false, true, true, false, false, true, false} {
+		v.CB2 = bit
+		v.CB1 = false
+		v.Tick()
+		v.CB1 = true
+		v.Tick()
+	}
+
+	expectBool(t, true, v.Read(viaIFR)&viaIntSR != 0)
+	expectByte(t, 0xB2, v.Read(viaSR))
+}